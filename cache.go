@@ -0,0 +1,193 @@
+package wechat
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/silenceper/wechat/v2/cache"
+)
+
+// RedisConfig Redis 连接配置
+// 配置 MasterName 时使用 Sentinel 模式，Cluster 为 true 或 Addrs 有多个地址时使用 Cluster 模式
+type RedisConfig struct {
+	Addr             string          `json:"addr" yaml:"addr" mapstructure:"addr"`                                     // Redis地址，单节点模式使用
+	Addrs            []string        `json:"addrs" yaml:"addrs" mapstructure:"addrs"`                                  // Sentinel 或 Cluster 节点地址列表
+	Username         string          `json:"username" yaml:"username" mapstructure:"username"`                         // 用户名，可选
+	Password         string          `json:"password" yaml:"password" mapstructure:"password"`                         // 密码，可选
+	DB               int             `json:"db" yaml:"db" mapstructure:"db"`                                           // 数据库编号，Cluster 模式只能为 0
+	PoolSize         int             `json:"poolSize" yaml:"poolSize" mapstructure:"poolSize"`                         // 连接池大小，默认为 CPU 核数的 10 倍
+	MinIdleConns     int             `json:"minIdleConns" yaml:"minIdleConns" mapstructure:"minIdleConns"`             // 最小空闲连接数
+	MasterName       string          `json:"masterName" yaml:"masterName" mapstructure:"masterName"`                   // Sentinel 主节点名称
+	SentinelUsername string          `json:"sentinelUsername" yaml:"sentinelUsername" mapstructure:"sentinelUsername"` // Sentinel 用户名，可选
+	SentinelPassword string          `json:"sentinelPassword" yaml:"sentinelPassword" mapstructure:"sentinelPassword"` // Sentinel 密码，可选
+	Cluster          bool            `json:"cluster" yaml:"cluster" mapstructure:"cluster"`                            // 是否使用 Cluster 模式
+	TLS              *RedisTLSConfig `json:"tls" yaml:"tls" mapstructure:"tls"`                                        // TLS 配置，为空时不使用 TLS
+}
+
+// RedisTLSConfig Redis TLS 配置
+type RedisTLSConfig struct {
+	ServerName         string `json:"serverName" yaml:"serverName" mapstructure:"serverName"`                         // 证书校验的服务器名称，默认取实际连接节点的主机名
+	InsecureSkipVerify bool   `json:"insecureSkipVerify" yaml:"insecureSkipVerify" mapstructure:"insecureSkipVerify"` // 跳过证书校验，仅用于测试环境
+}
+
+// addrs 返回全部节点地址
+func (c *RedisConfig) addrs() []string {
+	if len(c.Addrs) > 0 {
+		return c.Addrs
+	}
+	if c.Addr != "" {
+		return []string{c.Addr}
+	}
+	return nil
+}
+
+// Validate 校验 Redis 配置，返回全部错误
+func (c *RedisConfig) Validate() error {
+	var errs []error
+	if c.Addr != "" && len(c.Addrs) > 0 {
+		errs = append(errs, errors.New("addr 和 addrs 只能配置一个"))
+	}
+	if c.MasterName != "" && c.Cluster {
+		errs = append(errs, errors.New("masterName 和 cluster 不能同时配置"))
+	}
+	if (c.MasterName != "" || c.Cluster) && len(c.addrs()) == 0 {
+		errs = append(errs, errors.New("Sentinel 或 Cluster 模式需配置 addrs"))
+	}
+	if c.isCluster() && c.DB != 0 {
+		errs = append(errs, fmt.Errorf("Cluster 模式不支持选择数据库: %d", c.DB))
+	}
+	if c.DB < 0 {
+		errs = append(errs, fmt.Errorf("db 不能为负数: %d", c.DB))
+	}
+	if c.PoolSize < 0 || c.MinIdleConns < 0 {
+		errs = append(errs, errors.New("poolSize 和 minIdleConns 不能为负数"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// isCluster 是否使用 Cluster 模式
+func (c *RedisConfig) isCluster() bool {
+	return c.MasterName == "" && (c.Cluster || len(c.Addrs) > 1)
+}
+
+// NewRedisClient 根据配置创建 go-redis 客户端
+func NewRedisClient(cfg *RedisConfig) redis.UniversalClient {
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.addrs(),
+		DB:               cfg.DB,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		SentinelUsername: cfg.SentinelUsername,
+		SentinelPassword: cfg.SentinelPassword,
+		MasterName:       cfg.MasterName,
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     cfg.MinIdleConns,
+	}
+	if cfg.TLS != nil {
+		opts.TLSConfig = &tls.Config{
+			ServerName:         cfg.TLS.ServerName,
+			InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+		}
+		// 仅单节点模式默认使用连接地址的主机名，Sentinel 和 Cluster 模式留空，由 TLS 握手按实际连接的节点地址校验
+		if opts.TLSConfig.ServerName == "" && cfg.MasterName == "" && !cfg.isCluster() && len(opts.Addrs) > 0 {
+			if host, _, err := net.SplitHostPort(opts.Addrs[0]); err == nil {
+				opts.TLSConfig.ServerName = host
+			}
+		}
+	}
+
+	switch {
+	case cfg.MasterName != "":
+		return redis.NewFailoverClient(opts.Failover())
+	case cfg.isCluster():
+		return redis.NewClusterClient(opts.Cluster())
+	default:
+		return redis.NewClient(opts.Simple())
+	}
+}
+
+// NewCache 根据 Redis 配置创建缓存，未配置 Redis 地址时使用内存缓存
+// 每次调用都会创建新的连接池，多个客户端共用连接池时请将返回值传给 NewXxxWithCache 构造函数
+func NewCache(cfg *RedisConfig) cache.Cache {
+	if cfg == nil || len(cfg.addrs()) == 0 {
+		return cache.NewMemory()
+	}
+
+	// cache.NewRedis 只支持单节点且会创建连接池，这里直接构造并使用按配置创建的客户端
+	redisCache := &cache.Redis{}
+	redisCache.SetRedisCtx(context.Background())
+	redisCache.SetConn(NewRedisClient(cfg))
+
+	return redisCache
+}
+
+var (
+	sharedCachesMu sync.Mutex
+	sharedCaches   = make(map[string]cache.Cache)
+)
+
+// sharedCache 返回相同 Redis 配置共用的缓存，避免每个客户端各自创建连接池
+func sharedCache(cfg *RedisConfig) cache.Cache {
+	key, _ := json.Marshal(cfg)
+
+	sharedCachesMu.Lock()
+	defer sharedCachesMu.Unlock()
+	if c, ok := sharedCaches[string(key)]; ok {
+		return c
+	}
+	c := NewCache(cfg)
+	sharedCaches[string(key)] = c
+
+	return c
+}
+
+// newCache 创建客户端使用的缓存
+// 配置了 Redis 选项时优先使用，否则兼容旧的 RedisAddr 配置（不带用户名和密码，需要认证时请配置 Redis 选项）
+// 相同 Redis 配置的客户端共用一个连接池，都未配置时使用独立的内存缓存
+func newCache(redisCfg *RedisConfig, redisAddr string) cache.Cache {
+	if redisCfg == nil && redisAddr != "" {
+		redisCfg = &RedisConfig{Addr: redisAddr}
+	}
+	if redisCfg == nil || len(redisCfg.addrs()) == 0 {
+		return cache.NewMemory()
+	}
+
+	return sharedCache(redisCfg)
+}
+
+// cacheGetJSON 从缓存读取 JSON 序列化的值
+// Redis 缓存返回 string，内存缓存返回写入时的原值，这里统一按 JSON 文本处理
+func cacheGetJSON(ctx context.Context, c cache.Cache, key string, v any) bool {
+	var data []byte
+	switch val := cache.GetContext(ctx, c, key).(type) {
+	case string:
+		data = []byte(val)
+	case []byte:
+		data = val
+	default:
+		return false
+	}
+	if len(data) == 0 {
+		return false
+	}
+
+	return json.Unmarshal(data, v) == nil
+}
+
+// cacheSetJSON 将值序列化为 JSON 文本后写入缓存
+func cacheSetJSON(ctx context.Context, c cache.Cache, key string, v any, timeout time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return cache.SetContext(ctx, c, key, string(data), timeout)
+}
@@ -0,0 +1,130 @@
+package wechat
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/silenceper/wechat/v2/util"
+)
+
+const (
+	callbackFormatXML  = "xml"
+	callbackFormatJSON = "json"
+)
+
+// callbackTimestampSkew 回调时间戳与本地时间允许的最大偏差，超出时视为重放请求
+const callbackTimestampSkew = 5 * time.Minute
+
+// verifyCallbackSignature 校验回调签名，签名为参数字典序排序后拼接的 sha1 值
+func verifyCallbackSignature(signature string, params ...string) bool {
+	expected := util.Signature(params...)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) == 1
+}
+
+// openCallbackBody 校验签名并返回回调的明文数据
+// 配置了 EncodingAESKey 时只接受 encrypt_type 为 aes 的请求，使用 msg_signature 校验并解密 Encrypt 字段
+// 明文模式的 signature 不覆盖消息体，只在未配置 EncodingAESKey 时接受
+func openCallbackBody(query url.Values, body []byte, appID, token, encodingAESKey string) (string, []byte, error) {
+	format := callbackFormatJSON
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("<")) {
+		format = callbackFormatXML
+	}
+
+	timestamp, nonce := query.Get("timestamp"), query.Get("nonce")
+	if err := checkCallbackTimestamp(timestamp); err != nil {
+		return "", nil, err
+	}
+
+	encrypted := query.Get("encrypt_type") == "aes"
+	if encodingAESKey == "" {
+		if encrypted {
+			return "", nil, errors.New("未配置 EncodingAESKey，无法解密消息")
+		}
+		if !verifyCallbackSignature(query.Get("signature"), token, timestamp, nonce) {
+			return "", nil, errors.New("签名校验失败")
+		}
+		return format, body, nil
+	}
+	if !encrypted {
+		return "", nil, errors.New("已配置 EncodingAESKey，拒绝未加密的消息")
+	}
+
+	var envelope struct {
+		Encrypt string `xml:"Encrypt" json:"Encrypt"`
+	}
+	if err := unmarshalCallback(format, body, &envelope); err != nil {
+		return "", nil, err
+	}
+	if !verifyCallbackSignature(query.Get("msg_signature"), token, timestamp, nonce, envelope.Encrypt) {
+		return "", nil, errors.New("签名校验失败")
+	}
+
+	_, plain, err := util.DecryptMsg(appID, envelope.Encrypt, encodingAESKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("解密消息失败: %w", err)
+	}
+
+	return format, plain, nil
+}
+
+// checkCallbackTimestamp 校验回调时间戳是否在允许的偏差范围内
+func checkCallbackTimestamp(timestamp string) error {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("无效的时间戳: %s", timestamp)
+	}
+	if skew := time.Since(time.Unix(sec, 0)); skew > callbackTimestampSkew || skew < -callbackTimestampSkew {
+		return fmt.Errorf("时间戳超出允许范围: %s", timestamp)
+	}
+	return nil
+}
+
+// unmarshalCallback 按数据格式解析回调数据
+func unmarshalCallback(format string, data []byte, v any) error {
+	if strings.EqualFold(format, callbackFormatXML) {
+		return xml.Unmarshal(data, v)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// encryptedXMLReply 安全模式的被动回复
+type encryptedXMLReply struct {
+	XMLName      xml.Name `xml:"xml"`
+	Encrypt      cdata    `xml:"Encrypt"`
+	MsgSignature cdata    `xml:"MsgSignature"`
+	TimeStamp    string   `xml:"TimeStamp"`
+	Nonce        cdata    `xml:"Nonce"`
+}
+
+// encryptXMLReply 加密被动回复消息，timestamp 和 nonce 使用回调请求中的值
+func encryptXMLReply(plain []byte, appID, token, encodingAESKey, timestamp, nonce string) ([]byte, error) {
+	encrypted, err := util.EncryptMsg([]byte(util.RandomStr(16)), plain, appID, encodingAESKey)
+	if err != nil {
+		return nil, fmt.Errorf("加密回复消息失败: %w", err)
+	}
+
+	return xml.Marshal(&encryptedXMLReply{
+		Encrypt:      cdata(encrypted),
+		MsgSignature: cdata(util.Signature(token, timestamp, nonce, string(encrypted))),
+		TimeStamp:    timestamp,
+		Nonce:        cdata(nonce),
+	})
+}
+
+// cdata 序列化为 CDATA 的 XML 文本
+type cdata string
+
+// MarshalXML 将文本写为 CDATA
+func (c cdata) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		Value string `xml:",cdata"`
+	}{string(c)}, start)
+}
@@ -0,0 +1,311 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 小程序版本
+const (
+	EnvVersionRelease = "release" // 正式版
+	EnvVersionTrial   = "trial"   // 体验版
+	EnvVersionDevelop = "develop" // 开发版
+)
+
+// encodingAESKeyLength 消息加解密密钥长度
+const encodingAESKeyLength = 43
+
+// 环境变量名，通过环境变量可配置一个小程序、公众号和企业微信应用
+const (
+	EnvRedisAddr     = "WECHAT_REDIS_ADDR"
+	EnvRedisUsername = "WECHAT_REDIS_USERNAME"
+	EnvRedisPassword = "WECHAT_REDIS_PASSWORD"
+
+	EnvMiniProgramAppID          = "WECHAT_MINIPROGRAM_APP_ID"
+	EnvMiniProgramAppSecret      = "WECHAT_MINIPROGRAM_APP_SECRET"
+	EnvMiniProgramExpireInterval = "WECHAT_MINIPROGRAM_EXPIRE_INTERVAL"
+	EnvMiniProgramEnvVersion     = "WECHAT_MINIPROGRAM_ENV_VERSION"
+	EnvMiniProgramToken          = "WECHAT_MINIPROGRAM_TOKEN"
+	EnvMiniProgramEncodingAESKey = "WECHAT_MINIPROGRAM_ENCODING_AES_KEY"
+
+	EnvOfficialAccountAppID          = "WECHAT_OFFICIALACCOUNT_APP_ID"
+	EnvOfficialAccountAppSecret      = "WECHAT_OFFICIALACCOUNT_APP_SECRET"
+	EnvOfficialAccountToken          = "WECHAT_OFFICIALACCOUNT_TOKEN"
+	EnvOfficialAccountEncodingAESKey = "WECHAT_OFFICIALACCOUNT_ENCODING_AES_KEY"
+
+	EnvWorkwxCorpID         = "WECHAT_WORKWX_CORP_ID"
+	EnvWorkwxAgentID        = "WECHAT_WORKWX_AGENT_ID"
+	EnvWorkwxAgentSecret    = "WECHAT_WORKWX_AGENT_SECRET"
+	EnvWorkwxToken          = "WECHAT_WORKWX_TOKEN"
+	EnvWorkwxEncodingAESKey = "WECHAT_WORKWX_ENCODING_AES_KEY"
+	EnvWorkwxBaseURL        = "WECHAT_WORKWX_BASE_URL"
+)
+
+// Config 多应用配置
+// 可从 YAML、JSON 文件和环境变量加载，Config 本身即为 ConfigSource，可直接用于创建注册中心
+type Config struct {
+	Redis              *RedisConfig `json:"redis" yaml:"redis" mapstructure:"redis"` // 共用的 Redis 配置，为空时使用内存缓存
+	StaticConfigSource `yaml:",inline" mapstructure:",squash"`
+}
+
+// LoadConfig 加载配置文件并使用环境变量覆盖，校验通过后返回
+// path 为空时只从环境变量加载，文件格式按扩展名识别，支持 .yaml、.yml 和 .json
+// 配置文件中出现未知字段时报错，避免字段名拼写错误被忽略
+// 校验失败时返回全部错误
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取配置文件失败: %w", err)
+		}
+		if err := decodeConfig(data, filepath.Ext(path), cfg); err != nil {
+			return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// decodeConfig 按文件扩展名解析配置
+func decodeConfig(data []byte, ext string, cfg *Config) error {
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		return decoder.Decode(cfg)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		return decoder.Decode(cfg)
+	default:
+		return fmt.Errorf("不支持的配置文件格式: %s", ext)
+	}
+}
+
+// applyEnv 使用环境变量覆盖配置
+// 环境变量中的应用与配置文件中 appid 相同时覆盖其字段，否则追加为新应用
+func (c *Config) applyEnv() error {
+	var errs []error
+
+	if addr, username, password := os.Getenv(EnvRedisAddr), os.Getenv(EnvRedisUsername), os.Getenv(EnvRedisPassword); addr != "" || username != "" || password != "" {
+		if c.Redis == nil {
+			c.Redis = &RedisConfig{}
+		}
+		setFromEnv(&c.Redis.Addr, EnvRedisAddr)
+		setFromEnv(&c.Redis.Username, EnvRedisUsername)
+		setFromEnv(&c.Redis.Password, EnvRedisPassword)
+	}
+
+	if appID := os.Getenv(EnvMiniProgramAppID); appID != "" {
+		cfg, _ := c.MiniProgramConfig(context.Background(), appID)
+		if cfg == nil {
+			cfg = &MiniProgramConfig{AppId: appID}
+			c.MiniPrograms = append(c.MiniPrograms, cfg)
+		}
+		setFromEnv(&cfg.AppSecret, EnvMiniProgramAppSecret)
+		setFromEnv(&cfg.EnvVersion, EnvMiniProgramEnvVersion)
+		setFromEnv(&cfg.Token, EnvMiniProgramToken)
+		setFromEnv(&cfg.EncodingAESKey, EnvMiniProgramEncodingAESKey)
+		if val := os.Getenv(EnvMiniProgramExpireInterval); val != "" {
+			interval, err := strconv.Atoi(val)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s 必须为整数: %w", EnvMiniProgramExpireInterval, err))
+			}
+			cfg.ExpireInterval = interval
+		}
+	}
+
+	if appID := os.Getenv(EnvOfficialAccountAppID); appID != "" {
+		cfg, _ := c.OfficialAccountConfig(context.Background(), appID)
+		if cfg == nil {
+			cfg = &OfficialAccountConfig{AppId: appID}
+			c.OfficialAccounts = append(c.OfficialAccounts, cfg)
+		}
+		setFromEnv(&cfg.AppSecret, EnvOfficialAccountAppSecret)
+		setFromEnv(&cfg.Token, EnvOfficialAccountToken)
+		setFromEnv(&cfg.EncodingAESKey, EnvOfficialAccountEncodingAESKey)
+	}
+
+	if corpID := os.Getenv(EnvWorkwxCorpID); corpID != "" {
+		var agentID int64
+		if val := os.Getenv(EnvWorkwxAgentID); val != "" {
+			id, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s 必须为整数: %w", EnvWorkwxAgentID, err))
+			}
+			agentID = id
+		}
+		cfg, _ := c.WorkwxConfig(context.Background(), corpID, agentID)
+		if cfg == nil {
+			cfg = &WorkwxConfig{CorpID: corpID, AgentID: agentID}
+			c.Workwx = append(c.Workwx, cfg)
+		}
+		setFromEnv(&cfg.AgentSecret, EnvWorkwxAgentSecret)
+		setFromEnv(&cfg.Token, EnvWorkwxToken)
+		setFromEnv(&cfg.EncodingAESKey, EnvWorkwxEncodingAESKey)
+		setFromEnv(&cfg.BaseURL, EnvWorkwxBaseURL)
+	}
+
+	return errors.Join(errs...)
+}
+
+// setFromEnv 环境变量不为空时覆盖 dst
+func setFromEnv(dst *string, key string) {
+	if val := os.Getenv(key); val != "" {
+		*dst = val
+	}
+}
+
+// Validate 校验全部应用配置，返回全部错误
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Redis != nil {
+		if err := c.Redis.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("redis: %w", err))
+		}
+	}
+
+	appIDs := make(map[string]bool)
+	for i, cfg := range c.MiniPrograms {
+		if err := cfg.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("miniPrograms[%d]: %w", i, err))
+		}
+		if cfg.AppId != "" && appIDs[cfg.AppId] {
+			errs = append(errs, fmt.Errorf("miniPrograms[%d]: appId %s 重复", i, cfg.AppId))
+		}
+		appIDs[cfg.AppId] = true
+	}
+
+	appIDs = make(map[string]bool)
+	for i, cfg := range c.OfficialAccounts {
+		if err := cfg.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("officialAccounts[%d]: %w", i, err))
+		}
+		if cfg.AppId != "" && appIDs[cfg.AppId] {
+			errs = append(errs, fmt.Errorf("officialAccounts[%d]: appId %s 重复", i, cfg.AppId))
+		}
+		appIDs[cfg.AppId] = true
+	}
+
+	agents := make(map[workwxAgentKey]bool)
+	for i, cfg := range c.Workwx {
+		if err := cfg.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("workwx[%d]: %w", i, err))
+		}
+		key := workwxAgentKey{corpID: cfg.CorpID, agentID: cfg.AgentID}
+		if cfg.CorpID != "" && agents[key] {
+			errs = append(errs, fmt.Errorf("workwx[%d]: corpId %s agentId %d 重复", i, cfg.CorpID, cfg.AgentID))
+		}
+		agents[key] = true
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewRegistry 校验配置后创建共用缓存的多应用注册中心
+func (c *Config) NewRegistry() (*Registry, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return NewRegistry(NewCache(c.Redis), c), nil
+}
+
+// Validate 校验小程序配置，返回全部错误
+func (c *MiniProgramConfig) Validate() error {
+	var errs []error
+	if c.AppId == "" {
+		errs = append(errs, errors.New("appId 不能为空"))
+	}
+	if c.AppSecret == "" {
+		errs = append(errs, errors.New("appSecret 不能为空"))
+	}
+	switch c.EnvVersion {
+	case "", EnvVersionRelease, EnvVersionTrial, EnvVersionDevelop:
+	default:
+		errs = append(errs, fmt.Errorf("envVersion 必须为 release、trial 或 develop: %s", c.EnvVersion))
+	}
+	if c.ExpireInterval < 0 || c.ExpireInterval > 30 {
+		errs = append(errs, fmt.Errorf("expireInterval 必须在 0 到 30 天之间: %d", c.ExpireInterval))
+	}
+	errs = append(errs, validateCallbackKeys(c.Token, c.EncodingAESKey)...)
+	if c.Redis != nil {
+		if err := c.Redis.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("redis: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate 校验公众号配置，返回全部错误
+func (c *OfficialAccountConfig) Validate() error {
+	var errs []error
+	if c.AppId == "" {
+		errs = append(errs, errors.New("appId 不能为空"))
+	}
+	if c.AppSecret == "" {
+		errs = append(errs, errors.New("appSecret 不能为空"))
+	}
+	errs = append(errs, validateCallbackKeys(c.Token, c.EncodingAESKey)...)
+	if c.Redis != nil {
+		if err := c.Redis.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("redis: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate 校验企业微信应用配置，返回全部错误
+func (c *WorkwxConfig) Validate() error {
+	var errs []error
+	if c.CorpID == "" {
+		errs = append(errs, errors.New("corpId 不能为空"))
+	}
+	if c.AgentSecret == "" {
+		errs = append(errs, errors.New("agentSecret 不能为空"))
+	}
+	if c.AgentID < 0 {
+		errs = append(errs, fmt.Errorf("agentId 不能为负数: %d", c.AgentID))
+	}
+	errs = append(errs, validateCallbackKeys(c.Token, c.EncodingAESKey)...)
+	if c.Redis != nil {
+		if err := c.Redis.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("redis: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateCallbackKeys 校验回调 Token 和 EncodingAESKey
+func validateCallbackKeys(token, encodingAESKey string) []error {
+	var errs []error
+	if encodingAESKey != "" {
+		if len(encodingAESKey) != encodingAESKeyLength {
+			errs = append(errs, fmt.Errorf("encodingAESKey 长度必须为 %d: 实际为 %d", encodingAESKeyLength, len(encodingAESKey)))
+		}
+		if token == "" {
+			errs = append(errs, errors.New("配置 encodingAESKey 时 token 不能为空"))
+		}
+	}
+	return errs
+}
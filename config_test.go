@@ -0,0 +1,106 @@
+package wechat_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/darwinOrg/go-wechat"
+	"github.com/go-redis/redis/v8"
+)
+
+// TestLoadConfig 测试从 YAML、JSON 和环境变量加载配置
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "wechat.yaml")
+	_ = os.WriteFile(yamlPath, []byte(`
+redis:
+  addr: localhost:6379
+miniPrograms:
+  - appId: wx_mp_1
+    appSecret: secret_1
+    envVersion: trial
+    token: test_token
+    encodingAESKey: abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG
+workwx:
+  - corpId: test_corp_id
+    agentId: 1000001
+    agentSecret: test_secret
+`), 0o600)
+	t.Setenv(wechat.EnvMiniProgramAppID, "wx_mp_1")
+	t.Setenv(wechat.EnvMiniProgramEnvVersion, "develop")
+	t.Setenv(wechat.EnvRedisPassword, "redis_password")
+
+	cfg, err := wechat.LoadConfig(yamlPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(cfg.MiniPrograms) != 1 || cfg.MiniPrograms[0].EnvVersion != "develop" || cfg.Redis.Password != "redis_password" {
+		t.Fatalf("unexpected config: %+v %+v", cfg.MiniPrograms[0], cfg.Redis)
+	}
+	if workwxCfg, err := cfg.WorkwxConfig(context.Background(), "test_corp_id", 1000001); err != nil || workwxCfg.AgentSecret != "test_secret" {
+		t.Fatalf("WorkwxConfig failed: %+v, %v", workwxCfg, err)
+	}
+
+	// 全部校验错误一起返回
+	jsonPath := filepath.Join(dir, "wechat.json")
+	_ = os.WriteFile(jsonPath, []byte(`{
+		"miniPrograms": [{"appId": "wx_mp_2", "envVersion": "beta", "encodingAESKey": "short"}],
+		"workwx": [{"corpId": "test_corp_id"}]
+	}`), 0o600)
+	_, err = wechat.LoadConfig(jsonPath)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"appSecret 不能为空", "envVersion", "encodingAESKey 长度必须为 43", "token 不能为空", "workwx[0]: agentSecret 不能为空"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got: %v", want, err)
+		}
+	}
+
+	// 未知字段报错
+	_ = os.WriteFile(jsonPath, []byte(`{"miniPrograms": [{"appId": "wx_mp_3", "appKey": "key"}]}`), 0o600)
+	if _, err := wechat.LoadConfig(jsonPath); err == nil || !strings.Contains(err.Error(), "appKey") {
+		t.Fatalf("expected unknown field error, got %v", err)
+	}
+}
+
+// TestRedisConfig 测试 Redis 配置校验和不同模式的客户端创建
+func TestRedisConfig(t *testing.T) {
+	cluster := &wechat.RedisConfig{Addrs: []string{"10.0.0.1:6379", "10.0.0.2:6379"}, DB: 1, PoolSize: 20}
+	if err := cluster.Validate(); err == nil || !strings.Contains(err.Error(), "Cluster") {
+		t.Fatalf("expected cluster db error, got %v", err)
+	}
+	cluster.DB = 0
+	cluster.TLS = &wechat.RedisTLSConfig{}
+	clusterClient, ok := wechat.NewRedisClient(cluster).(*redis.ClusterClient)
+	if !ok || clusterClient.Options().TLSConfig.ServerName != "" {
+		t.Fatal("expected cluster client verifying each node's host")
+	}
+
+	single := &wechat.RedisConfig{Addr: "redis.example.com:6380", TLS: &wechat.RedisTLSConfig{}}
+	if client, ok := wechat.NewRedisClient(single).(*redis.Client); !ok || client.Options().TLSConfig.ServerName != "redis.example.com" {
+		t.Fatal("expected single node client with default server name")
+	}
+
+	sentinel := &wechat.RedisConfig{Addrs: []string{"10.0.0.1:26379"}, MasterName: "mymaster", DB: 2, TLS: &wechat.RedisTLSConfig{}}
+	if err := sentinel.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	client, ok := wechat.NewRedisClient(sentinel).(*redis.Client)
+	if !ok || client.Options().DB != 2 || client.Options().TLSConfig.ServerName != "" {
+		t.Fatalf("unexpected sentinel client: %+v", client)
+	}
+
+	// 直接构造的 Redis 缓存可正常调用，连接失败时返回空值
+	if val := wechat.NewCache(&wechat.RedisConfig{Addr: "127.0.0.1:1"}).Get("key"); val != nil {
+		t.Fatalf("unexpected cache value: %v", val)
+	}
+
+	if err := (&wechat.RedisConfig{MasterName: "mymaster", Cluster: true}).Validate(); err == nil {
+		t.Fatal("expected error for sentinel without addrs")
+	}
+}
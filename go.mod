@@ -3,8 +3,10 @@ module github.com/darwinOrg/go-wechat
 go 1.24.1
 
 require (
+	github.com/go-redis/redis/v8 v8.11.5
 	github.com/silenceper/wechat/v2 v2.1.11
 	github.com/xen0n/go-workwx/v2 v2.0.0-alpha.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -13,7 +15,6 @@ require (
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/fatih/structs v1.1.0 // indirect
-	github.com/go-redis/redis/v8 v8.11.5 // indirect
 	github.com/google/go-cmp v0.7.0 // indirect
 	github.com/sirupsen/logrus v1.9.3 // indirect
 	github.com/spf13/cast v1.10.0 // indirect
@@ -0,0 +1,297 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/silenceper/wechat/v2/cache"
+)
+
+// defaultIdentityTTL 身份映射默认缓存时长
+const defaultIdentityTTL = 30 * 24 * time.Hour
+
+// ErrIdentityNotFound 未找到身份映射
+var ErrIdentityNotFound = errors.New("身份映射不存在")
+
+// IdentityType 身份所属平台
+type IdentityType string
+
+// 身份所属平台
+const (
+	IdentityMiniProgram     IdentityType = "miniprogram"     // 小程序 openid，AppID 为小程序 appid
+	IdentityOfficialAccount IdentityType = "officialaccount" // 公众号 openid，AppID 为公众号 appid
+	IdentityWorkwx          IdentityType = "workwx"          // 企业微信客户 external_userid，AppID 为企业 corpid
+)
+
+// IdentityKey 用户在某个应用下的身份
+type IdentityKey struct {
+	Type  IdentityType `json:"type"`  // 所属平台
+	AppID string       `json:"appId"` // 应用 appid 或企业 corpid
+	ID    string       `json:"id"`    // openid 或 external_userid
+}
+
+// String 返回身份的唯一标识
+func (k IdentityKey) String() string {
+	return string(k.Type) + ":" + k.AppID + ":" + k.ID
+}
+
+// Identity 同一 unionid 下的全部身份
+type Identity struct {
+	UnionID  string        `json:"unionId"`  // 开放平台 unionid
+	Accounts []IdentityKey `json:"accounts"` // 各应用下的身份
+}
+
+// Find 查找指定应用下的身份ID
+func (i *Identity) Find(typ IdentityType, appID string) (string, bool) {
+	for _, account := range i.Accounts {
+		if account.Type == typ && account.AppID == appID {
+			return account.ID, true
+		}
+	}
+	return "", false
+}
+
+// merge 合并身份，同一应用下的身份以新值为准，返回被替换的旧身份
+func (i *Identity) merge(keys ...IdentityKey) []IdentityKey {
+	var replaced []IdentityKey
+	for _, key := range keys {
+		found := false
+		for idx, account := range i.Accounts {
+			if account.Type == key.Type && account.AppID == key.AppID {
+				found = true
+				if account.ID != key.ID {
+					replaced = append(replaced, account)
+					i.Accounts[idx].ID = key.ID
+				}
+				break
+			}
+		}
+		if !found {
+			i.Accounts = append(i.Accounts, key)
+		}
+	}
+	return replaced
+}
+
+// IdentityStore 身份映射存储
+type IdentityStore interface {
+	// Link 将身份关联到 unionid，返回关联后的全部身份
+	Link(ctx context.Context, unionID string, keys ...IdentityKey) (*Identity, error)
+	// Get 查询身份所属 unionid 下的全部身份，不存在时返回 ErrIdentityNotFound
+	Get(ctx context.Context, key IdentityKey) (*Identity, error)
+	// GetByUnionID 查询 unionid 下的全部身份，不存在时返回 ErrIdentityNotFound
+	GetByUnionID(ctx context.Context, unionID string) (*Identity, error)
+}
+
+// cacheIdentityStore 基于 cache.Cache 的身份映射存储
+type cacheIdentityStore struct {
+	cache cache.Cache
+	ttl   time.Duration
+	locks keyLocks // 同一 unionid 串行关联
+}
+
+// NewCacheIdentityStore 创建基于 cache.Cache 的身份映射存储
+// 缓存过期后可通过重新登录或接口查询恢复，需要长期保存时请实现 IdentityStore 接入数据库
+// Link 只在进程内按 unionid 加锁，多实例部署时并发关联同一 unionid 仍可能丢失身份
+// ttl 小于等于 0 时使用默认缓存时长
+func NewCacheIdentityStore(c cache.Cache, ttl time.Duration) IdentityStore {
+	if ttl <= 0 {
+		ttl = defaultIdentityTTL
+	}
+
+	return &cacheIdentityStore{cache: c, ttl: ttl}
+}
+
+// Link 将身份关联到 unionid
+func (s *cacheIdentityStore) Link(ctx context.Context, unionID string, keys ...IdentityKey) (*Identity, error) {
+	if unionID == "" {
+		return nil, errors.New("unionid 不能为空")
+	}
+
+	unlock := s.locks.lock(unionID)
+	defer unlock()
+
+	identity, err := s.GetByUnionID(ctx, unionID)
+	if errors.Is(err, ErrIdentityNotFound) {
+		identity = &Identity{UnionID: unionID}
+	} else if err != nil {
+		return nil, err
+	}
+
+	replaced := identity.merge(keys...)
+	if err := cacheSetJSON(ctx, s.cache, s.unionIDKey(unionID), identity, s.ttl); err != nil {
+		return nil, fmt.Errorf("保存身份映射失败: %w", err)
+	}
+	for _, account := range identity.Accounts {
+		if err := cache.SetContext(ctx, s.cache, s.accountKey(account), unionID, s.ttl); err != nil {
+			return nil, fmt.Errorf("保存身份映射失败: %w", err)
+		}
+	}
+	// 同一应用下的旧身份不再属于该 unionid，已关联到其他 unionid 时保留
+	for _, account := range replaced {
+		if current, _ := cache.GetContext(ctx, s.cache, s.accountKey(account)).(string); current != unionID {
+			continue
+		}
+		if err := cache.DeleteContext(ctx, s.cache, s.accountKey(account)); err != nil {
+			return nil, fmt.Errorf("删除旧身份映射失败: %w", err)
+		}
+	}
+
+	return identity, nil
+}
+
+// Get 查询身份所属 unionid 下的全部身份
+func (s *cacheIdentityStore) Get(ctx context.Context, key IdentityKey) (*Identity, error) {
+	unionID, ok := cache.GetContext(ctx, s.cache, s.accountKey(key)).(string)
+	if !ok || unionID == "" {
+		return nil, ErrIdentityNotFound
+	}
+
+	return s.GetByUnionID(ctx, unionID)
+}
+
+// GetByUnionID 查询 unionid 下的全部身份
+func (s *cacheIdentityStore) GetByUnionID(ctx context.Context, unionID string) (*Identity, error) {
+	var identity Identity
+	if !cacheGetJSON(ctx, s.cache, s.unionIDKey(unionID), &identity) {
+		return nil, ErrIdentityNotFound
+	}
+
+	return &identity, nil
+}
+
+// unionIDKey unionid 对应的缓存键
+func (s *cacheIdentityStore) unionIDKey(unionID string) string {
+	return "identity:unionid:" + unionID
+}
+
+// accountKey 身份对应的缓存键
+func (s *cacheIdentityStore) accountKey(key IdentityKey) string {
+	return "identity:account:" + key.String()
+}
+
+// IdentityResolver 用户身份解析器
+// 以 unionid 为纽带关联小程序、公众号和企业微信客户身份
+type IdentityResolver struct {
+	store        IdentityStore
+	workwxClient *WorkwxClient
+	payAppID     string // 企业绑定的微信支付 appid
+}
+
+// NewIdentityResolver 创建用户身份解析器
+// workwxClient 可为空，为空时无法通过接口查询企业微信客户身份
+func NewIdentityResolver(store IdentityStore, workwxClient *WorkwxClient) *IdentityResolver {
+	return &IdentityResolver{
+		store:        store,
+		workwxClient: workwxClient,
+	}
+}
+
+// GetStore 获取身份映射存储
+func (r *IdentityResolver) GetStore() IdentityStore {
+	return r.store
+}
+
+// SetPayAppID 设置企业绑定微信支付使用的小程序 appid
+// convert_to_openid 只能返回该 appid 下的 openid，设置后 ResolveMiniProgramOpenID 查询该小程序且没有记录时调用接口转换
+func (r *IdentityResolver) SetPayAppID(appID string) {
+	r.payAppID = appID
+}
+
+// RecordMiniProgramLogin 记录小程序登录返回的 openid 和 unionid
+// 未返回 unionid 时（小程序未绑定开放平台）不做记录
+func (r *IdentityResolver) RecordMiniProgramLogin(ctx context.Context, appID string, result *MiniProgramLoginResult) error {
+	if result == nil || result.UnionID == "" {
+		return nil
+	}
+
+	_, err := r.store.Link(ctx, result.UnionID, IdentityKey{Type: IdentityMiniProgram, AppID: appID, ID: result.OpenID})
+	return err
+}
+
+// RecordOfficialAccountUser 记录公众号用户的 openid 和 unionid
+// unionid 可通过 OfficialAccountClient.GetUserInfo 获取
+func (r *IdentityResolver) RecordOfficialAccountUser(ctx context.Context, appID, openID, unionID string) error {
+	if unionID == "" {
+		return nil
+	}
+
+	_, err := r.store.Link(ctx, unionID, IdentityKey{Type: IdentityOfficialAccount, AppID: appID, ID: openID})
+	return err
+}
+
+// Resolve 查询身份所属 unionid 下的全部身份
+func (r *IdentityResolver) Resolve(ctx context.Context, key IdentityKey) (*Identity, error) {
+	return r.store.Get(ctx, key)
+}
+
+// ResolveExternalUserID 查询用户对应的企业微信客户 external_userid
+// 优先使用已记录的映射，没有记录时调用 unionid_to_external_userid 查询并记录
+// 用户不是企业客户时返回 ErrIdentityNotFound
+func (r *IdentityResolver) ResolveExternalUserID(ctx context.Context, key IdentityKey) (string, error) {
+	if key.Type == IdentityWorkwx {
+		return key.ID, nil
+	}
+	if r.workwxClient == nil {
+		return "", errors.New("未配置企业微信客户端")
+	}
+	corpID := r.workwxClient.config.CorpID
+
+	identity, err := r.store.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if externalUserID, ok := identity.Find(IdentityWorkwx, corpID); ok {
+		return externalUserID, nil
+	}
+
+	externalUserID, err := r.workwxClient.UnionIDToExternalUserID(ctx, identity.UnionID, key.ID)
+	if err != nil {
+		return "", err
+	}
+	if externalUserID == "" {
+		return "", ErrIdentityNotFound
+	}
+
+	if _, err := r.store.Link(ctx, identity.UnionID, IdentityKey{Type: IdentityWorkwx, AppID: corpID, ID: externalUserID}); err != nil {
+		return "", err
+	}
+
+	return externalUserID, nil
+}
+
+// ResolveMiniProgramOpenID 查询企业微信客户在指定小程序下的 openid
+// 优先使用已记录的映射，没有记录且 appID 为 SetPayAppID 设置的 appid 时调用 convert_to_openid 转换
+// 转换结果在客户已关联 unionid 时记录，未关联时只返回不记录
+func (r *IdentityResolver) ResolveMiniProgramOpenID(ctx context.Context, corpID, externalUserID, appID string) (string, error) {
+	identity, err := r.store.Get(ctx, IdentityKey{Type: IdentityWorkwx, AppID: corpID, ID: externalUserID})
+	if err != nil && !errors.Is(err, ErrIdentityNotFound) {
+		return "", err
+	}
+	if identity != nil {
+		if openID, ok := identity.Find(IdentityMiniProgram, appID); ok {
+			return openID, nil
+		}
+	}
+
+	if r.workwxClient == nil || r.workwxClient.config.CorpID != corpID || r.payAppID == "" || r.payAppID != appID {
+		return "", ErrIdentityNotFound
+	}
+	openID, err := r.workwxClient.ConvertToOpenID(ctx, externalUserID)
+	if err != nil {
+		return "", err
+	}
+	if openID == "" {
+		return "", ErrIdentityNotFound
+	}
+
+	if identity != nil {
+		if _, err := r.store.Link(ctx, identity.UnionID, IdentityKey{Type: IdentityMiniProgram, AppID: appID, ID: openID}); err != nil {
+			return "", err
+		}
+	}
+
+	return openID, nil
+}
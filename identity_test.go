@@ -0,0 +1,154 @@
+package wechat_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/darwinOrg/go-wechat"
+	"github.com/silenceper/wechat/v2/cache"
+)
+
+// newMockWorkwxClient 创建连接到模拟接口的企业微信客户端
+func newMockWorkwxClient(t *testing.T, mux *http.ServeMux) *wechat.WorkwxClient {
+	mux.HandleFunc("/cgi-bin/gettoken", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "access_token": "mock_token", "expires_in": 7200})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return wechat.NewWorkwxClient(&wechat.WorkwxConfig{
+		CorpID:      "test_corp_id",
+		AgentID:     1000001,
+		AgentSecret: "test_secret",
+		BaseURL:     server.URL,
+	})
+}
+
+// TestIdentityResolver 测试小程序用户解析为企业微信客户
+func TestIdentityResolver(t *testing.T) {
+	calls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgi-bin/externalcontact/unionid_to_external_userid", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Query().Get("access_token") != "mock_token" || req["unionid"] != "test_unionid" || req["openid"] != "mp_openid" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 40003, "errmsg": "invalid openid"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "external_userid": "wm_external_1"})
+	})
+	convertCalls := 0
+	mux.HandleFunc("/cgi-bin/externalcontact/convert_to_openid", func(w http.ResponseWriter, r *http.Request) {
+		convertCalls++
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "openid": "pay_openid_" + req["external_userid"]})
+	})
+	workwxClient := newMockWorkwxClient(t, mux)
+	resolver := wechat.NewIdentityResolver(wechat.NewCacheIdentityStore(cache.NewMemory(), 0), workwxClient)
+	ctx := context.Background()
+
+	if err := resolver.RecordMiniProgramLogin(ctx, "wx_mp", &wechat.MiniProgramLoginResult{OpenID: "mp_openid", UnionID: "test_unionid"}); err != nil {
+		t.Fatalf("RecordMiniProgramLogin failed: %v", err)
+	}
+	if err := resolver.RecordOfficialAccountUser(ctx, "wx_oa", "oa_openid", "test_unionid"); err != nil {
+		t.Fatalf("RecordOfficialAccountUser failed: %v", err)
+	}
+
+	mpUser := wechat.IdentityKey{Type: wechat.IdentityMiniProgram, AppID: "wx_mp", ID: "mp_openid"}
+	for i := 0; i < 2; i++ {
+		externalUserID, err := resolver.ResolveExternalUserID(ctx, mpUser)
+		if err != nil || externalUserID != "wm_external_1" {
+			t.Fatalf("ResolveExternalUserID failed: %s, %v", externalUserID, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected resolved mapping to be recorded, got %d calls", calls)
+	}
+
+	identity, err := resolver.Resolve(ctx, wechat.IdentityKey{Type: wechat.IdentityOfficialAccount, AppID: "wx_oa", ID: "oa_openid"})
+	if err != nil || identity.UnionID != "test_unionid" || len(identity.Accounts) != 3 {
+		t.Fatalf("Resolve failed: %+v, %v", identity, err)
+	}
+	openID, err := resolver.ResolveMiniProgramOpenID(ctx, "test_corp_id", "wm_external_1", "wx_mp")
+	if err != nil || openID != "mp_openid" {
+		t.Fatalf("ResolveMiniProgramOpenID failed: %s, %v", openID, err)
+	}
+
+	_, err = resolver.ResolveExternalUserID(ctx, wechat.IdentityKey{Type: wechat.IdentityMiniProgram, AppID: "wx_mp", ID: "unknown"})
+	if !errors.Is(err, wechat.ErrIdentityNotFound) {
+		t.Fatalf("expected ErrIdentityNotFound, got %v", err)
+	}
+
+	// 没有记录时通过 convert_to_openid 转换并记录
+	if _, err := resolver.ResolveMiniProgramOpenID(ctx, "test_corp_id", "wm_external_1", "wx_pay_mp"); !errors.Is(err, wechat.ErrIdentityNotFound) {
+		t.Fatalf("expected ErrIdentityNotFound before SetPayAppID, got %v", err)
+	}
+	resolver.SetPayAppID("wx_pay_mp")
+	for i := 0; i < 2; i++ {
+		openID, err = resolver.ResolveMiniProgramOpenID(ctx, "test_corp_id", "wm_external_1", "wx_pay_mp")
+		if err != nil || openID != "pay_openid_wm_external_1" {
+			t.Fatalf("ResolveMiniProgramOpenID with convert failed: %s, %v", openID, err)
+		}
+	}
+	if convertCalls != 1 {
+		t.Fatalf("expected converted openid to be recorded, got %d calls", convertCalls)
+	}
+	if identity, _ := resolver.Resolve(ctx, mpUser); len(identity.Accounts) != 4 {
+		t.Fatalf("converted openid not linked: %+v", identity)
+	}
+}
+
+// TestCacheIdentityStore_ConcurrentLink 测试并发关联同一 unionid 不丢失身份
+func TestCacheIdentityStore_ConcurrentLink(t *testing.T) {
+	store := wechat.NewCacheIdentityStore(cache.NewMemory(), 0)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := wechat.IdentityKey{Type: wechat.IdentityMiniProgram, AppID: fmt.Sprintf("wx_mp_%d", i), ID: "openid"}
+			if _, err := store.Link(ctx, "test_unionid", key); err != nil {
+				t.Errorf("Link failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	identity, err := store.GetByUnionID(ctx, "test_unionid")
+	if err != nil || len(identity.Accounts) != 20 {
+		t.Fatalf("expected 20 linked accounts, got %+v, %v", identity, err)
+	}
+}
+
+// TestCacheIdentityStore_ReplaceAccount 测试同一应用下的身份被替换后，旧身份不再能查到 unionid
+func TestCacheIdentityStore_ReplaceAccount(t *testing.T) {
+	store := wechat.NewCacheIdentityStore(cache.NewMemory(), 0)
+	ctx := context.Background()
+
+	oldKey := wechat.IdentityKey{Type: wechat.IdentityWorkwx, AppID: "corp_1", ID: "old_external_userid"}
+	newKey := wechat.IdentityKey{Type: wechat.IdentityWorkwx, AppID: "corp_1", ID: "new_external_userid"}
+	if _, err := store.Link(ctx, "test_unionid", oldKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Link(ctx, "test_unionid", newKey); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Get(ctx, oldKey); !errors.Is(err, wechat.ErrIdentityNotFound) {
+		t.Fatalf("expected ErrIdentityNotFound for replaced account, got %v", err)
+	}
+	identity, err := store.Get(ctx, newKey)
+	if err != nil || len(identity.Accounts) != 1 || identity.Accounts[0].ID != "new_external_userid" {
+		t.Fatalf("unexpected identity: %+v, %v", identity, err)
+	}
+}
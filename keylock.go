@@ -0,0 +1,24 @@
+package wechat
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// keyLockStripes 按键加锁的分段数量
+const keyLockStripes = 256
+
+// keyLocks 按键串行执行的锁，键经哈希后映射到固定数量的锁，内存占用不随键的数量增长
+// 不同键可能共用同一把锁，持有锁期间不能再锁定同一 keyLocks 中的其他键
+type keyLocks struct {
+	stripes [keyLockStripes]sync.Mutex
+}
+
+// lock 锁定键，返回解锁函数
+func (l *keyLocks) lock(key string) func() {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	mu := &l.stripes[h.Sum32()%keyLockStripes]
+	mu.Lock()
+	return mu.Unlock
+}
@@ -2,7 +2,8 @@ package wechat
 
 import (
 	"context"
-	"os"
+	"fmt"
+	"sync/atomic"
 
 	"github.com/silenceper/wechat/v2"
 	"github.com/silenceper/wechat/v2/cache"
@@ -11,46 +12,80 @@ import (
 	"github.com/silenceper/wechat/v2/miniprogram/config"
 	"github.com/silenceper/wechat/v2/miniprogram/qrcode"
 	"github.com/silenceper/wechat/v2/miniprogram/urllink"
+	"github.com/silenceper/wechat/v2/miniprogram/urlscheme"
+	"github.com/silenceper/wechat/v2/util"
 )
 
 type MiniProgramConfig struct {
-	AppId          string
-	AppSecret      string
-	ExpireInterval int
-	RedisAddr      string
-	EnvVersion     string // 小程序版本：正式版为"release"，体验版为"trial"，开发版为"develop"
+	AppId          string       `json:"appId" yaml:"appId" mapstructure:"appId"`
+	AppSecret      string       `json:"appSecret" yaml:"appSecret" mapstructure:"appSecret"`
+	ExpireInterval int          `json:"expireInterval" yaml:"expireInterval" mapstructure:"expireInterval"`
+	RedisAddr      string       `json:"redisAddr" yaml:"redisAddr" mapstructure:"redisAddr"`
+	Redis          *RedisConfig `json:"redis" yaml:"redis" mapstructure:"redis"`                            // Redis 连接配置，可选，相同配置的客户端共用连接池
+	EnvVersion     string       `json:"envVersion" yaml:"envVersion" mapstructure:"envVersion"`             // 小程序版本：正式版为"release"，体验版为"trial"，开发版为"develop"
+	Token          string       `json:"token" yaml:"token" mapstructure:"token"`                            // 消息推送 Token
+	EncodingAESKey string       `json:"encodingAESKey" yaml:"encodingAESKey" mapstructure:"encodingAESKey"` // 消息推送加密密钥，明文模式可不填
 }
 
 type MiniProgramClient struct {
-	miniProgramIns *miniprogram.MiniProgram
-	config         *MiniProgramConfig
+	miniProgramIns     *miniprogram.MiniProgram
+	config             *MiniProgramConfig
+	cache              cache.Cache
+	sessionKeyStore    SessionKeyStore
+	subscribeTemplates *SubscribeTemplateRegistry
+	sceneCodec         *SceneCodec
+	mediaCheckTraces   MediaCheckTraceStore
+	artifactCache      atomic.Pointer[artifactCacheSetting]
 }
 
 func NewMiniProgramClient(cfg *MiniProgramConfig) *MiniProgramClient {
+	return NewMiniProgramClientWithCache(cfg, newCache(cfg.Redis, cfg.RedisAddr))
+}
+
+// NewMiniProgramClientWithCache 使用指定缓存创建小程序客户端，忽略 RedisAddr 配置
+// 多个客户端可共用同一个缓存连接池
+func NewMiniProgramClientWithCache(cfg *MiniProgramConfig, c cache.Cache) *MiniProgramClient {
 	miniCfg := &config.Config{
-		AppID:     cfg.AppId,
-		AppSecret: cfg.AppSecret,
-	}
-	if cfg.RedisAddr != "" {
-		miniCfg.Cache = cache.NewRedis(context.Background(), &cache.RedisOpts{
-			Host:     cfg.RedisAddr,
-			Username: os.Getenv("REDIS_USERNAME"),
-			Password: os.Getenv("REDIS_PASSWORD"),
-		})
-	} else {
-		miniCfg.Cache = cache.NewMemory()
+		AppID:          cfg.AppId,
+		AppSecret:      cfg.AppSecret,
+		Token:          cfg.Token,
+		EncodingAESKey: cfg.EncodingAESKey,
 	}
+	miniCfg.Cache = c
 
 	wx := wechat.NewWechat()
 	miniProgramIns := wx.GetMiniProgram(miniCfg)
 
 	stableAccessTokenHandle := credential.NewStableAccessToken(cfg.AppId, cfg.AppSecret, credential.CacheKeyMiniProgramPrefix, miniCfg.Cache)
-	miniProgramIns.SetAccessTokenHandle(stableAccessTokenHandle)
+	miniProgramIns.SetAccessTokenContextHandle(stableAccessTokenHandle)
+
+	return &MiniProgramClient{
+		miniProgramIns:     miniProgramIns,
+		config:             cfg,
+		cache:              miniCfg.Cache,
+		sessionKeyStore:    NewCacheSessionKeyStore(miniCfg.Cache, cfg.AppId, defaultSessionKeyTTL),
+		subscribeTemplates: NewSubscribeTemplateRegistry(),
+		sceneCodec:         NewSceneCodec(NewCacheSceneStore(miniCfg.Cache, cfg.AppId), defaultSceneTTL),
+		mediaCheckTraces:   NewCacheMediaCheckTraceStore(miniCfg.Cache, cfg.AppId, defaultMediaCheckTraceTTL),
+	}
+}
 
-	return &MiniProgramClient{miniProgramIns: miniProgramIns, config: cfg}
+// GetMiniProgram 获取小程序实例，用于直接调用SDK方法
+func (c *MiniProgramClient) GetMiniProgram() *miniprogram.MiniProgram {
+	return c.miniProgramIns
+}
+
+// GetConfig 获取配置
+func (c *MiniProgramClient) GetConfig() *MiniProgramConfig {
+	return c.config
 }
 
 func (c *MiniProgramClient) GenerateUrlLink(path, query string, expireTime int64) (string, error) {
+	return c.GenerateUrlLinkContext(context.Background(), path, query, expireTime)
+}
+
+// GenerateUrlLinkContext 与 GenerateUrlLink 相同，ctx 用于读写生成结果缓存
+func (c *MiniProgramClient) GenerateUrlLinkContext(ctx context.Context, path, query string, expireTime int64) (string, error) {
 	ulParams := &urllink.ULParams{
 		EnvVersion: c.config.EnvVersion,
 	}
@@ -68,23 +103,104 @@ func (c *MiniProgramClient) GenerateUrlLink(path, query string, expireTime int64
 		ulParams.ExpireInterval = c.config.ExpireInterval
 	}
 
-	return c.miniProgramIns.GetURLLink().Generate(ulParams)
+	return c.cachedLink(ctx, "urllink", linkTTL(expireTime, c.config.ExpireInterval), func() (string, error) {
+		return c.miniProgramIns.GetURLLink().Generate(ulParams)
+	}, ulParams)
+}
+
+func (c *MiniProgramClient) GenerateScheme(path, query string, expireTime int64) (string, error) {
+	usParams := &urlscheme.USParams{
+		JumpWxa: &urlscheme.JumpWxa{
+			Path:       path,
+			Query:      query,
+			EnvVersion: urlscheme.EnvVersion(c.config.EnvVersion),
+		},
+	}
+	if expireTime > 0 {
+		usParams.ExpireType = urlscheme.ExpireTypeTime
+		usParams.ExpireTime = expireTime
+	} else {
+		usParams.ExpireType = urlscheme.ExpireTypeInterval
+		usParams.ExpireInterval = c.config.ExpireInterval
+	}
+	usParams.IsExpire = true
+
+	return c.miniProgramIns.GetSURLScheme().Generate(usParams)
+}
+
+func (c *MiniProgramClient) GenerateNFCScheme(path, query, modelID, sn string) (string, error) {
+	return c.miniProgramIns.GetSURLScheme().GenerateNFC(&urlscheme.USParams{
+		JumpWxa: &urlscheme.JumpWxa{
+			Path:       path,
+			Query:      query,
+			EnvVersion: urlscheme.EnvVersion(c.config.EnvVersion),
+		},
+		ModelID: modelID,
+		Sn:      sn,
+	})
+}
+
+func (c *MiniProgramClient) QueryScheme(scheme string) (*urlscheme.ResQueryScheme, error) {
+	return c.miniProgramIns.GetSURLScheme().QuerySchemeWithRes(urlscheme.QueryScheme{Scheme: scheme})
+}
+
+func (c *MiniProgramClient) QueryUrlLink(urlLink string) (*urllink.ULQueryResult, error) {
+	return c.miniProgramIns.GetURLLink().Query(urlLink)
 }
 
 func (c *MiniProgramClient) GenerateShortLink(pageUrl, pageTitle string, permanent bool) (string, error) {
+	return c.GenerateShortLinkContext(context.Background(), pageUrl, pageTitle, permanent)
+}
+
+// GenerateShortLinkContext 与 GenerateShortLink 相同，ctx 用于读写生成结果缓存
+func (c *MiniProgramClient) GenerateShortLinkContext(ctx context.Context, pageUrl, pageTitle string, permanent bool) (string, error) {
 	if permanent {
-		return c.miniProgramIns.GetShortLink().GenerateShortLinkPermanent(pageUrl, pageTitle)
+		return c.cachedLink(ctx, "shortlink", artifactPermanent, func() (string, error) {
+			return c.miniProgramIns.GetShortLink().GenerateShortLinkPermanent(pageUrl, pageTitle)
+		}, pageUrl, pageTitle, permanent)
 	}
 
-	return c.miniProgramIns.GetShortLink().GenerateShortLinkTemp(pageUrl, pageTitle)
+	return c.cachedLink(ctx, "shortlink", intervalLinkTTL(shortLinkTempValidity), func() (string, error) {
+		return c.miniProgramIns.GetShortLink().GenerateShortLinkTemp(pageUrl, pageTitle)
+	}, pageUrl, pageTitle, permanent)
 }
 
 func (c *MiniProgramClient) GetWXACodeUnlimit(page, scene string, checkPath bool) ([]byte, error) {
-	return c.miniProgramIns.GetQRCode().GetWXACodeUnlimit(qrcode.QRCoder{
+	return c.GetWXACodeUnlimitContext(context.Background(), page, scene, checkPath)
+}
+
+// GetWXACodeUnlimitContext 与 GetWXACodeUnlimit 相同，ctx 用于读写生成结果缓存
+func (c *MiniProgramClient) GetWXACodeUnlimitContext(ctx context.Context, page, scene string, checkPath bool) ([]byte, error) {
+	coder := qrcode.QRCoder{
 		Page:       page,
 		Path:       page,
 		Scene:      scene,
 		CheckPath:  &checkPath,
 		EnvVersion: c.config.EnvVersion,
-	})
+	}
+
+	return c.cachedArtifact(ctx, "wxacode", artifactPermanent, func() ([]byte, error) {
+		return c.fetchWXACode(getWXACodeUnlimitURL, coder)
+	}, coder)
+}
+
+// commonResponse 仅包含错误码的通用响应
+type commonResponse struct {
+	util.CommonError
+}
+
+// postJSON 携带 access_token 调用小程序 JSON 接口并解析响应
+// urlFormat 中的 %s 会被替换为 access_token，resp 需内嵌 util.CommonError
+func (c *MiniProgramClient) postJSON(ctx context.Context, urlFormat string, req, resp any, apiName string) error {
+	accessToken, err := c.miniProgramIns.GetContext().GetAccessTokenContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	response, err := util.PostJSONContext(ctx, fmt.Sprintf(urlFormat, accessToken), req)
+	if err != nil {
+		return err
+	}
+
+	return util.DecodeWithError(response, resp, apiName)
 }
@@ -0,0 +1,222 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/silenceper/wechat/v2/cache"
+)
+
+const (
+	// defaultArtifactTTL 小程序码等永久有效结果的默认缓存时长
+	defaultArtifactTTL = 7 * 24 * time.Hour
+	// artifactExpireMargin 链接到期前提前失效的时间，避免返回即将过期的链接
+	artifactExpireMargin = time.Hour
+	// shortLinkTempValidity 临时 Short Link 的有效期
+	shortLinkTempValidity = 30 * 24 * time.Hour
+	// intervalLinkCacheDivisor 按天数计算有效期的链接最多缓存有效期的 1/10，保证返回的链接至少剩余 90% 的有效期
+	intervalLinkCacheDivisor = 10
+	// artifactPermanent 永久有效的结果，缓存时长只受缓存时长上限限制
+	artifactPermanent time.Duration = math.MaxInt64
+)
+
+// artifactCacheSetting 生成结果缓存设置，开启和关闭时整体替换，避免与请求并发读写
+type artifactCacheSetting struct {
+	store ArtifactCache
+	ttl   time.Duration
+}
+
+// ArtifactCache 小程序码、链接等生成结果的缓存
+type ArtifactCache interface {
+	// Get 获取缓存，不存在或已过期时返回 false
+	Get(ctx context.Context, key string) ([]byte, bool)
+	// Set 写入缓存
+	Set(ctx context.Context, key string, val []byte, ttl time.Duration) error
+}
+
+// cacheArtifactCache 基于 cache.Cache 的生成结果缓存
+type cacheArtifactCache struct {
+	cache     cache.Cache
+	keyPrefix string
+}
+
+// NewCacheArtifactCache 创建基于 cache.Cache 的生成结果缓存，可复用客户端的 Redis 缓存
+func NewCacheArtifactCache(c cache.Cache) ArtifactCache {
+	return &cacheArtifactCache{
+		cache:     c,
+		keyPrefix: "miniprogram:artifact:",
+	}
+}
+
+// NewMemoryArtifactCache 创建内存生成结果缓存
+func NewMemoryArtifactCache() ArtifactCache {
+	return NewCacheArtifactCache(cache.NewMemory())
+}
+
+// Get 获取缓存
+func (s *cacheArtifactCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	// Redis 缓存返回 string，内存缓存返回写入时的 []byte
+	switch val := cache.GetContext(ctx, s.cache, s.keyPrefix+key).(type) {
+	case []byte:
+		return val, len(val) > 0
+	case string:
+		return []byte(val), val != ""
+	default:
+		return nil, false
+	}
+}
+
+// Set 写入缓存
+func (s *cacheArtifactCache) Set(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	return cache.SetContext(ctx, s.cache, s.keyPrefix+key, val, ttl)
+}
+
+// fileArtifactCache 基于本地文件系统的生成结果缓存
+// 文件内容为 8 字节的过期时间（Unix 纳秒，大端序）加原始数据
+type fileArtifactCache struct {
+	dir string
+}
+
+// NewFileArtifactCache 创建基于本地文件系统的生成结果缓存
+func NewFileArtifactCache(dir string) (ArtifactCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+
+	return &fileArtifactCache{dir: dir}, nil
+}
+
+// Get 获取缓存
+func (s *fileArtifactCache) Get(_ context.Context, key string) ([]byte, bool) {
+	path := filepath.Join(s.dir, key)
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) <= 8 {
+		return nil, false
+	}
+
+	expireAt := time.Unix(0, int64(binary.BigEndian.Uint64(data[:8])))
+	if time.Now().After(expireAt) {
+		_ = os.Remove(path)
+		return nil, false
+	}
+
+	return data[8:], true
+}
+
+// Set 写入缓存，先写临时文件再重命名，避免并发读到不完整的数据
+func (s *fileArtifactCache) Set(_ context.Context, key string, val []byte, ttl time.Duration) error {
+	var buf bytes.Buffer
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint64(header, uint64(time.Now().Add(ttl).UnixNano()))
+	buf.Write(header)
+	buf.Write(val)
+
+	tmp, err := os.CreateTemp(s.dir, key+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, key))
+}
+
+// EnableArtifactCache 开启生成结果缓存
+// 可在请求处理过程中随时开启、关闭或替换，已开始的请求继续使用原缓存
+// 开启后 GetWXACodeUnlimit、GenerateUrlLink、GenerateShortLink 对相同参数直接返回缓存结果
+// ttl 为缓存时长上限，小于等于 0 时使用默认值 7 天；有过期时间的链接会在到期前失效
+// 按天数计算有效期的链接最多缓存有效期的 1/10，避免返回剩余有效期远短于请求的链接
+func (c *MiniProgramClient) EnableArtifactCache(store ArtifactCache, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = defaultArtifactTTL
+	}
+
+	c.artifactCache.Store(&artifactCacheSetting{store: store, ttl: ttl})
+}
+
+// DisableArtifactCache 关闭生成结果缓存
+func (c *MiniProgramClient) DisableArtifactCache() {
+	c.artifactCache.Store(nil)
+}
+
+// cachedArtifact 优先从缓存获取结果，未命中时调用 generate 生成并写入缓存
+// kind 和 params 组成规范化的缓存 key；ttl 小于等于 0 时不缓存
+func (c *MiniProgramClient) cachedArtifact(ctx context.Context, kind string, ttl time.Duration, generate func() ([]byte, error), params ...any) ([]byte, error) {
+	setting := c.artifactCache.Load()
+	if setting == nil {
+		return generate()
+	}
+
+	key, err := artifactKey(c.config.AppId, kind, params...)
+	if err != nil {
+		return nil, err
+	}
+	if val, ok := setting.store.Get(ctx, key); ok {
+		return val, nil
+	}
+
+	val, err := generate()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl = min(ttl, setting.ttl)
+	if ttl > 0 {
+		// 写缓存失败不影响返回结果
+		_ = setting.store.Set(ctx, key, val, ttl)
+	}
+
+	return val, nil
+}
+
+// cachedLink 与 cachedArtifact 相同，用于返回字符串的链接
+func (c *MiniProgramClient) cachedLink(ctx context.Context, kind string, ttl time.Duration, generate func() (string, error), params ...any) (string, error) {
+	val, err := c.cachedArtifact(ctx, kind, ttl, func() ([]byte, error) {
+		link, err := generate()
+		return []byte(link), err
+	}, params...)
+
+	return string(val), err
+}
+
+// artifactKey 根据规范化的请求参数生成内容寻址的缓存 key
+func artifactKey(appID, kind string, params ...any) (string, error) {
+	data, err := json.Marshal(append([]any{appID, kind}, params...))
+	if err != nil {
+		return "", fmt.Errorf("生成缓存 key 失败: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	return kind + "-" + hex.EncodeToString(sum[:]), nil
+}
+
+// linkTTL 计算链接的缓存时长，expireTime 为 Unix 时间戳，为 0 时使用 expireInterval 天数
+// 指定到期时间的链接缓存到到期前；按天数计算有效期的链接有效期从生成时开始计算，只缓存有效期的一小部分
+func linkTTL(expireTime int64, expireInterval int) time.Duration {
+	if expireTime > 0 {
+		return time.Until(time.Unix(expireTime, 0)) - artifactExpireMargin
+	}
+
+	return intervalLinkTTL(time.Duration(expireInterval) * 24 * time.Hour)
+}
+
+// intervalLinkTTL 计算按有效期生成的链接的缓存时长
+func intervalLinkTTL(validity time.Duration) time.Duration {
+	return validity / intervalLinkCacheDivisor
+}
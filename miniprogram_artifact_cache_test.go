@@ -0,0 +1,130 @@
+package wechat_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/darwinOrg/go-wechat"
+)
+
+// TestMiniProgramClient_ArtifactCache 测试相同参数的链接和小程序码只生成一次
+func TestMiniProgramClient_ArtifactCache(t *testing.T) {
+	var linkCalls int
+	var codeCalls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wxa/generate_urllink", func(w http.ResponseWriter, r *http.Request) {
+		linkCalls++
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "url_link": "https://wxaurl.cn/test"})
+	})
+	mux.HandleFunc("/wxa/getwxacodeunlimit", func(w http.ResponseWriter, r *http.Request) {
+		codeCalls.Add(1)
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("\x89PNG"))
+	})
+	miniClient := newMockMiniProgramClient(t, mux)
+
+	fileCache, err := wechat.NewFileArtifactCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	miniClient.EnableArtifactCache(fileCache, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		link, err := miniClient.GenerateUrlLink("pages/index", "a=1", 0)
+		if err != nil || link != "https://wxaurl.cn/test" {
+			t.Fatalf("GenerateUrlLink failed: %s, %v", link, err)
+		}
+		code, err := miniClient.GetWXACodeUnlimit("pages/index", "a=1", false)
+		if err != nil || string(code) != "\x89PNG" {
+			t.Fatalf("GetWXACodeUnlimit failed: %q, %v", code, err)
+		}
+	}
+	if linkCalls != 1 || codeCalls.Load() != 1 {
+		t.Fatalf("expected one call each, got link=%d code=%d", linkCalls, codeCalls.Load())
+	}
+
+	// 即将过期的链接不缓存
+	miniClient.EnableArtifactCache(wechat.NewMemoryArtifactCache(), time.Hour)
+	expireTime := time.Now().Add(30 * time.Minute).Unix()
+	for i := 0; i < 2; i++ {
+		if _, err := miniClient.GenerateUrlLink("pages/index", "a=1", expireTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if linkCalls != 3 {
+		t.Fatalf("expiring link should not be cached, calls=%d", linkCalls)
+	}
+
+	// 请求过程中切换缓存不产生数据竞争
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = miniClient.GetWXACodeUnlimit("pages/index", "a=1", false)
+		}()
+	}
+	miniClient.DisableArtifactCache()
+	miniClient.EnableArtifactCache(wechat.NewMemoryArtifactCache(), time.Hour)
+	wg.Wait()
+}
+
+// recordingArtifactCache 记录写入参数的生成结果缓存
+type recordingArtifactCache struct {
+	wechat.ArtifactCache
+	mu   sync.Mutex
+	ttls []time.Duration
+	ctxs []context.Context
+}
+
+func (s *recordingArtifactCache) Set(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	s.ttls = append(s.ttls, ttl)
+	s.ctxs = append(s.ctxs, ctx)
+	s.mu.Unlock()
+	return s.ArtifactCache.Set(ctx, key, val, ttl)
+}
+
+// TestMiniProgramClient_ArtifactCacheLinkTTL 测试按天数计算有效期的链接只缓存有效期的一小部分，且使用调用方的 ctx
+func TestMiniProgramClient_ArtifactCacheLinkTTL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wxa/generate_urllink", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "url_link": "https://wxaurl.cn/test"})
+	})
+	miniClient := newMockMiniProgramClient(t, mux)
+
+	store := &recordingArtifactCache{ArtifactCache: wechat.NewMemoryArtifactCache()}
+	miniClient.EnableArtifactCache(store, 30*24*time.Hour)
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "caller")
+	if _, err := miniClient.GenerateUrlLinkContext(ctx, "pages/index", "a=1", 0); err != nil {
+		t.Fatal(err)
+	}
+	expireTime := time.Now().Add(10 * 24 * time.Hour).Unix()
+	if _, err := miniClient.GenerateUrlLinkContext(ctx, "pages/index", "a=1", expireTime); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.ttls) != 2 {
+		t.Fatalf("expected 2 cache writes, got %d", len(store.ttls))
+	}
+	// ExpireInterval 为 30 天，最多缓存 3 天
+	if store.ttls[0] > 3*24*time.Hour {
+		t.Fatalf("interval link cached too long: %s", store.ttls[0])
+	}
+	// 指定到期时间的链接缓存到到期前
+	if store.ttls[1] < 9*24*time.Hour || store.ttls[1] > 10*24*time.Hour {
+		t.Fatalf("unexpected ttl for link with expire time: %s", store.ttls[1])
+	}
+	for _, c := range store.ctxs {
+		if c.Value(ctxKey{}) != "caller" {
+			t.Fatal("artifact cache should receive the caller's ctx")
+		}
+	}
+}
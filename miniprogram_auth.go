@@ -0,0 +1,132 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/silenceper/wechat/v2/cache"
+	"github.com/silenceper/wechat/v2/miniprogram/business"
+)
+
+// defaultSessionKeyTTL session_key 默认缓存时长
+// 微信未公布 session_key 的有效期，用户重新登录后旧的 session_key 即失效
+const defaultSessionKeyTTL = 72 * time.Hour
+
+// ErrSessionKeyNotFound 未找到用户的 session_key，需要用户重新登录
+var ErrSessionKeyNotFound = errors.New("session_key 不存在，请重新登录")
+
+// SessionKeyStore 小程序 session_key 存储
+type SessionKeyStore interface {
+	// Save 保存用户的 session_key
+	Save(ctx context.Context, openID, sessionKey string) error
+	// Get 获取用户的 session_key，不存在时返回 ErrSessionKeyNotFound
+	Get(ctx context.Context, openID string) (string, error)
+	// Delete 删除用户的 session_key
+	Delete(ctx context.Context, openID string) error
+}
+
+// cacheSessionKeyStore 基于 cache.Cache 的 session_key 存储
+type cacheSessionKeyStore struct {
+	cache     cache.Cache
+	keyPrefix string
+	ttl       time.Duration
+}
+
+// NewCacheSessionKeyStore 创建基于 cache.Cache 的 session_key 存储
+// ttl 小于等于 0 时使用默认缓存时长
+func NewCacheSessionKeyStore(c cache.Cache, appID string, ttl time.Duration) SessionKeyStore {
+	if ttl <= 0 {
+		ttl = defaultSessionKeyTTL
+	}
+
+	return &cacheSessionKeyStore{
+		cache:     c,
+		keyPrefix: "miniprogram:session_key:" + appID + ":",
+		ttl:       ttl,
+	}
+}
+
+// Save 保存用户的 session_key
+func (s *cacheSessionKeyStore) Save(ctx context.Context, openID, sessionKey string) error {
+	return cache.SetContext(ctx, s.cache, s.keyPrefix+openID, sessionKey, s.ttl)
+}
+
+// Get 获取用户的 session_key
+func (s *cacheSessionKeyStore) Get(ctx context.Context, openID string) (string, error) {
+	if val, ok := cache.GetContext(ctx, s.cache, s.keyPrefix+openID).(string); ok && val != "" {
+		return val, nil
+	}
+
+	return "", ErrSessionKeyNotFound
+}
+
+// Delete 删除用户的 session_key
+func (s *cacheSessionKeyStore) Delete(ctx context.Context, openID string) error {
+	return cache.DeleteContext(ctx, s.cache, s.keyPrefix+openID)
+}
+
+// MiniProgramLoginResult 小程序登录结果
+type MiniProgramLoginResult struct {
+	OpenID  string `json:"openid"`  // 用户唯一标识
+	UnionID string `json:"unionid"` // 用户在开放平台的唯一标识，满足 UnionID 下发条件时返回
+}
+
+// SetSessionKeyStore 自定义 session_key 存储，默认使用客户端的缓存
+func (c *MiniProgramClient) SetSessionKeyStore(store SessionKeyStore) {
+	c.sessionKeyStore = store
+}
+
+// GetSessionKeyStore 获取 session_key 存储
+func (c *MiniProgramClient) GetSessionKeyStore() SessionKeyStore {
+	return c.sessionKeyStore
+}
+
+// Login 小程序登录
+// 使用 wx.login 获取的 code 调用 code2Session，并保存用户的 session_key 以便后续解密用户数据
+func (c *MiniProgramClient) Login(ctx context.Context, jsCode string) (*MiniProgramLoginResult, error) {
+	if jsCode == "" {
+		return nil, errors.New("jsCode 不能为空")
+	}
+
+	session, err := c.miniProgramIns.GetAuth().Code2SessionContext(ctx, jsCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.sessionKeyStore.Save(ctx, session.OpenID, session.SessionKey); err != nil {
+		return nil, fmt.Errorf("保存 session_key 失败: %w", err)
+	}
+
+	return &MiniProgramLoginResult{
+		OpenID:  session.OpenID,
+		UnionID: session.UnionID,
+	}, nil
+}
+
+// GetPhoneNumber 通过手机号获取凭证 code 获取用户手机号
+// code 由小程序 getPhoneNumber 按钮回调返回，每个 code 只能使用一次，有效期为 5 分钟
+func (c *MiniProgramClient) GetPhoneNumber(ctx context.Context, code string) (*MiniProgramPhoneNumber, error) {
+	if code == "" {
+		return nil, errors.New("code 不能为空")
+	}
+
+	phoneInfo, err := c.miniProgramIns.GetBusiness().GetPhoneNumberWithContext(ctx, &business.GetPhoneNumberRequest{Code: code})
+	if err != nil {
+		return nil, err
+	}
+	if phoneInfo.Watermark.AppID != c.config.AppId {
+		return nil, ErrWatermarkMismatch
+	}
+
+	return &MiniProgramPhoneNumber{
+		PhoneNumber:     phoneInfo.PhoneNumber,
+		PurePhoneNumber: phoneInfo.PurePhoneNumber,
+		CountryCode:     phoneInfo.CountryCode,
+		Watermark: MiniProgramWatermark{
+			AppID:     phoneInfo.Watermark.AppID,
+			Timestamp: phoneInfo.Watermark.Timestamp,
+		},
+	}, nil
+}
@@ -0,0 +1,97 @@
+package wechat_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/darwinOrg/go-wechat"
+	"github.com/silenceper/wechat/v2/util"
+)
+
+// newMockMiniProgramClient 创建请求指向本地模拟服务的小程序客户端
+func newMockMiniProgramClient(t *testing.T, mux *http.ServeMux) *wechat.MiniProgramClient {
+	mux.HandleFunc("/cgi-bin/stable_token", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "mock_token", "expires_in": 7200})
+	})
+	server := httptest.NewServer(mux)
+	util.SetURIModifier(func(uri string) string {
+		return strings.Replace(uri, "https://api.weixin.qq.com", server.URL, 1)
+	})
+	t.Cleanup(func() {
+		util.SetURIModifier(nil)
+		server.Close()
+	})
+
+	return wechat.NewMiniProgramClient(&wechat.MiniProgramConfig{
+		AppId:          "wx_test_appid",
+		AppSecret:      "test_secret",
+		ExpireInterval: 30,
+		EnvVersion:     "release",
+	})
+}
+
+// TestMiniProgramClient_Login 测试登录并保存 session_key
+func TestMiniProgramClient_Login(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sns/jscode2session", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("js_code") != "test_code" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 40029, "errmsg": "invalid code"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"openid": "test_openid", "unionid": "test_unionid", "session_key": "test_session_key"})
+	})
+	client := newMockMiniProgramClient(t, mux)
+
+	ctx := context.Background()
+	result, err := client.Login(ctx, "test_code")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if result.OpenID != "test_openid" || result.UnionID != "test_unionid" {
+		t.Fatalf("unexpected login result: %+v", result)
+	}
+
+	sessionKey, err := client.GetSessionKeyStore().Get(ctx, "test_openid")
+	if err != nil || sessionKey != "test_session_key" {
+		t.Fatalf("session_key not saved: %q, %v", sessionKey, err)
+	}
+
+	if _, err := client.Login(ctx, "bad_code"); err == nil {
+		t.Fatal("Login with invalid code should fail")
+	}
+	if _, err := client.GetSessionKeyStore().Get(ctx, "unknown_openid"); err != wechat.ErrSessionKeyNotFound {
+		t.Fatalf("expected ErrSessionKeyNotFound, got %v", err)
+	}
+}
+
+// TestMiniProgramClient_GetPhoneNumber 测试通过 code 获取手机号
+func TestMiniProgramClient_GetPhoneNumber(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wxa/business/getuserphonenumber", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("access_token") != "mock_token" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 40001, "errmsg": "invalid credential"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"phone_info": map[string]any{
+				"phoneNumber":     "+86 13800000000",
+				"purePhoneNumber": "13800000000",
+				"countryCode":     "86",
+				"watermark":       map[string]any{"appid": "wx_test_appid", "timestamp": 1700000000},
+			},
+		})
+	})
+	client := newMockMiniProgramClient(t, mux)
+
+	phone, err := client.GetPhoneNumber(context.Background(), "phone_code")
+	if err != nil {
+		t.Fatalf("GetPhoneNumber failed: %v", err)
+	}
+	if phone.PurePhoneNumber != "13800000000" || phone.CountryCode != "86" || phone.Watermark.AppID != "wx_test_appid" {
+		t.Fatalf("unexpected phone number: %+v", phone)
+	}
+}
@@ -0,0 +1,204 @@
+package wechat
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/silenceper/wechat/v2/miniprogram/encryptor"
+)
+
+var (
+	// ErrWatermarkMismatch 解密数据的水印 appid 与配置的 AppId 不一致
+	ErrWatermarkMismatch = errors.New("数据水印 appid 不匹配")
+	// ErrInvalidSignature 数据签名校验失败
+	ErrInvalidSignature = errors.New("数据签名校验失败")
+)
+
+// MiniProgramDecryptRequest 小程序加密数据解密请求
+type MiniProgramDecryptRequest struct {
+	SessionKey    string // 会话密钥，为空时根据 OpenID 从 SessionKeyStore 获取
+	OpenID        string // 用户 openid，未传 SessionKey 时必填
+	EncryptedData string // 包括敏感数据在内的完整用户信息的加密数据
+	IV            string // 加密算法的初始向量
+	RawData       string // 不包括敏感信息的原始数据字符串，可选，与 Signature 同时传入时校验签名
+	Signature     string // 使用 sha1(rawData + sessionKey) 得到的字符串，可选
+}
+
+// MiniProgramWatermark 数据水印
+type MiniProgramWatermark struct {
+	AppID     string `json:"appid"`     // 小程序 appid
+	Timestamp int64  `json:"timestamp"` // 数据生成的时间戳
+}
+
+// MiniProgramUserInfo 用户信息
+type MiniProgramUserInfo struct {
+	OpenID    string               `json:"openId"`
+	UnionID   string               `json:"unionId"`
+	NickName  string               `json:"nickName"`
+	Gender    int                  `json:"gender"` // 0：未知，1：男，2：女
+	City      string               `json:"city"`
+	Province  string               `json:"province"`
+	Country   string               `json:"country"`
+	AvatarURL string               `json:"avatarUrl"`
+	Language  string               `json:"language"`
+	Watermark MiniProgramWatermark `json:"watermark"`
+}
+
+// MiniProgramPhoneNumber 用户手机号
+type MiniProgramPhoneNumber struct {
+	PhoneNumber     string               `json:"phoneNumber"`     // 用户绑定的手机号（国外手机号会有区号）
+	PurePhoneNumber string               `json:"purePhoneNumber"` // 没有区号的手机号
+	CountryCode     string               `json:"countryCode"`     // 区号
+	Watermark       MiniProgramWatermark `json:"watermark"`
+}
+
+// MiniProgramShareInfo 群分享信息
+type MiniProgramShareInfo struct {
+	OpenGID   string               `json:"openGId"` // 群对当前小程序的唯一 ID
+	Watermark MiniProgramWatermark `json:"watermark"`
+}
+
+// MiniProgramRunData 微信运动步数
+type MiniProgramRunData struct {
+	StepInfoList []MiniProgramStepInfo `json:"stepInfoList"` // 最近 30 天的步数
+	Watermark    MiniProgramWatermark  `json:"watermark"`
+}
+
+// MiniProgramStepInfo 单日步数
+type MiniProgramStepInfo struct {
+	Timestamp int64 `json:"timestamp"` // 时间戳，表示数据对应的时间
+	Step      int   `json:"step"`      // 微信运动步数
+}
+
+// DecryptUserInfo 解密用户信息，传入 RawData 和 Signature 时同时校验签名
+func (c *MiniProgramClient) DecryptUserInfo(ctx context.Context, req *MiniProgramDecryptRequest) (*MiniProgramUserInfo, error) {
+	var userInfo MiniProgramUserInfo
+	if err := c.DecryptData(ctx, req, &userInfo); err != nil {
+		return nil, err
+	}
+
+	return &userInfo, nil
+}
+
+// DecryptPhoneNumber 解密用户手机号
+func (c *MiniProgramClient) DecryptPhoneNumber(ctx context.Context, req *MiniProgramDecryptRequest) (*MiniProgramPhoneNumber, error) {
+	var phoneNumber MiniProgramPhoneNumber
+	if err := c.DecryptData(ctx, req, &phoneNumber); err != nil {
+		return nil, err
+	}
+
+	return &phoneNumber, nil
+}
+
+// DecryptShareInfo 解密群分享信息
+func (c *MiniProgramClient) DecryptShareInfo(ctx context.Context, req *MiniProgramDecryptRequest) (*MiniProgramShareInfo, error) {
+	var shareInfo MiniProgramShareInfo
+	if err := c.DecryptData(ctx, req, &shareInfo); err != nil {
+		return nil, err
+	}
+
+	return &shareInfo, nil
+}
+
+// DecryptRunData 解密微信运动步数
+func (c *MiniProgramClient) DecryptRunData(ctx context.Context, req *MiniProgramDecryptRequest) (*MiniProgramRunData, error) {
+	var runData MiniProgramRunData
+	if err := c.DecryptData(ctx, req, &runData); err != nil {
+		return nil, err
+	}
+
+	return &runData, nil
+}
+
+// DecryptData 解密数据到自定义结构体，并校验水印 appid
+// 传入 RawData 和 Signature 时同时校验签名
+func (c *MiniProgramClient) DecryptData(ctx context.Context, req *MiniProgramDecryptRequest, v any) error {
+	sessionKey, err := c.resolveSessionKey(ctx, req.SessionKey, req.OpenID)
+	if err != nil {
+		return err
+	}
+
+	if req.RawData != "" || req.Signature != "" {
+		if err := VerifyRawDataSignature(sessionKey, req.RawData, req.Signature); err != nil {
+			return err
+		}
+	}
+
+	if err := validateCipherText(req.EncryptedData); err != nil {
+		return err
+	}
+
+	plainText, err := encryptor.GetCipherText(sessionKey, req.EncryptedData, req.IV)
+	if err != nil {
+		return fmt.Errorf("解密数据失败: %w", err)
+	}
+
+	var watermarked struct {
+		Watermark MiniProgramWatermark `json:"watermark"`
+	}
+	if err := json.Unmarshal(plainText, &watermarked); err != nil {
+		return fmt.Errorf("解析解密数据失败: %w", err)
+	}
+	if watermarked.Watermark.AppID != c.config.AppId {
+		return ErrWatermarkMismatch
+	}
+
+	if err := json.Unmarshal(plainText, v); err != nil {
+		return fmt.Errorf("解析解密数据失败: %w", err)
+	}
+
+	return nil
+}
+
+// validateCipherText 校验密文长度为 AES 块大小的整数倍
+// 上游解密时未校验长度，不完整的块会导致 panic
+func validateCipherText(encryptedData string) error {
+	cipherText, err := base64.StdEncoding.DecodeString(encryptedData)
+	if err != nil {
+		return fmt.Errorf("解码加密数据失败: %w", err)
+	}
+	if len(cipherText) == 0 || len(cipherText)%aes.BlockSize != 0 {
+		return fmt.Errorf("加密数据长度不正确: %d", len(cipherText))
+	}
+	return nil
+}
+
+// VerifyRawData 使用已保存的 session_key 校验 rawData 签名
+func (c *MiniProgramClient) VerifyRawData(ctx context.Context, openID, rawData, signature string) error {
+	sessionKey, err := c.resolveSessionKey(ctx, "", openID)
+	if err != nil {
+		return err
+	}
+
+	return VerifyRawDataSignature(sessionKey, rawData, signature)
+}
+
+// VerifyRawDataSignature 校验 rawData 签名，signature = sha1(rawData + sessionKey)
+func VerifyRawDataSignature(sessionKey, rawData, signature string) error {
+	sum := sha1.Sum([]byte(rawData + sessionKey))
+	expected := hex.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// resolveSessionKey 优先使用传入的 session_key，否则根据 openid 从存储中获取
+func (c *MiniProgramClient) resolveSessionKey(ctx context.Context, sessionKey, openID string) (string, error) {
+	if sessionKey != "" {
+		return sessionKey, nil
+	}
+	if openID == "" {
+		return "", errors.New("session_key 和 openid 不能同时为空")
+	}
+
+	return c.sessionKeyStore.Get(ctx, openID)
+}
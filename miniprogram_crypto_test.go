@@ -0,0 +1,99 @@
+package wechat_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/darwinOrg/go-wechat"
+)
+
+// encryptMiniProgramData 按小程序规则使用 AES-128-CBC 加密数据
+func encryptMiniProgramData(t *testing.T, sessionKey, iv, plainText []byte) string {
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padding := aes.BlockSize - len(plainText)%aes.BlockSize
+	plainText = append(plainText, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipherText := make([]byte, len(plainText))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(cipherText, plainText)
+	return base64.StdEncoding.EncodeToString(cipherText)
+}
+
+// TestMiniProgramClient_DecryptData 测试解密用户数据并校验水印和签名
+func TestMiniProgramClient_DecryptData(t *testing.T) {
+	client := wechat.NewMiniProgramClient(&wechat.MiniProgramConfig{AppId: "wx_test_appid"})
+
+	ctx := context.Background()
+	key := []byte("0123456789abcdef")
+	iv := []byte("fedcba9876543210")
+	sessionKey := base64.StdEncoding.EncodeToString(key)
+	ivText := base64.StdEncoding.EncodeToString(iv)
+	if err := client.GetSessionKeyStore().Save(ctx, "test_openid", sessionKey); err != nil {
+		t.Fatal(err)
+	}
+
+	phonePlain := `{"phoneNumber":"+86 13800000000","purePhoneNumber":"13800000000","countryCode":"86","watermark":{"appid":"wx_test_appid","timestamp":1700000000}}`
+	phone, err := client.DecryptPhoneNumber(ctx, &wechat.MiniProgramDecryptRequest{
+		OpenID:        "test_openid",
+		EncryptedData: encryptMiniProgramData(t, key, iv, []byte(phonePlain)),
+		IV:            ivText,
+	})
+	if err != nil {
+		t.Fatalf("DecryptPhoneNumber failed: %v", err)
+	}
+	if phone.PurePhoneNumber != "13800000000" || phone.CountryCode != "86" || phone.Watermark.Timestamp != 1700000000 {
+		t.Fatalf("unexpected phone number: %+v", phone)
+	}
+
+	rawData := `{"nickName":"test"}`
+	sum := sha1.Sum([]byte(rawData + sessionKey))
+	userPlain := `{"openId":"test_openid","nickName":"test","watermark":{"appid":"wx_test_appid","timestamp":1700000000}}`
+	userReq := &wechat.MiniProgramDecryptRequest{
+		SessionKey:    sessionKey,
+		EncryptedData: encryptMiniProgramData(t, key, iv, []byte(userPlain)),
+		IV:            ivText,
+		RawData:       rawData,
+		Signature:     hex.EncodeToString(sum[:]),
+	}
+	userInfo, err := client.DecryptUserInfo(ctx, userReq)
+	if err != nil {
+		t.Fatalf("DecryptUserInfo failed: %v", err)
+	}
+	if userInfo.OpenID != "test_openid" || userInfo.NickName != "test" {
+		t.Fatalf("unexpected user info: %+v", userInfo)
+	}
+
+	userReq.Signature = "bad_signature"
+	if _, err := client.DecryptUserInfo(ctx, userReq); !errors.Is(err, wechat.ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+
+	otherPlain := `{"openGId":"group","watermark":{"appid":"wx_other_appid","timestamp":1700000000}}`
+	_, err = client.DecryptShareInfo(ctx, &wechat.MiniProgramDecryptRequest{
+		SessionKey:    sessionKey,
+		EncryptedData: encryptMiniProgramData(t, key, iv, []byte(otherPlain)),
+		IV:            ivText,
+	})
+	if !errors.Is(err, wechat.ErrWatermarkMismatch) {
+		t.Fatalf("expected ErrWatermarkMismatch, got %v", err)
+	}
+
+	// 密文长度不是块大小的整数倍时返回错误而不是 panic
+	truncated, _ := base64.StdEncoding.DecodeString(encryptMiniProgramData(t, key, iv, []byte(phonePlain)))
+	_, err = client.DecryptPhoneNumber(ctx, &wechat.MiniProgramDecryptRequest{
+		SessionKey:    sessionKey,
+		EncryptedData: base64.StdEncoding.EncodeToString(truncated[:len(truncated)-3]),
+		IV:            ivText,
+	})
+	if err == nil {
+		t.Fatal("expected error for truncated cipher text")
+	}
+}
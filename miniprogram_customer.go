@@ -0,0 +1,94 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/silenceper/wechat/v2/miniprogram/message"
+	"github.com/silenceper/wechat/v2/util"
+)
+
+const (
+	customerSendURL    = "https://api.weixin.qq.com/cgi-bin/message/custom/send?access_token=%s"
+	customerTypingURL  = "https://api.weixin.qq.com/cgi-bin/message/custom/typing?access_token=%s"
+	uploadTempMediaURL = "https://api.weixin.qq.com/cgi-bin/media/upload?access_token=%s&type=%s"
+)
+
+// CustomerMessage 小程序客服消息
+type CustomerMessage = message.CustomerMessage
+
+// TempMedia 临时素材上传结果，有效期 3 天
+type TempMedia struct {
+	util.CommonError
+	Type      string `json:"type"`       // 素材类型
+	MediaID   string `json:"media_id"`   // 素材ID，可用于发送客服消息
+	CreatedAt int64  `json:"created_at"` // 上传时间戳
+}
+
+// SendCustomerMessage 发送客服消息，用户需在 48 小时内与客服有过互动
+func (c *MiniProgramClient) SendCustomerMessage(ctx context.Context, msg *CustomerMessage) error {
+	if msg.ToUser == "" {
+		return errors.New("touser 不能为空")
+	}
+
+	return c.postJSON(ctx, customerSendURL, msg, &commonResponse{}, "SendCustomerMessage")
+}
+
+// SendCustomerTextMessage 发送客服文本消息
+func (c *MiniProgramClient) SendCustomerTextMessage(ctx context.Context, toUser, content string) error {
+	return c.SendCustomerMessage(ctx, message.NewCustomerTextMessage(toUser, content))
+}
+
+// SendCustomerImageMessage 发送客服图片消息
+// mediaID: 通过 UploadTempImage 上传的图片素材ID
+func (c *MiniProgramClient) SendCustomerImageMessage(ctx context.Context, toUser, mediaID string) error {
+	return c.SendCustomerMessage(ctx, message.NewCustomerImgMessage(toUser, mediaID))
+}
+
+// SendCustomerLinkMessage 发送客服图文链接消息
+// thumbURL: 图文链接消息的图片链接
+func (c *MiniProgramClient) SendCustomerLinkMessage(ctx context.Context, toUser, title, description, url, thumbURL string) error {
+	return c.SendCustomerMessage(ctx, message.NewCustomerLinkMessage(toUser, title, description, url, thumbURL))
+}
+
+// SendCustomerMiniProgramPageMessage 发送客服小程序卡片消息
+// pagePath: 点击卡片后进入的小程序页面路径
+// thumbMediaID: 卡片封面图片的素材ID，通过 UploadTempImage 上传
+func (c *MiniProgramClient) SendCustomerMiniProgramPageMessage(ctx context.Context, toUser, title, pagePath, thumbMediaID string) error {
+	return c.SendCustomerMessage(ctx, message.NewCustomerMiniprogrampageMessage(toUser, title, pagePath, thumbMediaID))
+}
+
+// SetTyping 下发客服当前输入状态，typing 为 false 时取消正在输入状态
+func (c *MiniProgramClient) SetTyping(ctx context.Context, toUser string, typing bool) error {
+	command := "CancelTyping"
+	if typing {
+		command = "Typing"
+	}
+
+	return c.postJSON(ctx, customerTypingURL, map[string]string{
+		"touser":  toUser,
+		"command": command,
+	}, &commonResponse{}, "SetTyping")
+}
+
+// UploadTempImage 上传客服消息使用的临时图片素材
+func (c *MiniProgramClient) UploadTempImage(ctx context.Context, fileName string, reader io.Reader) (*TempMedia, error) {
+	accessToken, err := c.miniProgramIns.GetContext().GetAccessTokenContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := util.PostFileFromReader("media", fileName, fileName, fmt.Sprintf(uploadTempMediaURL, accessToken, "image"), reader)
+	if err != nil {
+		return nil, fmt.Errorf("上传临时素材失败: %w", err)
+	}
+
+	media := &TempMedia{}
+	if err := util.DecodeWithError(response, media, "UploadTempImage"); err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
@@ -0,0 +1,68 @@
+package wechat_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestMiniProgramClient_CustomerMessage 测试上传临时图片、输入状态和客服消息发送
+func TestMiniProgramClient_CustomerMessage(t *testing.T) {
+	var sent []map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgi-bin/media/upload", func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("media")
+		if err != nil || r.URL.Query().Get("type") != "image" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 40004, "errmsg": "invalid media type"})
+			return
+		}
+		data, _ := io.ReadAll(file)
+		if header.Filename != "a.png" || string(data) != "png data" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 40005, "errmsg": "invalid file"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"type": "image", "media_id": "media_1", "created_at": 1700000000})
+	})
+	mux.HandleFunc("/cgi-bin/message/custom/typing", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["command"] != "Typing" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 40001, "errmsg": "invalid command"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0})
+	})
+	mux.HandleFunc("/cgi-bin/message/custom/send", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		sent = append(sent, req)
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0})
+	})
+	miniClient := newMockMiniProgramClient(t, mux)
+	ctx := context.Background()
+
+	media, err := miniClient.UploadTempImage(ctx, "a.png", strings.NewReader("png data"))
+	if err != nil || media.MediaID != "media_1" {
+		t.Fatalf("UploadTempImage failed: %+v, %v", media, err)
+	}
+	if err := miniClient.SetTyping(ctx, "test_openid", true); err != nil {
+		t.Fatalf("SetTyping failed: %v", err)
+	}
+	if err := miniClient.SendCustomerTextMessage(ctx, "test_openid", "hello"); err != nil {
+		t.Fatalf("SendCustomerTextMessage failed: %v", err)
+	}
+	if err := miniClient.SendCustomerMiniProgramPageMessage(ctx, "test_openid", "title", "pages/index", media.MediaID); err != nil {
+		t.Fatalf("SendCustomerMiniProgramPageMessage failed: %v", err)
+	}
+
+	if len(sent) != 2 || sent[0]["msgtype"] != "text" || sent[1]["msgtype"] != "miniprogrampage" {
+		t.Fatalf("unexpected sent messages: %+v", sent)
+	}
+	page := sent[1]["miniprogrampage"].(map[string]any)
+	if page["thumb_media_id"] != "media_1" || page["pagepath"] != "pages/index" {
+		t.Fatalf("unexpected miniprogrampage: %+v", page)
+	}
+}
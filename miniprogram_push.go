@@ -0,0 +1,293 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// 小程序消息推送的消息类型和事件类型
+const (
+	MiniProgramMsgTypeText            = "text"
+	MiniProgramMsgTypeImage           = "image"
+	MiniProgramMsgTypeMiniProgramPage = "miniprogrampage"
+	MiniProgramMsgTypeEvent           = "event"
+
+	MiniProgramEventUserEnterTempSession = "user_enter_tempsession"     // 用户进入客服会话
+	MiniProgramEventSubscribePopup       = "subscribe_msg_popup_event"  // 用户操作订阅消息弹窗
+	MiniProgramEventSubscribeMsgChange   = "subscribe_msg_change_event" // 用户管理订阅消息
+	MiniProgramEventSubscribeMsgSent     = "subscribe_msg_sent_event"   // 订阅消息发送结果
+	MiniProgramEventMediaCheck           = "wxa_media_check"            // 异步内容安全检测结果
+)
+
+// MiniProgramPushHeader 小程序推送消息的公共字段
+type MiniProgramPushHeader struct {
+	ToUserName   string `xml:"ToUserName" json:"ToUserName"`     // 小程序原始ID
+	FromUserName string `xml:"FromUserName" json:"FromUserName"` // 发送者的 openid
+	CreateTime   int64  `xml:"CreateTime" json:"CreateTime"`     // 消息创建时间（整型）
+	MsgType      string `xml:"MsgType" json:"MsgType"`           // 消息类型
+	Event        string `xml:"Event" json:"Event"`               // 事件类型，MsgType 为 event 时有效
+}
+
+// MiniProgramPushMessage 未被类型化处理的推送消息
+type MiniProgramPushMessage struct {
+	MiniProgramPushHeader
+	Format string // 数据格式，xml 或 json
+	Raw    []byte // 解密后的原始数据
+}
+
+// MiniProgramCustomerMessage 客服消息，包括用户进入客服会话事件
+type MiniProgramCustomerMessage struct {
+	MiniProgramPushHeader
+	MsgID        int64  `xml:"MsgId" json:"MsgId"`               // 消息ID
+	Content      string `xml:"Content" json:"Content"`           // 文本消息内容
+	PicURL       string `xml:"PicUrl" json:"PicUrl"`             // 图片链接
+	MediaID      string `xml:"MediaId" json:"MediaId"`           // 图片消息媒体ID
+	Title        string `xml:"Title" json:"Title"`               // 小程序卡片标题
+	AppID        string `xml:"AppId" json:"AppId"`               // 小程序卡片 appid
+	PagePath     string `xml:"PagePath" json:"PagePath"`         // 小程序卡片页面路径
+	ThumbURL     string `xml:"ThumbUrl" json:"ThumbUrl"`         // 小程序卡片封面图片的临时 cdn 链接
+	ThumbMediaID string `xml:"ThumbMediaId" json:"ThumbMediaId"` // 小程序卡片封面图片的临时素材ID
+	SessionFrom  string `xml:"SessionFrom" json:"SessionFrom"`   // 进入会话事件的开发者在客服会话按钮设置的 session-from 属性
+}
+
+// SubscribeMsgEvent 订阅消息相关事件，包括弹窗、管理和发送结果
+type SubscribeMsgEvent struct {
+	MiniProgramPushHeader
+	List []SubscribeMsgEventItem
+}
+
+// SubscribeMsgEventItem 订阅消息事件中的单个模板
+type SubscribeMsgEventItem struct {
+	TemplateID            string `xml:"TemplateId" json:"TemplateId"`                       // 模板ID
+	SubscribeStatusString string `xml:"SubscribeStatusString" json:"SubscribeStatusString"` // 订阅状态，accept 或 reject，弹窗和管理事件有效
+	PopupScene            string `xml:"PopupScene" json:"PopupScene"`                       // 弹窗场景，0 为 wx.requestSubscribeMessage 调用
+	MsgID                 string `xml:"MsgID" json:"MsgID"`                                 // 消息ID，发送结果事件有效
+	ErrorCode             int    `xml:"ErrorCode" json:"ErrorCode"`                         // 发送结果错误码，0 为成功
+	ErrorStatus           string `xml:"ErrorStatus" json:"ErrorStatus"`                     // 发送结果状态
+}
+
+// MiniProgramMessageHandlers 小程序消息推送处理函数，未设置的类型交给 OnOther 处理
+type MiniProgramMessageHandlers struct {
+	OnCustomerMessage func(ctx context.Context, msg *MiniProgramCustomerMessage) error // 客服消息及进入会话事件
+	OnSubscribeMsg    func(ctx context.Context, event *SubscribeMsgEvent) error        // 订阅消息弹窗、管理、发送结果事件
+	OnMediaCheck      func(ctx context.Context, result *MediaCheckResult) error        // 异步内容安全检测结果，可直接使用 MediaCheckCallback.Handle
+	OnOther           func(ctx context.Context, msg *MiniProgramPushMessage) error     // 其他消息和事件
+}
+
+// MiniProgramHTTPHandler 小程序消息推送 HTTP 处理器
+type MiniProgramHTTPHandler struct {
+	appID          string
+	token          string
+	encodingAESKey string
+	handlers       *MiniProgramMessageHandlers
+}
+
+var _ http.Handler = (*MiniProgramHTTPHandler)(nil)
+
+// CreateHTTPHandler 创建HTTP处理器用于接收小程序消息推送
+// 支持明文、兼容和安全模式，以及 XML 和 JSON 两种数据格式
+func (c *MiniProgramClient) CreateHTTPHandler(handlers *MiniProgramMessageHandlers) (*MiniProgramHTTPHandler, error) {
+	if c.config.Token == "" {
+		return nil, errors.New("消息推送 Token 不能为空")
+	}
+	if c.config.EncodingAESKey != "" && len(c.config.EncodingAESKey) != 43 {
+		return nil, errors.New("EncodingAESKey 长度必须为 43")
+	}
+	if handlers == nil {
+		handlers = &MiniProgramMessageHandlers{}
+	}
+
+	return &MiniProgramHTTPHandler{
+		appID:          c.config.AppId,
+		token:          c.config.Token,
+		encodingAESKey: c.config.EncodingAESKey,
+		handlers:       handlers,
+	}, nil
+}
+
+// ServeHTTP 处理消息推送请求
+func (h *MiniProgramHTTPHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet:
+		// 配置消息推送时的 URL 验证
+		if !verifyCallbackSignature(query.Get("signature"), h.token, query.Get("timestamp"), query.Get("nonce")) {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte(query.Get("echostr")))
+
+	case http.MethodPost:
+		defer r.Body.Close()
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		format, plain, err := openCallbackBody(query, body, h.appID, h.token, h.encodingAESKey)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := h.dispatch(r.Context(), format, plain); err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte("success"))
+
+	default:
+		rw.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// dispatch 按消息类型解析并分发给对应的处理函数
+func (h *MiniProgramHTTPHandler) dispatch(ctx context.Context, format string, plain []byte) error {
+	var header MiniProgramPushHeader
+	if err := unmarshalCallback(format, plain, &header); err != nil {
+		return err
+	}
+
+	switch {
+	case h.handlers.OnCustomerMessage != nil && isMiniProgramCustomerMessage(&header):
+		var msg MiniProgramCustomerMessage
+		if err := unmarshalCallback(format, plain, &msg); err != nil {
+			return err
+		}
+		return h.handlers.OnCustomerMessage(ctx, &msg)
+
+	case h.handlers.OnSubscribeMsg != nil && isSubscribeMsgEvent(&header):
+		event, err := parseSubscribeMsgEvent(format, plain)
+		if err != nil {
+			return err
+		}
+		return h.handlers.OnSubscribeMsg(ctx, event)
+
+	case h.handlers.OnMediaCheck != nil && header.MsgType == MiniProgramMsgTypeEvent && header.Event == MiniProgramEventMediaCheck:
+		result, err := parseMediaCheckEvent(format, plain)
+		if err != nil {
+			return err
+		}
+		// 未找到检测记录的结果无法再处理，按成功应答避免微信重试
+		if err := h.handlers.OnMediaCheck(ctx, result); err != nil && !errors.Is(err, ErrMediaCheckTraceNotFound) {
+			return err
+		}
+		return nil
+
+	case h.handlers.OnOther != nil:
+		return h.handlers.OnOther(ctx, &MiniProgramPushMessage{MiniProgramPushHeader: header, Format: format, Raw: plain})
+	}
+
+	return nil
+}
+
+// isMiniProgramCustomerMessage 是否为客服消息或进入会话事件
+func isMiniProgramCustomerMessage(header *MiniProgramPushHeader) bool {
+	switch header.MsgType {
+	case MiniProgramMsgTypeText, MiniProgramMsgTypeImage, MiniProgramMsgTypeMiniProgramPage:
+		return true
+	case MiniProgramMsgTypeEvent:
+		return header.Event == MiniProgramEventUserEnterTempSession
+	}
+	return false
+}
+
+// isSubscribeMsgEvent 是否为订阅消息相关事件
+func isSubscribeMsgEvent(header *MiniProgramPushHeader) bool {
+	if header.MsgType != MiniProgramMsgTypeEvent {
+		return false
+	}
+	switch header.Event {
+	case MiniProgramEventSubscribePopup, MiniProgramEventSubscribeMsgChange, MiniProgramEventSubscribeMsgSent:
+		return true
+	}
+	return false
+}
+
+// parseSubscribeMsgEvent 解析订阅消息事件
+// XML 格式的模板列表位于 SubscribeMsgPopupEvent 等节点下，JSON 格式的 List 可能是对象或数组
+func parseSubscribeMsgEvent(format string, plain []byte) (*SubscribeMsgEvent, error) {
+	var event SubscribeMsgEvent
+	if format == callbackFormatXML {
+		var data struct {
+			MiniProgramPushHeader
+			Popup  []SubscribeMsgEventItem `xml:"SubscribeMsgPopupEvent>List"`
+			Change []SubscribeMsgEventItem `xml:"SubscribeMsgChangeEvent>List"`
+			Sent   []SubscribeMsgEventItem `xml:"SubscribeMsgSentEvent>List"`
+		}
+		if err := xml.Unmarshal(plain, &data); err != nil {
+			return nil, err
+		}
+		event.MiniProgramPushHeader = data.MiniProgramPushHeader
+		event.List = append(append(append(event.List, data.Popup...), data.Change...), data.Sent...)
+		return &event, nil
+	}
+
+	var data struct {
+		MiniProgramPushHeader
+		List json.RawMessage `json:"List"`
+	}
+	if err := json.Unmarshal(plain, &data); err != nil {
+		return nil, err
+	}
+	event.MiniProgramPushHeader = data.MiniProgramPushHeader
+	list := bytes.TrimSpace(data.List)
+	switch {
+	case bytes.HasPrefix(list, []byte("[")):
+		if err := json.Unmarshal(list, &event.List); err != nil {
+			return nil, err
+		}
+	case bytes.HasPrefix(list, []byte("{")):
+		var item SubscribeMsgEventItem
+		if err := json.Unmarshal(list, &item); err != nil {
+			return nil, err
+		}
+		event.List = []SubscribeMsgEventItem{item}
+	}
+
+	return &event, nil
+}
+
+// parseMediaCheckEvent 解析异步内容安全检测结果事件
+func parseMediaCheckEvent(format string, plain []byte) (*MediaCheckResult, error) {
+	type detail struct {
+		Strategy string          `xml:"strategy" json:"strategy"`
+		ErrCode  int64           `xml:"errcode" json:"errcode"`
+		Suggest  SecCheckSuggest `xml:"suggest" json:"suggest"`
+		Label    SecCheckLabel   `xml:"label" json:"label"`
+		Prob     uint            `xml:"prob" json:"prob"`
+		Keyword  string          `xml:"keyword" json:"keyword"`
+	}
+	var data struct {
+		TraceID string `xml:"trace_id" json:"trace_id"`
+		Result  struct {
+			Suggest SecCheckSuggest `xml:"suggest" json:"suggest"`
+			Label   SecCheckLabel   `xml:"label" json:"label"`
+		} `xml:"result" json:"result"`
+		Detail []detail `xml:"detail" json:"detail"`
+	}
+	if err := unmarshalCallback(format, plain, &data); err != nil {
+		return nil, err
+	}
+
+	result := &MediaCheckResult{
+		TraceID: data.TraceID,
+		Suggest: data.Result.Suggest,
+		Label:   data.Result.Label,
+	}
+	for _, d := range data.Detail {
+		result.Details = append(result.Details, SecCheckDetail(d))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,205 @@
+package wechat_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darwinOrg/go-wechat"
+	"github.com/silenceper/wechat/v2/util"
+)
+
+const (
+	testPushToken  = "test_token"
+	testPushAESKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+)
+
+// TestMiniProgramHTTPHandler 测试消息推送的 URL 验证、明文与安全模式分发
+func TestMiniProgramHTTPHandler(t *testing.T) {
+	miniClient := wechat.NewMiniProgramClient(&wechat.MiniProgramConfig{
+		AppId:          "wx_test_appid",
+		AppSecret:      "test_secret",
+		Token:          testPushToken,
+		EncodingAESKey: testPushAESKey,
+	})
+
+	// 明文模式使用未配置 EncodingAESKey 的小程序
+	plainClient := wechat.NewMiniProgramClient(&wechat.MiniProgramConfig{
+		AppId:     "wx_test_appid",
+		AppSecret: "test_secret",
+		Token:     testPushToken,
+	})
+
+	var (
+		customerMsg *wechat.MiniProgramCustomerMessage
+		subscribe   *wechat.SubscribeMsgEvent
+		mediaCheck  *wechat.MediaCheckResult
+	)
+	handlers := &wechat.MiniProgramMessageHandlers{
+		OnCustomerMessage: func(ctx context.Context, msg *wechat.MiniProgramCustomerMessage) error {
+			customerMsg = msg
+			return nil
+		},
+		OnSubscribeMsg: func(ctx context.Context, event *wechat.SubscribeMsgEvent) error {
+			subscribe = event
+			return nil
+		},
+		OnMediaCheck: func(ctx context.Context, result *wechat.MediaCheckResult) error {
+			mediaCheck = result
+			return nil
+		},
+	}
+	handler, err := miniClient.CreateHTTPHandler(handlers)
+	if err != nil {
+		t.Fatalf("CreateHTTPHandler failed: %v", err)
+	}
+	plainHandler, err := plainClient.CreateHTTPHandler(handlers)
+	if err != nil {
+		t.Fatalf("CreateHTTPHandler failed: %v", err)
+	}
+
+	// URL 验证
+	query := url.Values{"timestamp": {"1700000000"}, "nonce": {"nonce"}, "echostr": {"echo_123"}}
+	query.Set("signature", util.Signature(testPushToken, "1700000000", "nonce"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/push?"+query.Encode(), nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "echo_123" {
+		t.Fatalf("unexpected echo response: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// 签名错误
+	query.Set("signature", "bad")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/push?"+query.Encode(), nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad signature, got %d", rec.Code)
+	}
+
+	// 明文模式 XML 客服消息
+	xmlBody := `<xml><ToUserName>gh_test</ToUserName><FromUserName>test_openid</FromUserName><CreateTime>1700000000</CreateTime><MsgType>text</MsgType><Content>hello</Content><MsgId>1</MsgId></xml>`
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	query = url.Values{"timestamp": {timestamp}, "nonce": {"nonce"}}
+	query.Set("signature", util.Signature(testPushToken, timestamp, "nonce"))
+	rec = httptest.NewRecorder()
+	plainHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/push?"+query.Encode(), strings.NewReader(xmlBody)))
+	if rec.Code != http.StatusOK || customerMsg == nil || customerMsg.Content != "hello" || customerMsg.FromUserName != "test_openid" {
+		t.Fatalf("unexpected customer message: %d %+v", rec.Code, customerMsg)
+	}
+
+	// 明文模式 XML 订阅消息弹窗事件
+	xmlBody = `<xml><ToUserName>gh_test</ToUserName><FromUserName>test_openid</FromUserName><CreateTime>1700000000</CreateTime><MsgType>event</MsgType><Event>subscribe_msg_popup_event</Event>` +
+		`<SubscribeMsgPopupEvent><List><TemplateId>tpl_1</TemplateId><SubscribeStatusString>accept</SubscribeStatusString><PopupScene>0</PopupScene></List>` +
+		`<List><TemplateId>tpl_2</TemplateId><SubscribeStatusString>reject</SubscribeStatusString><PopupScene>0</PopupScene></List></SubscribeMsgPopupEvent></xml>`
+	rec = httptest.NewRecorder()
+	plainHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/push?"+query.Encode(), strings.NewReader(xmlBody)))
+	if rec.Code != http.StatusOK || subscribe == nil || len(subscribe.List) != 2 || subscribe.List[1].SubscribeStatusString != "reject" {
+		t.Fatalf("unexpected subscribe event: %d %+v", rec.Code, subscribe)
+	}
+
+	// 配置了 EncodingAESKey 时拒绝明文消息，避免仅凭 URL 签名伪造消息体
+	subscribe = nil
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/push?"+query.Encode(), strings.NewReader(xmlBody)))
+	if rec.Code != http.StatusBadRequest || subscribe != nil {
+		t.Fatalf("expected 400 for plaintext message with AES key configured, got %d", rec.Code)
+	}
+
+	// 时间戳超出允许范围
+	staleTimestamp := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	query = url.Values{"timestamp": {staleTimestamp}, "nonce": {"nonce"}}
+	query.Set("signature", util.Signature(testPushToken, staleTimestamp, "nonce"))
+	rec = httptest.NewRecorder()
+	plainHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/push?"+query.Encode(), strings.NewReader(xmlBody)))
+	if rec.Code != http.StatusBadRequest || subscribe != nil {
+		t.Fatalf("expected 400 for stale timestamp, got %d", rec.Code)
+	}
+
+	// 安全模式 JSON 订阅消息事件，List 为对象
+	jsonBody := `{"ToUserName":"gh_test","FromUserName":"test_openid","CreateTime":1700000000,"MsgType":"event","Event":"subscribe_msg_sent_event","List":{"TemplateId":"tpl_3","MsgID":"100","ErrorCode":0,"ErrorStatus":"success"}}`
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, newEncryptedPushRequest(t, jsonBody))
+	if rec.Code != http.StatusOK || subscribe == nil || len(subscribe.List) != 1 || subscribe.List[0].TemplateID != "tpl_3" {
+		t.Fatalf("unexpected subscribe sent event: %d %+v", rec.Code, subscribe)
+	}
+
+	// 安全模式 JSON 异步检测结果
+	jsonBody = `{"ToUserName":"gh_test","FromUserName":"test_openid","CreateTime":1700000000,"MsgType":"event","Event":"wxa_media_check","appid":"wx_test_appid","trace_id":"media_trace","version":2,` +
+		`"detail":[{"strategy":"content_model","errcode":0,"suggest":"risky","label":20002,"prob":90}],"errcode":0,"result":{"suggest":"risky","label":20002}}`
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, newEncryptedPushRequest(t, jsonBody))
+	if rec.Code != http.StatusOK || mediaCheck == nil || mediaCheck.TraceID != "media_trace" || mediaCheck.Passed() || len(mediaCheck.Details) != 1 {
+		t.Fatalf("unexpected media check result: %d %+v", rec.Code, mediaCheck)
+	}
+}
+
+// newEncryptedPushRequest 构造安全模式的 JSON 推送请求
+func newEncryptedPushRequest(t *testing.T, plain string) *http.Request {
+	t.Helper()
+
+	encrypted, err := util.EncryptMsg([]byte("0123456789abcdef"), []byte(plain), "wx_test_appid", testPushAESKey)
+	if err != nil {
+		t.Fatalf("EncryptMsg failed: %v", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	query := url.Values{"timestamp": {timestamp}, "nonce": {"nonce"}, "encrypt_type": {"aes"}}
+	query.Set("msg_signature", util.Signature(testPushToken, timestamp, "nonce", string(encrypted)))
+	body := fmt.Sprintf(`{"ToUserName":"gh_test","Encrypt":%q}`, encrypted)
+
+	return httptest.NewRequest(http.MethodPost, "/push?"+query.Encode(), strings.NewReader(body))
+}
+
+// TestMiniProgramHTTPHandler_MediaCheckRedelivery 测试重复推送同一异步检测结果时均应答成功且只处理一次
+func TestMiniProgramHTTPHandler_MediaCheckRedelivery(t *testing.T) {
+	miniClient := wechat.NewMiniProgramClient(&wechat.MiniProgramConfig{
+		AppId:          "wx_test_appid",
+		AppSecret:      "test_secret",
+		Token:          testPushToken,
+		EncodingAESKey: testPushAESKey,
+	})
+	ctx := context.Background()
+	if err := miniClient.GetMediaCheckTraceStore().Save(ctx, &wechat.MediaCheckRecord{TraceID: "media_trace", BizID: "avatar_1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var handled int
+	callback := miniClient.NewMediaCheckCallback(func(ctx context.Context, record *wechat.MediaCheckRecord, result *wechat.MediaCheckResult) error {
+		handled++
+		return nil
+	})
+	handler, err := miniClient.CreateHTTPHandler(&wechat.MiniProgramMessageHandlers{OnMediaCheck: callback.Handle})
+	if err != nil {
+		t.Fatalf("CreateHTTPHandler failed: %v", err)
+	}
+
+	jsonBody := `{"ToUserName":"gh_test","FromUserName":"test_openid","CreateTime":1700000000,"MsgType":"event","Event":"wxa_media_check","appid":"wx_test_appid","trace_id":"media_trace","version":2,` +
+		`"detail":[],"errcode":0,"result":{"suggest":"pass","label":100}}`
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newEncryptedPushRequest(t, jsonBody))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("push %d: expected 200, got %d", i+1, rec.Code)
+		}
+	}
+	if handled != 1 {
+		t.Fatalf("expected result handled once, got %d", handled)
+	}
+
+	// 自定义处理函数返回 ErrMediaCheckTraceNotFound 时同样应答成功
+	handler, _ = miniClient.CreateHTTPHandler(&wechat.MiniProgramMessageHandlers{
+		OnMediaCheck: func(ctx context.Context, result *wechat.MediaCheckResult) error {
+			return fmt.Errorf("查询失败: %w", wechat.ErrMediaCheckTraceNotFound)
+		},
+	})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newEncryptedPushRequest(t, jsonBody))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for unknown trace, got %d", rec.Code)
+	}
+}
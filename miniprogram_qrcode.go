@@ -0,0 +1,109 @@
+package wechat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/silenceper/wechat/v2/miniprogram/qrcode"
+	"github.com/silenceper/wechat/v2/util"
+)
+
+const (
+	getWXACodeURL        = "https://api.weixin.qq.com/wxa/getwxacode?access_token=%s"
+	getWXACodeUnlimitURL = "https://api.weixin.qq.com/wxa/getwxacodeunlimit?access_token=%s"
+	createWXAQRCodeURL   = "https://api.weixin.qq.com/cgi-bin/wxaapp/createwxaqrcode?access_token=%s"
+)
+
+// WXACodeOptions 小程序码样式选项
+type WXACodeOptions struct {
+	Width     int           // 二维码的宽度，单位 px，最小 280px，最大 1280px，默认 430px
+	AutoColor bool          // 自动配置线条颜色，默认 false
+	LineColor *qrcode.Color // AutoColor 为 false 时生效，使用 rgb 设置颜色，十进制表示
+	IsHyaline bool          // 是否需要透明底色，默认 false
+}
+
+// WXACodeError 获取小程序码时微信返回的错误
+// 微信在出错时仍返回 HTTP 200，响应体为 JSON 错误信息而不是图片
+type WXACodeError struct {
+	ErrCode int64  `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (e *WXACodeError) Error() string {
+	return fmt.Sprintf("获取小程序码失败: %d - %s", e.ErrCode, e.ErrMsg)
+}
+
+// GetWXACode 获取小程序码，适用于需要的码数量较少的业务场景，总数限制 10 万个
+// path: 扫码进入的小程序页面路径，可以携带参数，最大长度 1024 字节
+func (c *MiniProgramClient) GetWXACode(path string, opts *WXACodeOptions) ([]byte, error) {
+	coder := qrcode.QRCoder{
+		Path:       path,
+		EnvVersion: c.config.EnvVersion,
+	}
+	applyWXACodeOptions(&coder, opts)
+
+	return c.fetchWXACode(getWXACodeURL, coder)
+}
+
+// GetWXACodeUnlimitWithOptions 获取不限制数量的小程序码，并指定样式
+func (c *MiniProgramClient) GetWXACodeUnlimitWithOptions(page, scene string, checkPath bool, opts *WXACodeOptions) ([]byte, error) {
+	coder := qrcode.QRCoder{
+		Page:       page,
+		Path:       page,
+		Scene:      scene,
+		CheckPath:  &checkPath,
+		EnvVersion: c.config.EnvVersion,
+	}
+	applyWXACodeOptions(&coder, opts)
+
+	return c.fetchWXACode(getWXACodeUnlimitURL, coder)
+}
+
+// CreateWXAQRCode 获取小程序二维码，适用于需要的码数量较少的业务场景，总数限制 10 万个
+// width: 二维码的宽度，单位 px，传 0 时使用默认值 430px
+func (c *MiniProgramClient) CreateWXAQRCode(path string, width int) ([]byte, error) {
+	return c.fetchWXACode(createWXAQRCodeURL, qrcode.QRCoder{
+		Path:  path,
+		Width: width,
+	})
+}
+
+// applyWXACodeOptions 将样式选项写入请求参数
+func applyWXACodeOptions(coder *qrcode.QRCoder, opts *WXACodeOptions) {
+	if opts == nil {
+		return
+	}
+
+	coder.Width = opts.Width
+	coder.AutoColor = opts.AutoColor
+	coder.LineColor = opts.LineColor
+	coder.IsHyaline = opts.IsHyaline
+}
+
+// fetchWXACode 请求小程序码图片，响应为 JSON 时转换为 WXACodeError
+func (c *MiniProgramClient) fetchWXACode(urlFormat string, coder qrcode.QRCoder) ([]byte, error) {
+	accessToken, err := c.miniProgramIns.GetContext().GetAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := util.PostJSON(fmt.Sprintf(urlFormat, accessToken), coder)
+	if err != nil {
+		return nil, err
+	}
+
+	// 不依赖 Content-Type 判断，图片数据不会以 { 开头
+	if bytes.HasPrefix(bytes.TrimSpace(response), []byte("{")) {
+		var wxErr WXACodeError
+		if err := json.Unmarshal(response, &wxErr); err != nil {
+			return nil, fmt.Errorf("解析小程序码响应失败: %w", err)
+		}
+		if wxErr.ErrCode == 0 && wxErr.ErrMsg == "" {
+			wxErr.ErrMsg = "响应不是图片"
+		}
+		return nil, &wxErr
+	}
+
+	return response, nil
+}
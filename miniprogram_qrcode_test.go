@@ -0,0 +1,64 @@
+package wechat_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/darwinOrg/go-wechat"
+	"github.com/silenceper/wechat/v2/miniprogram/qrcode"
+)
+
+// TestMiniProgramClient_GetWXACode 测试获取小程序码及错误响应识别
+func TestMiniProgramClient_GetWXACode(t *testing.T) {
+	pngHeader := []byte("\x89PNG\r\n\x1a\n")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wxa/getwxacode", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["width"] != float64(280) || req["is_hyaline"] != true || req["line_color"] == nil {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 40169, "errmsg": "invalid length for scene"})
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(pngHeader)
+	})
+	var unlimitReq map[string]any
+	mux.HandleFunc("/wxa/getwxacodeunlimit", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&unlimitReq)
+		// 模拟微信以非 JSON Content-Type 返回错误信息
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write([]byte(`{"errcode":41030,"errmsg":"invalid page"}`))
+	})
+	miniClient := newMockMiniProgramClient(t, mux)
+
+	code, err := miniClient.GetWXACode("pages/index?a=1", &wechat.WXACodeOptions{
+		Width:     280,
+		LineColor: &qrcode.Color{R: "0", G: "0", B: "255"},
+		IsHyaline: true,
+	})
+	if err != nil {
+		t.Fatalf("GetWXACode failed: %v", err)
+	}
+	if string(code) != string(pngHeader) {
+		t.Fatalf("unexpected code bytes: %q", code)
+	}
+
+	_, err = miniClient.GetWXACode("pages/index", nil)
+	var wxErr *wechat.WXACodeError
+	if !errors.As(err, &wxErr) || wxErr.ErrCode != 40169 {
+		t.Fatalf("expected WXACodeError 40169, got %v", err)
+	}
+
+	_, err = miniClient.GetWXACodeUnlimit("pages/index", "a=1", false)
+	if !errors.As(err, &wxErr) || wxErr.ErrCode != 41030 {
+		t.Fatalf("expected WXACodeError 41030, got %v", err)
+	}
+
+	_, _ = miniClient.GetWXACodeUnlimitWithOptions("pages/detail", "a=2", false, &wechat.WXACodeOptions{Width: 280})
+	if unlimitReq["page"] != "pages/detail" || unlimitReq["path"] != "pages/detail" || unlimitReq["width"] != float64(280) {
+		t.Fatalf("unexpected unlimit request: %+v", unlimitReq)
+	}
+}
@@ -0,0 +1,227 @@
+package wechat
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/silenceper/wechat/v2/cache"
+)
+
+const (
+	// SceneMaxLength scene 参数最大长度
+	SceneMaxLength = 32
+	// sceneStoredPrefix 存储型 scene 的前缀，内联 scene 为 k=v 形式，不会以该字符开头
+	sceneStoredPrefix = "~"
+	// sceneKeyLength 存储型 scene 的 key 长度
+	sceneKeyLength = 16
+	// defaultSceneTTL 存储型 scene 默认保存时长
+	defaultSceneTTL = 30 * 24 * time.Hour
+	// sceneAllowedSymbols scene 支持的特殊字符
+	sceneAllowedSymbols = "!#$&'()*+,/:;=?@-._~"
+)
+
+var (
+	// ErrSceneTooLong scene 超过 32 个字符
+	ErrSceneTooLong = errors.New("scene 不能超过 32 个字符")
+	// ErrSceneInvalidChar scene 包含不支持的字符
+	ErrSceneInvalidChar = errors.New("scene 包含不支持的字符")
+	// ErrSceneNotFound 存储型 scene 对应的参数不存在或已过期
+	ErrSceneNotFound = errors.New("scene 不存在或已过期")
+)
+
+// ValidateScene 校验 scene 是否合法
+// scene 最多 32 个可见字符，只支持数字、大小写英文以及 !#$&'()*+,/:;=?@-._~
+func ValidateScene(scene string) error {
+	if utf8.RuneCountInString(scene) > SceneMaxLength {
+		return ErrSceneTooLong
+	}
+	for _, r := range scene {
+		if !isSceneChar(r) {
+			return fmt.Errorf("%w: %q", ErrSceneInvalidChar, r)
+		}
+	}
+
+	return nil
+}
+
+// isSceneChar 判断字符是否为 scene 支持的字符
+func isSceneChar(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
+		strings.ContainsRune(sceneAllowedSymbols, r)
+}
+
+// SceneStore 存储型 scene 的参数存储
+type SceneStore interface {
+	// Save 保存 scene key 对应的完整参数
+	Save(ctx context.Context, key, query string, ttl time.Duration) error
+	// Load 读取 scene key 对应的完整参数，不存在时返回 ErrSceneNotFound
+	Load(ctx context.Context, key string) (string, error)
+}
+
+// cacheSceneStore 基于 cache.Cache 的 scene 存储
+type cacheSceneStore struct {
+	cache     cache.Cache
+	keyPrefix string
+}
+
+// NewCacheSceneStore 创建基于 cache.Cache 的 scene 存储
+func NewCacheSceneStore(c cache.Cache, appID string) SceneStore {
+	return &cacheSceneStore{
+		cache:     c,
+		keyPrefix: "miniprogram:scene:" + appID + ":",
+	}
+}
+
+// Save 保存 scene key 对应的完整参数
+func (s *cacheSceneStore) Save(ctx context.Context, key, query string, ttl time.Duration) error {
+	return cache.SetContext(ctx, s.cache, s.keyPrefix+key, query, ttl)
+}
+
+// Load 读取 scene key 对应的完整参数
+func (s *cacheSceneStore) Load(ctx context.Context, key string) (string, error) {
+	if val, ok := cache.GetContext(ctx, s.cache, s.keyPrefix+key).(string); ok && val != "" {
+		return val, nil
+	}
+
+	return "", ErrSceneNotFound
+}
+
+// SceneCodec scene 编解码器
+// 参数较短且字符合法时直接内联为 k=v&k2=v2，否则将完整参数保存到 SceneStore，scene 中只放短 key
+type SceneCodec struct {
+	store SceneStore
+	ttl   time.Duration
+}
+
+// NewSceneCodec 创建 scene 编解码器
+// ttl 为存储型 scene 的保存时长，小于等于 0 时使用默认值 30 天
+func NewSceneCodec(store SceneStore, ttl time.Duration) *SceneCodec {
+	if ttl <= 0 {
+		ttl = defaultSceneTTL
+	}
+
+	return &SceneCodec{store: store, ttl: ttl}
+}
+
+// Encode 将参数编码为 scene
+// 相同参数总是得到相同的 scene，存储型 scene 的 key 由参数内容的哈希生成
+func (s *SceneCodec) Encode(ctx context.Context, params url.Values) (string, error) {
+	if len(params) == 0 {
+		return "", errors.New("scene 参数不能为空")
+	}
+
+	if scene, ok := inlineScene(params); ok {
+		return scene, nil
+	}
+
+	query := params.Encode()
+	sum := sha256.Sum256([]byte(query))
+	key := base64.RawURLEncoding.EncodeToString(sum[:])[:sceneKeyLength]
+	if err := s.store.Save(ctx, key, query, s.ttl); err != nil {
+		return "", fmt.Errorf("保存 scene 参数失败: %w", err)
+	}
+
+	return sceneStoredPrefix + key, nil
+}
+
+// Decode 将 scene 解码为参数
+// 兼容小程序端未 decodeURIComponent 直接上传的 scene
+func (s *SceneCodec) Decode(ctx context.Context, scene string) (url.Values, error) {
+	if strings.Contains(scene, "%") {
+		unescaped, err := url.QueryUnescape(scene)
+		if err != nil {
+			return nil, fmt.Errorf("scene 解码失败: %w", err)
+		}
+		scene = unescaped
+	}
+	if err := ValidateScene(scene); err != nil {
+		return nil, err
+	}
+
+	if key, ok := strings.CutPrefix(scene, sceneStoredPrefix); ok {
+		query, err := s.store.Load(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		return url.ParseQuery(query)
+	}
+
+	params := url.Values{}
+	for _, pair := range strings.Split(scene, "&") {
+		if pair == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(pair, "=")
+		params.Add(k, v)
+	}
+
+	return params, nil
+}
+
+// inlineScene 尝试将参数直接内联为 scene
+func inlineScene(params url.Values) (string, bool) {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		if k == "" || strings.ContainsAny(k, "=&") {
+			return "", false
+		}
+		for _, v := range params[k] {
+			if strings.ContainsAny(v, "=&") {
+				return "", false
+			}
+			if sb.Len() > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(k)
+			sb.WriteByte('=')
+			sb.WriteString(v)
+		}
+	}
+
+	scene := sb.String()
+	if strings.HasPrefix(scene, sceneStoredPrefix) || ValidateScene(scene) != nil {
+		return "", false
+	}
+
+	return scene, true
+}
+
+// SetSceneStore 自定义存储型 scene 的参数存储，默认使用客户端的缓存
+func (c *MiniProgramClient) SetSceneStore(store SceneStore, ttl time.Duration) {
+	c.sceneCodec = NewSceneCodec(store, ttl)
+}
+
+// GetSceneCodec 获取 scene 编解码器
+func (c *MiniProgramClient) GetSceneCodec() *SceneCodec {
+	return c.sceneCodec
+}
+
+// GetWXACodeUnlimitWithParams 使用任意长度的参数获取不限制数量的小程序码
+// 参数超出 scene 限制时自动保存到 SceneStore，小程序端需要调用 ResolveScene 还原参数
+func (c *MiniProgramClient) GetWXACodeUnlimitWithParams(ctx context.Context, page string, params url.Values, checkPath bool) ([]byte, error) {
+	scene, err := c.sceneCodec.Encode(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetWXACodeUnlimitContext(ctx, page, scene, checkPath)
+}
+
+// ResolveScene 将小程序码携带的 scene 还原为参数
+func (c *MiniProgramClient) ResolveScene(ctx context.Context, scene string) (url.Values, error) {
+	return c.sceneCodec.Decode(ctx, scene)
+}
@@ -0,0 +1,59 @@
+package wechat_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/darwinOrg/go-wechat"
+)
+
+// TestSceneCodec 测试 scene 内联与存储两种编码方式
+func TestSceneCodec(t *testing.T) {
+	miniClient := wechat.NewMiniProgramClient(&wechat.MiniProgramConfig{AppId: "wx_test_appid"})
+	codec := miniClient.GetSceneCodec()
+	ctx := context.Background()
+
+	short := url.Values{"id": {"123"}, "from": {"poster"}}
+	scene, err := codec.Encode(ctx, short)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if scene != "from=poster&id=123" {
+		t.Fatalf("unexpected inline scene: %s", scene)
+	}
+
+	long := url.Values{"invite_code": {"ABCDEFGHIJKLMNOPQRSTUVWXYZ"}, "channel": {"渠道"}}
+	scene, err = codec.Encode(ctx, long)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if err := wechat.ValidateScene(scene); err != nil {
+		t.Fatalf("encoded scene is invalid: %s, %v", scene, err)
+	}
+	if again, _ := codec.Encode(ctx, long); again != scene {
+		t.Fatalf("scene should be deterministic: %s != %s", again, scene)
+	}
+
+	for _, s := range []string{scene, url.QueryEscape(scene)} {
+		params, err := miniClient.ResolveScene(ctx, s)
+		if err != nil {
+			t.Fatalf("ResolveScene failed: %v", err)
+		}
+		if params.Encode() != long.Encode() {
+			t.Fatalf("unexpected params: %v", params)
+		}
+	}
+
+	if _, err := miniClient.ResolveScene(ctx, "~unknownscenekey0"); !errors.Is(err, wechat.ErrSceneNotFound) {
+		t.Fatalf("expected ErrSceneNotFound, got %v", err)
+	}
+	if err := wechat.ValidateScene(strings.Repeat("a", 33)); !errors.Is(err, wechat.ErrSceneTooLong) {
+		t.Fatalf("expected ErrSceneTooLong, got %v", err)
+	}
+	if err := wechat.ValidateScene("a=中文"); !errors.Is(err, wechat.ErrSceneInvalidChar) {
+		t.Fatalf("expected ErrSceneInvalidChar, got %v", err)
+	}
+}
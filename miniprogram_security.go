@@ -0,0 +1,282 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/silenceper/wechat/v2/cache"
+	"github.com/silenceper/wechat/v2/miniprogram/security"
+)
+
+// defaultMediaCheckTraceTTL 异步检测记录的保存时长，微信通常在 30 分钟内推送检测结果
+const defaultMediaCheckTraceTTL = 24 * time.Hour
+
+// ErrMediaCheckTraceNotFound 未找到 trace_id 对应的检测记录
+var ErrMediaCheckTraceNotFound = errors.New("未找到 trace_id 对应的检测记录")
+
+// SecCheckScene 内容安全检测场景
+type SecCheckScene = security.MsgScene
+
+const (
+	SecCheckSceneProfile   = security.MsgSceneMaterial  // 资料
+	SecCheckSceneComment   = security.MsgSceneComment   // 评论
+	SecCheckSceneForum     = security.MsgSceneForum     // 论坛
+	SecCheckSceneSocialLog = security.MsgSceneSocialLog // 社交日志
+)
+
+// SecCheckSuggest 内容安全检测建议
+type SecCheckSuggest = security.CheckSuggest
+
+const (
+	SecCheckSuggestPass   = security.CheckSuggestPass   // 通过
+	SecCheckSuggestReview = security.CheckSuggestReview // 需要人工审核
+	SecCheckSuggestRisky  = security.CheckSuggestRisky  // 有违规风险
+)
+
+// SecCheckLabel 内容安全检测命中标签，100 为正常
+type SecCheckLabel = security.CheckLabel
+
+// MediaCheckType 异步检测的媒体类型
+type MediaCheckType uint8
+
+const (
+	MediaCheckTypeAudio MediaCheckType = 1 // 音频
+	MediaCheckTypeImage MediaCheckType = 2 // 图片
+)
+
+// MsgSecCheckRequest 文本内容安全检测请求
+type MsgSecCheckRequest struct {
+	OpenID    string        // 用户的 openid，用户需在近两小时访问过小程序
+	Scene     SecCheckScene // 检测场景
+	Content   string        // 需检测的文本内容，最多 2500 字
+	Title     string        // 文本标题，可选
+	Nickname  string        // 用户昵称，可选
+	Signature string        // 个性签名，仅资料场景有效，可选
+}
+
+// SecCheckResult 内容安全检测结果
+type SecCheckResult struct {
+	TraceID string           // 唯一请求标识
+	Suggest SecCheckSuggest  // 综合建议
+	Label   SecCheckLabel    // 综合命中标签
+	Details []SecCheckDetail // 详细检测结果
+}
+
+// SecCheckDetail 内容安全检测详细结果
+type SecCheckDetail struct {
+	Strategy string          // 策略类型
+	ErrCode  int64           // 错误码，仅当该值为 0 时该项结果有效
+	Suggest  SecCheckSuggest // 建议
+	Label    SecCheckLabel   // 命中标签
+	Prob     uint            // 置信度，0-100
+	Keyword  string          // 命中的自定义关键词
+}
+
+// Passed 是否通过检测
+func (r *SecCheckResult) Passed() bool {
+	return r.Suggest == SecCheckSuggestPass
+}
+
+// MsgSecCheck 检测文本是否含有违法违规内容（2.0 版本）
+func (c *MiniProgramClient) MsgSecCheck(ctx context.Context, req *MsgSecCheckRequest) (*SecCheckResult, error) {
+	if req.OpenID == "" || req.Content == "" {
+		return nil, errors.New("openid 和 content 不能为空")
+	}
+
+	resp, err := c.miniProgramIns.GetSecurity().MsgCheckContext(ctx, &security.MsgCheckRequest{
+		OpenID:    req.OpenID,
+		Scene:     req.Scene,
+		Content:   req.Content,
+		Nickname:  req.Nickname,
+		Title:     req.Title,
+		Signature: req.Signature,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SecCheckResult{
+		TraceID: resp.TraceID,
+		Suggest: resp.Result.Suggest,
+		Label:   resp.Result.Label,
+	}
+	for _, d := range resp.Detail {
+		result.Details = append(result.Details, SecCheckDetail{
+			Strategy: d.Strategy,
+			ErrCode:  d.ErrCode,
+			Suggest:  SecCheckSuggest(d.Suggest),
+			Label:    d.Label,
+			Prob:     d.Prob,
+			Keyword:  d.Keyword,
+		})
+	}
+
+	return result, nil
+}
+
+// MediaCheckRequest 图片/音频异步检测请求
+type MediaCheckRequest struct {
+	MediaURL  string         // 要检测的图片或音频的 url
+	MediaType MediaCheckType // 媒体类型
+	OpenID    string         // 用户的 openid，用户需在近两小时访问过小程序
+	Scene     SecCheckScene  // 检测场景
+	BizID     string         // 业务ID，可选，检测结果回调时原样带回，用于关联业务数据
+}
+
+// MediaCheckRecord 异步检测请求记录，通过 trace_id 关联检测结果
+type MediaCheckRecord struct {
+	TraceID    string         `json:"trace_id"`
+	MediaURL   string         `json:"media_url"`
+	MediaType  MediaCheckType `json:"media_type"`
+	OpenID     string         `json:"openid"`
+	Scene      SecCheckScene  `json:"scene"`
+	BizID      string         `json:"biz_id"`
+	CreateTime int64          `json:"create_time"`
+}
+
+// MediaCheckTraceStore 异步检测记录存储
+type MediaCheckTraceStore interface {
+	// Save 保存检测记录
+	Save(ctx context.Context, record *MediaCheckRecord) error
+	// Load 根据 trace_id 读取检测记录，不存在时返回 ErrMediaCheckTraceNotFound
+	Load(ctx context.Context, traceID string) (*MediaCheckRecord, error)
+	// Delete 删除检测记录
+	Delete(ctx context.Context, traceID string) error
+}
+
+// cacheMediaCheckTraceStore 基于 cache.Cache 的异步检测记录存储
+type cacheMediaCheckTraceStore struct {
+	cache     cache.Cache
+	keyPrefix string
+	ttl       time.Duration
+}
+
+// NewCacheMediaCheckTraceStore 创建基于 cache.Cache 的异步检测记录存储
+// ttl 小于等于 0 时使用默认值 24 小时
+func NewCacheMediaCheckTraceStore(c cache.Cache, appID string, ttl time.Duration) MediaCheckTraceStore {
+	if ttl <= 0 {
+		ttl = defaultMediaCheckTraceTTL
+	}
+
+	return &cacheMediaCheckTraceStore{
+		cache:     c,
+		keyPrefix: "miniprogram:media_check:" + appID + ":",
+		ttl:       ttl,
+	}
+}
+
+// Save 保存检测记录
+func (s *cacheMediaCheckTraceStore) Save(ctx context.Context, record *MediaCheckRecord) error {
+	return cacheSetJSON(ctx, s.cache, s.keyPrefix+record.TraceID, record, s.ttl)
+}
+
+// Load 根据 trace_id 读取检测记录
+func (s *cacheMediaCheckTraceStore) Load(ctx context.Context, traceID string) (*MediaCheckRecord, error) {
+	var record MediaCheckRecord
+	if !cacheGetJSON(ctx, s.cache, s.keyPrefix+traceID, &record) {
+		return nil, ErrMediaCheckTraceNotFound
+	}
+
+	return &record, nil
+}
+
+// Delete 删除检测记录
+func (s *cacheMediaCheckTraceStore) Delete(ctx context.Context, traceID string) error {
+	return cache.DeleteContext(ctx, s.cache, s.keyPrefix+traceID)
+}
+
+// SetMediaCheckTraceStore 自定义异步检测记录存储，默认使用客户端的缓存
+func (c *MiniProgramClient) SetMediaCheckTraceStore(store MediaCheckTraceStore) {
+	c.mediaCheckTraces = store
+}
+
+// GetMediaCheckTraceStore 获取异步检测记录存储
+func (c *MiniProgramClient) GetMediaCheckTraceStore() MediaCheckTraceStore {
+	return c.mediaCheckTraces
+}
+
+// MediaCheck 异步检测图片/音频是否含有违法违规内容（2.0 版本）
+// 返回 trace_id，检测结果通过消息推送的 wxa_media_check 事件返回
+func (c *MiniProgramClient) MediaCheck(ctx context.Context, req *MediaCheckRequest) (string, error) {
+	if req.MediaURL == "" || req.OpenID == "" {
+		return "", errors.New("media_url 和 openid 不能为空")
+	}
+
+	traceID, err := c.miniProgramIns.GetSecurity().MediaCheckAsyncContext(ctx, &security.MediaCheckAsyncRequest{
+		MediaURL:  req.MediaURL,
+		MediaType: uint8(req.MediaType),
+		OpenID:    req.OpenID,
+		Scene:     uint8(req.Scene),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	record := &MediaCheckRecord{
+		TraceID:    traceID,
+		MediaURL:   req.MediaURL,
+		MediaType:  req.MediaType,
+		OpenID:     req.OpenID,
+		Scene:      req.Scene,
+		BizID:      req.BizID,
+		CreateTime: time.Now().Unix(),
+	}
+	if err := c.mediaCheckTraces.Save(ctx, record); err != nil {
+		return traceID, fmt.Errorf("保存检测记录失败: %w", err)
+	}
+
+	return traceID, nil
+}
+
+// MediaCheckResult 异步检测结果
+type MediaCheckResult struct {
+	TraceID string           // 检测请求返回的 trace_id
+	Suggest SecCheckSuggest  // 综合建议
+	Label   SecCheckLabel    // 综合命中标签
+	Details []SecCheckDetail // 详细检测结果
+}
+
+// Passed 是否通过检测
+func (r *MediaCheckResult) Passed() bool {
+	return r.Suggest == SecCheckSuggestPass
+}
+
+// MediaCheckResultHandler 异步检测结果处理函数
+type MediaCheckResultHandler func(ctx context.Context, record *MediaCheckRecord, result *MediaCheckResult) error
+
+// MediaCheckCallback 异步检测结果回调，通过 trace_id 找回原始请求后交给处理函数
+type MediaCheckCallback struct {
+	store   MediaCheckTraceStore
+	handler MediaCheckResultHandler
+}
+
+// NewMediaCheckCallback 创建异步检测结果回调
+func NewMediaCheckCallback(store MediaCheckTraceStore, handler MediaCheckResultHandler) *MediaCheckCallback {
+	return &MediaCheckCallback{store: store, handler: handler}
+}
+
+// NewMediaCheckCallback 使用客户端的检测记录存储创建异步检测结果回调
+func (c *MiniProgramClient) NewMediaCheckCallback(handler MediaCheckResultHandler) *MediaCheckCallback {
+	return NewMediaCheckCallback(c.mediaCheckTraces, handler)
+}
+
+// Handle 处理异步检测结果
+// 处理成功后删除检测记录，微信重复推送已处理的结果，或 trace_id 未知、记录已过期时视为已处理并返回 nil
+// 避免微信对无法处理的结果持续重试
+func (cb *MediaCheckCallback) Handle(ctx context.Context, result *MediaCheckResult) error {
+	record, err := cb.store.Load(ctx, result.TraceID)
+	if errors.Is(err, ErrMediaCheckTraceNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := cb.handler(ctx, record, result); err != nil {
+		return err
+	}
+
+	return cb.store.Delete(ctx, result.TraceID)
+}
@@ -0,0 +1,69 @@
+package wechat_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/darwinOrg/go-wechat"
+)
+
+// TestMiniProgramClient_MediaCheck 测试异步检测请求与回调结果的关联
+func TestMiniProgramClient_MediaCheck(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wxa/msg_sec_check", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"errcode":  0,
+			"trace_id": "msg_trace",
+			"result":   map[string]any{"suggest": "risky", "label": 20001},
+			"detail":   []map[string]any{{"strategy": "content_model", "errcode": 0, "suggest": "risky", "label": 20001, "prob": 90}},
+		})
+	})
+	mux.HandleFunc("/wxa/media_check_async", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "trace_id": "media_trace"})
+	})
+	miniClient := newMockMiniProgramClient(t, mux)
+	ctx := context.Background()
+
+	msgResult, err := miniClient.MsgSecCheck(ctx, &wechat.MsgSecCheckRequest{
+		OpenID:  "test_openid",
+		Scene:   wechat.SecCheckSceneComment,
+		Content: "test content",
+	})
+	if err != nil {
+		t.Fatalf("MsgSecCheck failed: %v", err)
+	}
+	if msgResult.Passed() || msgResult.Label.String() != "时政" || len(msgResult.Details) != 1 {
+		t.Fatalf("unexpected result: %+v", msgResult)
+	}
+
+	traceID, err := miniClient.MediaCheck(ctx, &wechat.MediaCheckRequest{
+		MediaURL:  "https://example.com/a.png",
+		MediaType: wechat.MediaCheckTypeImage,
+		OpenID:    "test_openid",
+		Scene:     wechat.SecCheckSceneProfile,
+		BizID:     "avatar_1",
+	})
+	if err != nil || traceID != "media_trace" {
+		t.Fatalf("MediaCheck failed: %s, %v", traceID, err)
+	}
+
+	var handledBizID string
+	callback := miniClient.NewMediaCheckCallback(func(ctx context.Context, record *wechat.MediaCheckRecord, result *wechat.MediaCheckResult) error {
+		handledBizID = record.BizID
+		return nil
+	})
+	result := &wechat.MediaCheckResult{TraceID: "media_trace", Suggest: wechat.SecCheckSuggestPass, Label: 100}
+	if err := callback.Handle(ctx, result); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if handledBizID != "avatar_1" {
+		t.Fatalf("unexpected biz id: %s", handledBizID)
+	}
+	// 重复推送已处理的结果时视为成功，不再调用处理函数
+	handledBizID = ""
+	if err := callback.Handle(ctx, result); err != nil || handledBizID != "" {
+		t.Fatalf("redelivery should be acked without handling: %q, %v", handledBizID, err)
+	}
+}
@@ -0,0 +1,381 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/silenceper/wechat/v2/miniprogram/order"
+	"github.com/silenceper/wechat/v2/util"
+)
+
+const (
+	uploadShippingInfoURL         = "https://api.weixin.qq.com/wxa/sec/order/upload_shipping_info?access_token=%s"
+	uploadCombinedShippingInfoURL = "https://api.weixin.qq.com/wxa/sec/order/upload_combined_shipping_info?access_token=%s"
+	getShippingOrderURL           = "https://api.weixin.qq.com/wxa/sec/order/get_order?access_token=%s"
+	getShippingOrderListURL       = "https://api.weixin.qq.com/wxa/sec/order/get_order_list?access_token=%s"
+	notifyConfirmReceiveURL       = "https://api.weixin.qq.com/wxa/sec/order/notify_confirm_receive?access_token=%s"
+	setMsgJumpPathURL             = "https://api.weixin.qq.com/wxa/sec/order/set_msg_jump_path?access_token=%s"
+	isTradeManagedURL             = "https://api.weixin.qq.com/wxa/sec/order/is_trade_managed?access_token=%s"
+)
+
+const (
+	// shippingListMaxLen 单个订单最多上传的物流单数量
+	shippingListMaxLen = 10
+	// shippingItemDescMaxLen 商品信息最大长度
+	shippingItemDescMaxLen = 120
+	// shippingExpressSF 顺丰的物流公司编码，顺丰发货时必须填写联系方式
+	shippingExpressSF = "SF"
+)
+
+// ShippingLogisticsType 物流模式
+type ShippingLogisticsType = order.LogisticsType
+
+const (
+	ShippingLogisticsExpress    = order.LogisticsTypeExpress    // 实体物流配送，使用快递公司发货
+	ShippingLogisticsSameCity   = order.LogisticsTypeSameCity   // 同城配送
+	ShippingLogisticsVirtual    = order.LogisticsTypeVirtual    // 虚拟商品，无需物流配送
+	ShippingLogisticsSelfPickup = order.LogisticsTypeSelfPickup // 用户自提
+)
+
+// ShippingDeliveryMode 发货模式
+type ShippingDeliveryMode = order.DeliveryMode
+
+const (
+	ShippingDeliveryUnified = order.DeliveryModeUnifiedDelivery // 统一发货
+	ShippingDeliverySplit   = order.DeliveryModeSplitDelivery   // 分拆发货
+)
+
+// ShippingOrderNumberType 订单单号类型
+type ShippingOrderNumberType = order.NumberType
+
+const (
+	ShippingOrderNumberOutTradeNo    = order.NumberTypeOutTradeNo    // 使用下单商户号和商户侧单号
+	ShippingOrderNumberTransactionID = order.NumberTypeTransactionID // 使用微信支付单号
+)
+
+// ShippingOrderState 订单状态
+type ShippingOrderState = order.State
+
+const (
+	ShippingOrderStateWaitShipment = order.StateWaitShipment // 待发货
+	ShippingOrderStateShipped      = order.StateShipped      // 已发货
+	ShippingOrderStateConfirm      = order.StateConfirm      // 确认收货
+	ShippingOrderStateComplete     = order.StateComplete     // 交易完成
+	ShippingOrderStateRefund       = order.StateRefund       // 已退款
+)
+
+// ShippingOrder 订单及发货信息
+type ShippingOrder = order.ShippingOrder
+
+// ShippingOrderKey 需要上传发货信息的订单
+type ShippingOrderKey struct {
+	OrderNumberType ShippingOrderNumberType `json:"order_number_type"`        // 订单单号类型
+	TransactionID   string                  `json:"transaction_id,omitempty"` // 微信支付单号，单号类型为 2 时必填
+	MchID           string                  `json:"mchid,omitempty"`          // 下单商户号，单号类型为 1 时必填
+	OutTradeNo      string                  `json:"out_trade_no,omitempty"`   // 商户侧单号，单号类型为 1 时必填
+}
+
+// Validate 校验订单单号
+func (k *ShippingOrderKey) Validate() error {
+	switch k.OrderNumberType {
+	case ShippingOrderNumberOutTradeNo:
+		if k.MchID == "" || k.OutTradeNo == "" {
+			return errors.New("使用商户侧单号时 mchid 和 out_trade_no 不能为空")
+		}
+	case ShippingOrderNumberTransactionID:
+		if k.TransactionID == "" {
+			return errors.New("使用微信支付单号时 transaction_id 不能为空")
+		}
+	default:
+		return fmt.Errorf("不支持的订单单号类型: %d", k.OrderNumberType)
+	}
+
+	return nil
+}
+
+// ShippingContact 联系方式，采用掩码传输，最后 4 位数字不能打掩码
+type ShippingContact struct {
+	ConsignorContact string `json:"consignor_contact,omitempty"` // 寄件人联系方式
+	ReceiverContact  string `json:"receiver_contact,omitempty"`  // 收件人联系方式
+}
+
+// ShippingInfo 物流单信息
+type ShippingInfo struct {
+	TrackingNo     string           `json:"tracking_no,omitempty"`     // 物流单号，快递发货时必填
+	ExpressCompany string           `json:"express_company,omitempty"` // 物流公司编码，快递发货时必填
+	ItemDesc       string           `json:"item_desc"`                 // 商品信息，限 120 个字以内
+	Contact        *ShippingContact `json:"contact,omitempty"`         // 联系方式，顺丰发货时必填，寄件人或收件人二选一
+}
+
+// validate 校验物流单信息
+func (s *ShippingInfo) validate(logisticsType ShippingLogisticsType) error {
+	var errs []error
+	if s.ItemDesc == "" {
+		errs = append(errs, errors.New("item_desc 不能为空"))
+	} else if utf8.RuneCountInString(s.ItemDesc) > shippingItemDescMaxLen {
+		errs = append(errs, fmt.Errorf("item_desc 最多 %d 个字", shippingItemDescMaxLen))
+	}
+	if logisticsType == ShippingLogisticsExpress {
+		if s.TrackingNo == "" || s.ExpressCompany == "" {
+			errs = append(errs, errors.New("快递发货时 tracking_no 和 express_company 不能为空"))
+		}
+		if s.ExpressCompany == shippingExpressSF && (s.Contact == nil || (s.Contact.ConsignorContact == "" && s.Contact.ReceiverContact == "")) {
+			errs = append(errs, errors.New("顺丰发货时必须填写寄件人或收件人联系方式"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ShippingDelivery 订单的发货方式和物流单
+type ShippingDelivery struct {
+	LogisticsType  ShippingLogisticsType `json:"logistics_type"`   // 物流模式
+	DeliveryMode   ShippingDeliveryMode  `json:"delivery_mode"`    // 发货模式
+	IsAllDelivered bool                  `json:"is_all_delivered"` // 分拆发货时是否已全部发货完成
+	ShippingList   []*ShippingInfo       `json:"shipping_list"`    // 物流单列表，统一发货只能有一个，分拆发货最多 10 个
+}
+
+// validate 校验发货方式和物流单
+func (d *ShippingDelivery) validate() error {
+	var errs []error
+	if d.LogisticsType < ShippingLogisticsExpress || d.LogisticsType > ShippingLogisticsSelfPickup {
+		errs = append(errs, fmt.Errorf("不支持的物流模式: %d", d.LogisticsType))
+	}
+	switch d.DeliveryMode {
+	case ShippingDeliveryUnified:
+		if len(d.ShippingList) != 1 {
+			errs = append(errs, errors.New("统一发货时 shipping_list 只能有一个物流单"))
+		}
+	case ShippingDeliverySplit:
+		if len(d.ShippingList) == 0 || len(d.ShippingList) > shippingListMaxLen {
+			errs = append(errs, fmt.Errorf("分拆发货时 shipping_list 需要 1 到 %d 个物流单", shippingListMaxLen))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("不支持的发货模式: %d", d.DeliveryMode))
+	}
+	for i, s := range d.ShippingList {
+		if err := s.validate(d.LogisticsType); err != nil {
+			errs = append(errs, fmt.Errorf("shipping_list[%d]: %w", i, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ShippingPayer 支付者信息
+type ShippingPayer struct {
+	OpenID string `json:"openid"` // 支付者 openid
+}
+
+// UploadShippingInfoRequest 发货信息录入请求
+type UploadShippingInfoRequest struct {
+	OrderKey *ShippingOrderKey `json:"order_key"` // 订单
+	ShippingDelivery
+	UploadTime string         `json:"upload_time"` // 上传时间，RFC 3339 格式，为空时使用当前时间
+	Payer      *ShippingPayer `json:"payer"`       // 支付者信息
+}
+
+// Validate 校验发货信息
+func (r *UploadShippingInfoRequest) Validate() error {
+	var errs []error
+	if r.OrderKey == nil {
+		errs = append(errs, errors.New("order_key 不能为空"))
+	} else if err := r.OrderKey.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if r.Payer == nil || r.Payer.OpenID == "" {
+		errs = append(errs, errors.New("payer.openid 不能为空"))
+	}
+	if err := r.ShippingDelivery.validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// ShippingSubOrder 合单中的子订单
+type ShippingSubOrder struct {
+	OrderKey *ShippingOrderKey `json:"order_key"` // 子订单
+	ShippingDelivery
+}
+
+// UploadCombinedShippingInfoRequest 合单发货信息录入请求
+type UploadCombinedShippingInfoRequest struct {
+	OrderKey   *ShippingOrderKey   `json:"order_key"`   // 合单订单
+	SubOrders  []*ShippingSubOrder `json:"sub_orders"`  // 子订单发货信息
+	UploadTime string              `json:"upload_time"` // 上传时间，RFC 3339 格式，为空时使用当前时间
+	Payer      *ShippingPayer      `json:"payer"`       // 支付者信息
+}
+
+// Validate 校验合单发货信息
+func (r *UploadCombinedShippingInfoRequest) Validate() error {
+	var errs []error
+	if r.OrderKey == nil {
+		errs = append(errs, errors.New("order_key 不能为空"))
+	} else if err := r.OrderKey.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if r.Payer == nil || r.Payer.OpenID == "" {
+		errs = append(errs, errors.New("payer.openid 不能为空"))
+	}
+	if len(r.SubOrders) == 0 {
+		errs = append(errs, errors.New("sub_orders 不能为空"))
+	}
+	for i, sub := range r.SubOrders {
+		if sub.OrderKey == nil {
+			errs = append(errs, fmt.Errorf("sub_orders[%d]: order_key 不能为空", i))
+		} else if err := sub.OrderKey.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("sub_orders[%d]: %w", i, err))
+		}
+		if err := sub.ShippingDelivery.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("sub_orders[%d]: %w", i, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// UploadShippingInfo 发货信息录入
+func (c *MiniProgramClient) UploadShippingInfo(ctx context.Context, req *UploadShippingInfoRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	// 在副本上补充上传时间，避免修改调用方的请求，重试时使用新的时间
+	payload := *req
+	if payload.UploadTime == "" {
+		payload.UploadTime = time.Now().Format(time.RFC3339Nano)
+	}
+
+	return c.postJSON(ctx, uploadShippingInfoURL, &payload, &commonResponse{}, "UploadShippingInfo")
+}
+
+// UploadCombinedShippingInfo 合单发货信息录入
+func (c *MiniProgramClient) UploadCombinedShippingInfo(ctx context.Context, req *UploadCombinedShippingInfoRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	// 在副本上补充上传时间，避免修改调用方的请求，重试时使用新的时间
+	payload := *req
+	if payload.UploadTime == "" {
+		payload.UploadTime = time.Now().Format(time.RFC3339Nano)
+	}
+
+	return c.postJSON(ctx, uploadCombinedShippingInfoURL, &payload, &commonResponse{}, "UploadCombinedShippingInfo")
+}
+
+// ShippingOrderQuery 查询订单的单号，使用微信支付单号或商户号加商户侧单号
+type ShippingOrderQuery struct {
+	TransactionID   string `json:"transaction_id,omitempty"`    // 微信支付单号
+	MerchantID      string `json:"merchant_id,omitempty"`       // 下单商户号
+	SubMerchantID   string `json:"sub_merchant_id,omitempty"`   // 二级商户号
+	MerchantTradeNo string `json:"merchant_trade_no,omitempty"` // 商户侧单号
+}
+
+// validate 校验查询单号
+func (q *ShippingOrderQuery) validate() error {
+	if q.TransactionID == "" && (q.MerchantID == "" || q.MerchantTradeNo == "") {
+		return errors.New("transaction_id 或 merchant_id 和 merchant_trade_no 不能为空")
+	}
+
+	return nil
+}
+
+// GetShippingOrder 查询订单发货状态
+func (c *MiniProgramClient) GetShippingOrder(ctx context.Context, query *ShippingOrderQuery) (*ShippingOrder, error) {
+	if err := query.validate(); err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		util.CommonError
+		Order ShippingOrder `json:"order"`
+	}
+	if err := c.postJSON(ctx, getShippingOrderURL, query, &resp, "GetShippingOrder"); err != nil {
+		return nil, err
+	}
+
+	return &resp.Order, nil
+}
+
+// ShippingOrderListRequest 查询订单列表请求
+type ShippingOrderListRequest struct {
+	PayTimeBegin int64              // 支付时间范围开始，时间戳，可选
+	PayTimeEnd   int64              // 支付时间范围结束，时间戳，可选
+	OrderState   ShippingOrderState // 订单状态，可选
+	OpenID       string             // 支付者 openid，可选
+	LastIndex    string             // 翻页游标，获取第一页时不填
+	PageSize     int64              // 每页数量，最多 50 条
+}
+
+// ShippingOrderListResult 订单列表
+type ShippingOrderListResult struct {
+	Orders    []*ShippingOrder // 订单列表
+	LastIndex string           // 下一页的翻页游标
+	HasMore   bool             // 是否还有更多
+}
+
+// ListShippingOrders 查询订单列表
+func (c *MiniProgramClient) ListShippingOrders(ctx context.Context, req *ShippingOrderListRequest) (*ShippingOrderListResult, error) {
+	listReq := &order.GetShippingOrderListRequest{
+		OrderState: req.OrderState,
+		Openid:     req.OpenID,
+		LastIndex:  req.LastIndex,
+		PageSize:   req.PageSize,
+	}
+	if req.PayTimeBegin > 0 || req.PayTimeEnd > 0 {
+		listReq.PayTimeRange = &order.TimeRange{BeginTime: req.PayTimeBegin, EndTime: req.PayTimeEnd}
+	}
+
+	var resp order.GetShippingOrderListResponse
+	if err := c.postJSON(ctx, getShippingOrderListURL, listReq, &resp, "ListShippingOrders"); err != nil {
+		return nil, err
+	}
+
+	return &ShippingOrderListResult{
+		Orders:    resp.OrderList,
+		LastIndex: resp.LastIndex,
+		HasMore:   resp.HasMore,
+	}, nil
+}
+
+// NotifyConfirmReceive 确认收货提醒，快递送达后提醒用户确认收货，每个订单只能调用一次
+// receivedTime: 快递签收时间
+func (c *MiniProgramClient) NotifyConfirmReceive(ctx context.Context, query *ShippingOrderQuery, receivedTime time.Time) error {
+	if err := query.validate(); err != nil {
+		return err
+	}
+
+	return c.postJSON(ctx, notifyConfirmReceiveURL, &order.NotifyConfirmReceiveRequest{
+		TransactionID:   query.TransactionID,
+		MerchantID:      query.MerchantID,
+		SubMerchantID:   query.SubMerchantID,
+		MerchantTradeNo: query.MerchantTradeNo,
+		ReceivedTime:    receivedTime.Unix(),
+	}, &commonResponse{}, "NotifyConfirmReceive")
+}
+
+// SetShippingMsgJumpPath 设置发货消息跳转路径，用户点击发货、确认收货等消息时进入该页面
+// path: 小程序页面路径，页面会携带 merchant_id、merchant_trade_no、transaction_id 参数
+func (c *MiniProgramClient) SetShippingMsgJumpPath(ctx context.Context, path string) error {
+	if path == "" {
+		return errors.New("path 不能为空")
+	}
+
+	return c.postJSON(ctx, setMsgJumpPathURL, map[string]string{"path": path}, &commonResponse{}, "SetShippingMsgJumpPath")
+}
+
+// IsTradeManaged 查询小程序是否已开通发货信息管理服务
+func (c *MiniProgramClient) IsTradeManaged(ctx context.Context) (bool, error) {
+	var resp struct {
+		util.CommonError
+		IsTradeManaged bool `json:"is_trade_managed"`
+	}
+	if err := c.postJSON(ctx, isTradeManagedURL, map[string]string{"appid": c.config.AppId}, &resp, "IsTradeManaged"); err != nil {
+		return false, err
+	}
+
+	return resp.IsTradeManaged, nil
+}
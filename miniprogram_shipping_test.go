@@ -0,0 +1,90 @@
+package wechat_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/darwinOrg/go-wechat"
+)
+
+// TestUploadShippingInfoRequest_Validate 测试发货信息必填物流字段校验
+func TestUploadShippingInfoRequest_Validate(t *testing.T) {
+	req := &wechat.UploadShippingInfoRequest{
+		OrderKey: &wechat.ShippingOrderKey{OrderNumberType: wechat.ShippingOrderNumberTransactionID, TransactionID: "tx_1"},
+		ShippingDelivery: wechat.ShippingDelivery{
+			LogisticsType: wechat.ShippingLogisticsExpress,
+			DeliveryMode:  wechat.ShippingDeliveryUnified,
+			ShippingList:  []*wechat.ShippingInfo{{TrackingNo: "SF123", ExpressCompany: "SF", ItemDesc: "抱枕*1"}},
+		},
+		Payer: &wechat.ShippingPayer{OpenID: "test_openid"},
+	}
+	if err := req.Validate(); err == nil {
+		t.Fatal("expected error for SF shipping without contact")
+	}
+
+	req.ShippingList[0].Contact = &wechat.ShippingContact{ReceiverContact: "189****1234"}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req.DeliveryMode = wechat.ShippingDeliveryUnified
+	req.ShippingList = append(req.ShippingList, &wechat.ShippingInfo{ItemDesc: "抱枕*1"})
+	if err := req.Validate(); err == nil {
+		t.Fatal("expected error for unified delivery with multiple shipping items")
+	}
+}
+
+// TestMiniProgramClient_Shipping 测试发货信息录入与订单查询
+func TestMiniProgramClient_Shipping(t *testing.T) {
+	var uploaded map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wxa/sec/order/upload_combined_shipping_info", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&uploaded)
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0})
+	})
+	mux.HandleFunc("/wxa/sec/order/get_order", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"errcode": 0,
+			"order":   map[string]any{"transaction_id": "tx_1", "order_state": 2, "paid_amount": 100},
+		})
+	})
+	miniClient := newMockMiniProgramClient(t, mux)
+	ctx := context.Background()
+
+	req := &wechat.UploadCombinedShippingInfoRequest{
+		OrderKey: &wechat.ShippingOrderKey{OrderNumberType: wechat.ShippingOrderNumberOutTradeNo, MchID: "mch_1", OutTradeNo: "combine_1"},
+		SubOrders: []*wechat.ShippingSubOrder{{
+			OrderKey: &wechat.ShippingOrderKey{OrderNumberType: wechat.ShippingOrderNumberOutTradeNo, MchID: "mch_1", OutTradeNo: "sub_1"},
+			ShippingDelivery: wechat.ShippingDelivery{
+				LogisticsType: wechat.ShippingLogisticsVirtual,
+				DeliveryMode:  wechat.ShippingDeliveryUnified,
+				ShippingList:  []*wechat.ShippingInfo{{ItemDesc: "会员月卡"}},
+			},
+		}},
+		Payer: &wechat.ShippingPayer{OpenID: "test_openid"},
+	}
+	if err := miniClient.UploadCombinedShippingInfo(ctx, req); err != nil {
+		t.Fatalf("UploadCombinedShippingInfo failed: %v", err)
+	}
+	subOrders, _ := uploaded["sub_orders"].([]any)
+	if len(subOrders) != 1 || uploaded["upload_time"] == "" {
+		t.Fatalf("unexpected upload body: %+v", uploaded)
+	}
+	// 上传时间只写入请求副本，不修改调用方的请求
+	if req.UploadTime != "" {
+		t.Fatalf("request should not be modified, upload_time=%s", req.UploadTime)
+	}
+	if sub := subOrders[0].(map[string]any); sub["logistics_type"] != float64(3) || sub["delivery_mode"] != float64(1) {
+		t.Fatalf("unexpected sub order: %+v", sub)
+	}
+
+	order, err := miniClient.GetShippingOrder(ctx, &wechat.ShippingOrderQuery{TransactionID: "tx_1"})
+	if err != nil {
+		t.Fatalf("GetShippingOrder failed: %v", err)
+	}
+	if order.OrderState != wechat.ShippingOrderStateShipped || order.PaidAmount != 100 {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+}
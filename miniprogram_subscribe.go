@@ -0,0 +1,367 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/silenceper/wechat/v2/miniprogram/subscribe"
+	"github.com/silenceper/wechat/v2/util"
+)
+
+const (
+	subscribeSendURL        = "https://api.weixin.qq.com/cgi-bin/message/subscribe/send?access_token=%s"
+	subscribeGetTemplateURL = "https://api.weixin.qq.com/wxaapi/newtmpl/gettemplate?access_token=%s"
+	subscribeAddTemplateURL = "https://api.weixin.qq.com/wxaapi/newtmpl/addtemplate?access_token=%s"
+	subscribeDelTemplateURL = "https://api.weixin.qq.com/wxaapi/newtmpl/deltemplate?access_token=%s"
+)
+
+// SubscribeFieldType 订阅消息模板参数类型，由参数名去掉末尾序号得到，如 thing1 的类型为 thing
+type SubscribeFieldType string
+
+const (
+	SubscribeFieldThing           SubscribeFieldType = "thing"            // 事物，20 个以内字符
+	SubscribeFieldNumber          SubscribeFieldType = "number"           // 数字，32 位以内数字，可带小数
+	SubscribeFieldLetter          SubscribeFieldType = "letter"           // 字母，32 位以内字母
+	SubscribeFieldSymbol          SubscribeFieldType = "symbol"           // 符号，5 位以内符号
+	SubscribeFieldCharacterString SubscribeFieldType = "character_string" // 字符串，32 位以内数字、字母或符号
+	SubscribeFieldTime            SubscribeFieldType = "time"             // 时间，24 小时制时间格式
+	SubscribeFieldDate            SubscribeFieldType = "date"             // 日期，年月日格式
+	SubscribeFieldAmount          SubscribeFieldType = "amount"           // 金额，1 个币种符号 + 10 位以内纯数字，可带小数，结尾可带“元”
+	SubscribeFieldPhoneNumber     SubscribeFieldType = "phone_number"     // 电话，17 位以内数字、符号
+	SubscribeFieldCarNumber       SubscribeFieldType = "car_number"       // 车牌，8 位以内
+	SubscribeFieldName            SubscribeFieldType = "name"             // 姓名，10 个以内纯汉字或 20 个以内纯字母或符号
+	SubscribeFieldPhrase          SubscribeFieldType = "phrase"           // 汉字，5 个以内汉字
+	SubscribeFieldShortThing      SubscribeFieldType = "short_thing"      // 短事物，5 个以内字符
+)
+
+var (
+	subscribeFieldKeyRegexp = regexp.MustCompile(`^([a-z_]+?)(\d+)$`)
+	subscribeNumberRegexp   = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+	subscribeLetterRegexp   = regexp.MustCompile(`^[A-Za-z]+$`)
+	subscribeAsciiRegexp    = regexp.MustCompile(`^[\x21-\x7e]+$`)
+	subscribePhoneRegexp    = regexp.MustCompile(`^[\d+\-() ]+$`)
+	subscribeAmountRegexp   = regexp.MustCompile(`^[^\d\s]?\d{1,10}(\.\d+)?元?$`)
+	subscribePhraseRegexp   = regexp.MustCompile(`^\p{Han}+$`)
+)
+
+// 日期和时间格式，支持年月日或 - / . 分隔的日期，可带 24 小时制时间，时间段用 ~ 连接
+const (
+	subscribeDatePattern  = `\d{4}(?:年\d{1,2}月\d{1,2}日|[-/.]\d{1,2}[-/.]\d{1,2})`
+	subscribeClockPattern = `(?:[01]?\d|2[0-3]):[0-5]\d(?::[0-5]\d)?`
+	subscribeDateTime     = subscribeDatePattern + `(?:\s*` + subscribeClockPattern + `)?`
+	subscribeTimePoint    = `(?:` + subscribeDatePattern + `\s*)?` + subscribeClockPattern
+)
+
+var (
+	subscribeDateRegexp      = regexp.MustCompile(`^` + subscribeDateTime + `(?:\s*~\s*` + subscribeDateTime + `)?$`)
+	subscribeTimeRegexp      = regexp.MustCompile(`^` + subscribeTimePoint + `(?:\s*~\s*` + subscribeTimePoint + `)?$`)
+	subscribeSymbolRegexp    = regexp.MustCompile(`^[\p{P}\p{S}]+$`)
+	subscribeCarNumberRegexp = regexp.MustCompile(`^\p{Han}?[A-Za-z0-9]+\p{Han}?$`)
+)
+
+// subscribeFieldMaxLen 各类型参数的最大字符数，0 表示不限制
+var subscribeFieldMaxLen = map[SubscribeFieldType]int{
+	SubscribeFieldThing:           20,
+	SubscribeFieldNumber:          32,
+	SubscribeFieldLetter:          32,
+	SubscribeFieldSymbol:          5,
+	SubscribeFieldCharacterString: 32,
+	SubscribeFieldTime:            0,
+	SubscribeFieldDate:            0,
+	SubscribeFieldAmount:          0,
+	SubscribeFieldPhoneNumber:     17,
+	SubscribeFieldCarNumber:       8,
+	SubscribeFieldName:            20,
+	SubscribeFieldPhrase:          5,
+	SubscribeFieldShortThing:      5,
+}
+
+// ParseSubscribeFieldKey 解析模板参数名，返回参数类型
+func ParseSubscribeFieldKey(key string) (SubscribeFieldType, error) {
+	matches := subscribeFieldKeyRegexp.FindStringSubmatch(key)
+	if matches == nil {
+		return "", fmt.Errorf("模板参数名不合法: %s", key)
+	}
+
+	fieldType := SubscribeFieldType(matches[1])
+	if _, ok := subscribeFieldMaxLen[fieldType]; !ok {
+		return "", fmt.Errorf("模板参数类型不支持: %s", key)
+	}
+
+	return fieldType, nil
+}
+
+// Validate 校验参数值是否符合该类型的格式和长度限制
+func (t SubscribeFieldType) Validate(value string) error {
+	if value == "" {
+		return errors.New("参数值不能为空")
+	}
+
+	length := utf8.RuneCountInString(value)
+	if maxLen := subscribeFieldMaxLen[t]; maxLen > 0 && length > maxLen {
+		return fmt.Errorf("%s 类型参数最多 %d 个字符，实际 %d 个", t, maxLen, length)
+	}
+
+	switch t {
+	case SubscribeFieldNumber:
+		if !subscribeNumberRegexp.MatchString(value) {
+			return fmt.Errorf("%s 类型参数只能是数字", t)
+		}
+	case SubscribeFieldLetter:
+		if !subscribeLetterRegexp.MatchString(value) {
+			return fmt.Errorf("%s 类型参数只能是字母", t)
+		}
+	case SubscribeFieldCharacterString:
+		if !subscribeAsciiRegexp.MatchString(value) {
+			return fmt.Errorf("%s 类型参数只能是数字、字母或符号", t)
+		}
+	case SubscribeFieldSymbol:
+		if !subscribeSymbolRegexp.MatchString(value) {
+			return fmt.Errorf("%s 类型参数只能是符号", t)
+		}
+	case SubscribeFieldTime:
+		if !subscribeTimeRegexp.MatchString(value) {
+			return fmt.Errorf("%s 类型参数需为 24 小时制时间，如 15:01 或 2019年10月1日 15:01", t)
+		}
+	case SubscribeFieldDate:
+		if !subscribeDateRegexp.MatchString(value) {
+			return fmt.Errorf("%s 类型参数需为年月日格式，如 2019年10月1日 或 2019-10-01 15:01", t)
+		}
+	case SubscribeFieldCarNumber:
+		if !subscribeCarNumberRegexp.MatchString(value) {
+			return fmt.Errorf("%s 类型参数首位和末位可为汉字，其余只能是字母或数字", t)
+		}
+	case SubscribeFieldPhoneNumber:
+		if !subscribePhoneRegexp.MatchString(value) {
+			return fmt.Errorf("%s 类型参数只能是数字或符号", t)
+		}
+	case SubscribeFieldAmount:
+		if !subscribeAmountRegexp.MatchString(value) {
+			return fmt.Errorf("%s 类型参数格式不正确", t)
+		}
+	case SubscribeFieldName:
+		if subscribePhraseRegexp.MatchString(value) && length > 10 {
+			return fmt.Errorf("%s 类型参数为汉字时最多 10 个字符", t)
+		}
+	case SubscribeFieldPhrase:
+		if !subscribePhraseRegexp.MatchString(value) {
+			return fmt.Errorf("%s 类型参数只能是汉字", t)
+		}
+	}
+
+	return nil
+}
+
+// SubscribeTemplate 订阅消息模板定义
+type SubscribeTemplate struct {
+	TemplateID string                        // 模板ID
+	Fields     map[string]SubscribeFieldType // 模板参数名及类型，如 thing1、date2
+}
+
+// Validate 校验消息数据，参数名必须与模板完全一致且参数值符合类型限制
+func (t *SubscribeTemplate) Validate(data map[string]string) error {
+	var errs []error
+	for key, fieldType := range t.Fields {
+		value, ok := data[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("缺少模板参数: %s", key))
+			continue
+		}
+		if err := fieldType.Validate(value); err != nil {
+			errs = append(errs, fmt.Errorf("模板参数 %s: %w", key, err))
+		}
+	}
+	for key := range data {
+		if _, ok := t.Fields[key]; !ok {
+			errs = append(errs, fmt.Errorf("模板不存在参数: %s", key))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// SubscribeTemplateRegistry 订阅消息模板注册表，发送已注册模板的消息前会校验参数
+type SubscribeTemplateRegistry struct {
+	mu        sync.RWMutex
+	templates map[string]*SubscribeTemplate
+}
+
+// NewSubscribeTemplateRegistry 创建订阅消息模板注册表
+func NewSubscribeTemplateRegistry() *SubscribeTemplateRegistry {
+	return &SubscribeTemplateRegistry{
+		templates: make(map[string]*SubscribeTemplate),
+	}
+}
+
+// Register 注册模板
+// keys: 模板参数名，如 thing1、time2，参数类型由参数名推断
+func (r *SubscribeTemplateRegistry) Register(templateID string, keys ...string) error {
+	if templateID == "" {
+		return errors.New("模板ID不能为空")
+	}
+	if len(keys) == 0 {
+		return errors.New("模板参数不能为空")
+	}
+
+	fields := make(map[string]SubscribeFieldType, len(keys))
+	for _, key := range keys {
+		fieldType, err := ParseSubscribeFieldKey(key)
+		if err != nil {
+			return err
+		}
+		fields[key] = fieldType
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.templates[templateID] = &SubscribeTemplate{TemplateID: templateID, Fields: fields}
+	return nil
+}
+
+// Unregister 移除模板
+func (r *SubscribeTemplateRegistry) Unregister(templateID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.templates, templateID)
+}
+
+// Get 获取已注册的模板
+func (r *SubscribeTemplateRegistry) Get(templateID string) (*SubscribeTemplate, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.templates[templateID]
+	return t, ok
+}
+
+// SubscribeMessage 订阅消息
+type SubscribeMessage struct {
+	ToUser     string            // 接收者（用户）的 openid
+	TemplateID string            // 订阅模板ID
+	Page       string            // 点击模板卡片后的跳转页面，可选
+	Data       map[string]string // 模板内容，如 {"thing1": "订单已发货"}
+	Lang       string            // 进入小程序查看的语言类型，可选，默认 zh_CN
+}
+
+// SetSubscribeTemplateRegistry 设置订阅消息模板注册表
+// registry 为空时替换为空的注册表，发送时不再校验模板参数
+func (c *MiniProgramClient) SetSubscribeTemplateRegistry(registry *SubscribeTemplateRegistry) {
+	if registry == nil {
+		registry = NewSubscribeTemplateRegistry()
+	}
+	c.subscribeTemplates = registry
+}
+
+// GetSubscribeTemplateRegistry 获取订阅消息模板注册表
+func (c *MiniProgramClient) GetSubscribeTemplateRegistry() *SubscribeTemplateRegistry {
+	return c.subscribeTemplates
+}
+
+// SendSubscribeMessage 发送订阅消息，返回消息ID
+// 模板已注册时先校验参数；跳转的小程序版本由 EnvVersion 决定
+func (c *MiniProgramClient) SendSubscribeMessage(ctx context.Context, msg *SubscribeMessage) (int64, error) {
+	if msg.ToUser == "" || msg.TemplateID == "" {
+		return 0, errors.New("touser 和 template_id 不能为空")
+	}
+	if t, ok := c.subscribeTemplates.Get(msg.TemplateID); ok {
+		if err := t.Validate(msg.Data); err != nil {
+			return 0, err
+		}
+	}
+
+	data := make(map[string]*subscribe.DataItem, len(msg.Data))
+	for k, v := range msg.Data {
+		data[k] = &subscribe.DataItem{Value: v}
+	}
+	req := &subscribe.Message{
+		ToUser:           msg.ToUser,
+		TemplateID:       msg.TemplateID,
+		Page:             msg.Page,
+		Data:             data,
+		MiniprogramState: miniProgramState(c.config.EnvVersion),
+		Lang:             msg.Lang,
+	}
+
+	var resp struct {
+		util.CommonError
+		MsgID int64 `json:"msgid"`
+	}
+	if err := c.postJSON(ctx, subscribeSendURL, req, &resp, "SendSubscribeMessage"); err != nil {
+		return 0, err
+	}
+
+	return resp.MsgID, nil
+}
+
+// ListSubscribeTemplates 获取当前账号下的个人模板列表
+func (c *MiniProgramClient) ListSubscribeTemplates(ctx context.Context) ([]subscribe.TemplateItem, error) {
+	accessToken, err := c.miniProgramIns.GetContext().GetAccessTokenContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := util.HTTPGetContext(ctx, fmt.Sprintf(subscribeGetTemplateURL, accessToken))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp subscribe.TemplateList
+	if err := util.DecodeWithError(response, &resp, "ListSubscribeTemplates"); err != nil {
+		return nil, err
+	}
+
+	return resp.Data, nil
+}
+
+// AddSubscribeTemplate 从公共模板库选用模板到个人模板库，返回模板ID
+// tid: 公共模板标题ID
+// kidList: 选用的关键词 kid 列表，按顺序排列
+// sceneDesc: 服务场景描述，15 个字以内
+func (c *MiniProgramClient) AddSubscribeTemplate(ctx context.Context, tid string, kidList []int, sceneDesc string) (string, error) {
+	req := map[string]any{
+		"tid":       tid,
+		"kidList":   kidList,
+		"sceneDesc": sceneDesc,
+	}
+
+	var resp struct {
+		util.CommonError
+		PriTmplID string `json:"priTmplId"`
+	}
+	if err := c.postJSON(ctx, subscribeAddTemplateURL, req, &resp, "AddSubscribeTemplate"); err != nil {
+		return "", err
+	}
+
+	return resp.PriTmplID, nil
+}
+
+// DeleteSubscribeTemplate 删除个人模板，同时从注册表中移除
+func (c *MiniProgramClient) DeleteSubscribeTemplate(ctx context.Context, templateID string) error {
+	req := map[string]any{
+		"priTmplId": templateID,
+	}
+
+	var resp commonResponse
+	if err := c.postJSON(ctx, subscribeDelTemplateURL, req, &resp, "DeleteSubscribeTemplate"); err != nil {
+		return err
+	}
+
+	c.subscribeTemplates.Unregister(templateID)
+	return nil
+}
+
+// miniProgramState 将 EnvVersion 转换为订阅消息的 miniprogram_state
+func miniProgramState(envVersion string) string {
+	switch strings.ToLower(envVersion) {
+	case "develop":
+		return "developer"
+	case "trial":
+		return "trial"
+	default:
+		return "formal"
+	}
+}
@@ -0,0 +1,108 @@
+package wechat_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/darwinOrg/go-wechat"
+)
+
+// TestSubscribeTemplate_Validate 测试订阅消息模板参数校验
+func TestSubscribeTemplate_Validate(t *testing.T) {
+	registry := wechat.NewSubscribeTemplateRegistry()
+	if err := registry.Register("tpl_order", "thing1", "amount2", "date3", "phrase4"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := registry.Register("tpl_bad", "unknown1"); err == nil {
+		t.Fatal("Register with unknown field type should fail")
+	}
+
+	tpl, ok := registry.Get("tpl_order")
+	if !ok {
+		t.Fatal("template not registered")
+	}
+
+	valid := map[string]string{"thing1": "订单已发货", "amount2": "¥100.00", "date3": "2024年1月1日", "phrase4": "已完成"}
+	if err := tpl.Validate(valid); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	invalid := map[string]string{"thing1": "这是一段超过二十个字符长度限制的订阅消息内容描述", "amount2": "abc", "phrase4": "done", "thing5": "多余"}
+	if err := tpl.Validate(invalid); err == nil {
+		t.Fatal("Validate should fail")
+	}
+
+	formats := []struct {
+		fieldType wechat.SubscribeFieldType
+		value     string
+		valid     bool
+	}{
+		{wechat.SubscribeFieldDate, "2019-10-01 15:01", true},
+		{wechat.SubscribeFieldDate, "2019年10月1日~2019年10月7日", true},
+		{wechat.SubscribeFieldDate, "明天", false},
+		{wechat.SubscribeFieldTime, "15:01", true},
+		{wechat.SubscribeFieldTime, "2019年10月1日 15:01 ~ 17:30", true},
+		{wechat.SubscribeFieldTime, "25:00", false},
+		{wechat.SubscribeFieldSymbol, "+-", true},
+		{wechat.SubscribeFieldSymbol, "a+", false},
+		{wechat.SubscribeFieldCarNumber, "粤A12345", true},
+		{wechat.SubscribeFieldCarNumber, "A-1234", false},
+	}
+	for _, f := range formats {
+		if err := f.fieldType.Validate(f.value); (err == nil) != f.valid {
+			t.Errorf("%s %q: valid=%v, err=%v", f.fieldType, f.value, f.valid, err)
+		}
+	}
+}
+
+// TestMiniProgramClient_SendSubscribeMessage 测试发送订阅消息
+func TestMiniProgramClient_SendSubscribeMessage(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgi-bin/message/subscribe/send", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["miniprogram_state"] != "formal" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 47003, "errmsg": "argument invalid"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "errmsg": "ok", "msgid": 123})
+	})
+	client := newMockMiniProgramClient(t, mux)
+	if err := client.GetSubscribeTemplateRegistry().Register("tpl_order", "thing1"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	msgID, err := client.SendSubscribeMessage(ctx, &wechat.SubscribeMessage{
+		ToUser:     "test_openid",
+		TemplateID: "tpl_order",
+		Data:       map[string]string{"thing1": "订单已发货"},
+	})
+	if err != nil {
+		t.Fatalf("SendSubscribeMessage failed: %v", err)
+	}
+	if msgID != 123 {
+		t.Fatalf("unexpected msgid: %d", msgID)
+	}
+
+	_, err = client.SendSubscribeMessage(ctx, &wechat.SubscribeMessage{
+		ToUser:     "test_openid",
+		TemplateID: "tpl_order",
+		Data:       map[string]string{"thing2": "订单已发货"},
+	})
+	if err == nil {
+		t.Fatal("SendSubscribeMessage with invalid data should fail")
+	}
+
+	// 清空注册表后不再校验模板参数
+	client.SetSubscribeTemplateRegistry(nil)
+	if _, err := client.SendSubscribeMessage(ctx, &wechat.SubscribeMessage{
+		ToUser:     "test_openid",
+		TemplateID: "tpl_order",
+		Data:       map[string]string{"thing2": "订单已发货"},
+	}); err != nil {
+		t.Fatalf("SendSubscribeMessage with nil registry failed: %v", err)
+	}
+}
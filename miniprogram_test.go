@@ -1,6 +1,9 @@
 package wechat_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
 	"os"
 	"testing"
 
@@ -23,3 +26,38 @@ func TestGenerateUrlLink(t *testing.T) {
 	}
 	t.Logf("link: %s", link)
 }
+
+// TestGenerateScheme 测试生成 URL Scheme 的过期时间默认规则
+func TestGenerateScheme(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wxa/generatescheme", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			JumpWxa struct {
+				EnvVersion string `json:"env_version"`
+			} `json:"jump_wxa"`
+			ExpireType     int   `json:"expire_type"`
+			ExpireTime     int64 `json:"expire_time"`
+			ExpireInterval int   `json:"expire_interval"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		openLink := fmt.Sprintf("weixin://dl/business/?t=%s_%d_%d_%d", req.JumpWxa.EnvVersion, req.ExpireType, req.ExpireTime, req.ExpireInterval)
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "openlink": openLink})
+	})
+	miniClient := newMockMiniProgramClient(t, mux)
+
+	scheme, err := miniClient.GenerateScheme("pages/index", "a=1", 0)
+	if err != nil {
+		t.Fatalf("GenerateScheme failed: %v", err)
+	}
+	if scheme != "weixin://dl/business/?t=release_1_0_30" {
+		t.Fatalf("unexpected scheme: %s", scheme)
+	}
+
+	scheme, err = miniClient.GenerateScheme("pages/index", "a=1", 1700000000)
+	if err != nil {
+		t.Fatalf("GenerateScheme failed: %v", err)
+	}
+	if scheme != "weixin://dl/business/?t=release_0_1700000000_0" {
+		t.Fatalf("unexpected scheme: %s", scheme)
+	}
+}
@@ -0,0 +1,547 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/silenceper/wechat/v2"
+	"github.com/silenceper/wechat/v2/cache"
+	"github.com/silenceper/wechat/v2/credential"
+	"github.com/silenceper/wechat/v2/officialaccount"
+	"github.com/silenceper/wechat/v2/officialaccount/basic"
+	"github.com/silenceper/wechat/v2/officialaccount/config"
+	"github.com/silenceper/wechat/v2/officialaccount/menu"
+	"github.com/silenceper/wechat/v2/officialaccount/message"
+	"github.com/silenceper/wechat/v2/officialaccount/oauth"
+	"github.com/silenceper/wechat/v2/officialaccount/user"
+	"github.com/silenceper/wechat/v2/util"
+)
+
+// qrCodeTempMaxExpire 临时二维码最长有效期
+const qrCodeTempMaxExpire = 30 * 24 * time.Hour
+
+const (
+	templateSendURL          = "https://api.weixin.qq.com/cgi-bin/message/template/send?access_token=%s"
+	templateListURL          = "https://api.weixin.qq.com/cgi-bin/template/get_all_private_template?access_token=%s"
+	templateAddURL           = "https://api.weixin.qq.com/cgi-bin/template/api_add_template?access_token=%s"
+	templateDeleteURL        = "https://api.weixin.qq.com/cgi-bin/template/del_private_template?access_token=%s"
+	qrCodeCreateURL          = "https://api.weixin.qq.com/cgi-bin/qrcode/create?access_token=%s"
+	userInfoURL              = "https://api.weixin.qq.com/cgi-bin/user/info?access_token=%s"
+	userInfoBatchURL         = "https://api.weixin.qq.com/cgi-bin/user/info/batchget?access_token=%s"
+	userUpdateRemarkURL      = "https://api.weixin.qq.com/cgi-bin/user/info/updateremark?access_token=%s"
+	userListURL              = "https://api.weixin.qq.com/cgi-bin/user/get?access_token=%s"
+	tagCreateURL             = "https://api.weixin.qq.com/cgi-bin/tags/create?access_token=%s"
+	tagListURL               = "https://api.weixin.qq.com/cgi-bin/tags/get?access_token=%s"
+	tagUpdateURL             = "https://api.weixin.qq.com/cgi-bin/tags/update?access_token=%s"
+	tagDeleteURL             = "https://api.weixin.qq.com/cgi-bin/tags/delete?access_token=%s"
+	tagUserListURL           = "https://api.weixin.qq.com/cgi-bin/user/tag/get?access_token=%s"
+	tagBatchTaggingURL       = "https://api.weixin.qq.com/cgi-bin/tags/members/batchtagging?access_token=%s"
+	tagBatchUntaggingURL     = "https://api.weixin.qq.com/cgi-bin/tags/members/batchuntagging?access_token=%s"
+	tagUserTagsURL           = "https://api.weixin.qq.com/cgi-bin/tags/getidlist?access_token=%s"
+	menuCreateURL            = "https://api.weixin.qq.com/cgi-bin/menu/create?access_token=%s"
+	menuGetURL               = "https://api.weixin.qq.com/cgi-bin/menu/get?access_token=%s"
+	menuDeleteURL            = "https://api.weixin.qq.com/cgi-bin/menu/delete?access_token=%s"
+	menuAddConditionalURL    = "https://api.weixin.qq.com/cgi-bin/menu/addconditional?access_token=%s"
+	menuDeleteConditionalURL = "https://api.weixin.qq.com/cgi-bin/menu/delconditional?access_token=%s"
+)
+
+// 网页授权作用域
+const (
+	OAuthScopeBase     = "snsapi_base"     // 静默授权，只能获取 openid
+	OAuthScopeUserInfo = "snsapi_userinfo" // 需用户确认，可获取昵称、头像等信息
+)
+
+type OfficialAccountConfig struct {
+	AppId          string       `json:"appId" yaml:"appId" mapstructure:"appId"`
+	AppSecret      string       `json:"appSecret" yaml:"appSecret" mapstructure:"appSecret"`
+	RedisAddr      string       `json:"redisAddr" yaml:"redisAddr" mapstructure:"redisAddr"`
+	Redis          *RedisConfig `json:"redis" yaml:"redis" mapstructure:"redis"`                            // Redis 连接配置，可选，相同配置的客户端共用连接池
+	Token          string       `json:"token" yaml:"token" mapstructure:"token"`                            // 服务器配置 Token
+	EncodingAESKey string       `json:"encodingAESKey" yaml:"encodingAESKey" mapstructure:"encodingAESKey"` // 服务器配置消息加解密密钥，明文模式可不填
+}
+
+type OfficialAccountClient struct {
+	officialAccountIns *officialaccount.OfficialAccount
+	config             *OfficialAccountConfig
+	cache              cache.Cache
+}
+
+func NewOfficialAccountClient(cfg *OfficialAccountConfig) *OfficialAccountClient {
+	return NewOfficialAccountClientWithCache(cfg, newCache(cfg.Redis, cfg.RedisAddr))
+}
+
+// NewOfficialAccountClientWithCache 使用指定缓存创建公众号客户端，忽略 RedisAddr 配置
+func NewOfficialAccountClientWithCache(cfg *OfficialAccountConfig, c cache.Cache) *OfficialAccountClient {
+	oaCfg := &config.Config{
+		AppID:          cfg.AppId,
+		AppSecret:      cfg.AppSecret,
+		Token:          cfg.Token,
+		EncodingAESKey: cfg.EncodingAESKey,
+	}
+	oaCfg.Cache = c
+
+	wx := wechat.NewWechat()
+	officialAccountIns := wx.GetOfficialAccount(oaCfg)
+
+	stableAccessTokenHandle := credential.NewStableAccessToken(cfg.AppId, cfg.AppSecret, credential.CacheKeyOfficialAccountPrefix, oaCfg.Cache)
+	officialAccountIns.SetAccessTokenHandle(stableAccessTokenHandle)
+
+	return &OfficialAccountClient{
+		officialAccountIns: officialAccountIns,
+		config:             cfg,
+		cache:              oaCfg.Cache,
+	}
+}
+
+// GetOfficialAccount 获取公众号实例，用于直接调用SDK方法
+func (c *OfficialAccountClient) GetOfficialAccount() *officialaccount.OfficialAccount {
+	return c.officialAccountIns
+}
+
+// GetConfig 获取配置
+func (c *OfficialAccountClient) GetConfig() *OfficialAccountConfig {
+	return c.config
+}
+
+// ==================== 模板消息 ====================
+
+// TemplateMessage 公众号模板消息
+type TemplateMessage = message.TemplateMessage
+
+// TemplateDataItem 模板消息参数
+type TemplateDataItem = message.TemplateDataItem
+
+// SendTemplateMessage 发送模板消息，返回消息ID
+func (c *OfficialAccountClient) SendTemplateMessage(ctx context.Context, msg *TemplateMessage) (int64, error) {
+	var resp struct {
+		util.CommonError
+		MsgID int64 `json:"msgid"`
+	}
+	if err := c.postJSON(ctx, templateSendURL, msg, &resp, "SendTemplateMessage"); err != nil {
+		return 0, err
+	}
+
+	return resp.MsgID, nil
+}
+
+// ListTemplates 获取已添加的模板列表
+func (c *OfficialAccountClient) ListTemplates(ctx context.Context) ([]*message.TemplateItem, error) {
+	var resp struct {
+		util.CommonError
+		TemplateList []*message.TemplateItem `json:"template_list"`
+	}
+	if err := c.getJSON(ctx, templateListURL, nil, &resp, "ListTemplates"); err != nil {
+		return nil, err
+	}
+
+	return resp.TemplateList, nil
+}
+
+// AddTemplate 从模板库添加模板，返回模板ID
+// shortID: 模板库中模板的编号
+// keyNameList: 选用的类目模板的关键词
+func (c *OfficialAccountClient) AddTemplate(ctx context.Context, shortID string, keyNameList []string) (string, error) {
+	req := map[string]any{
+		"template_id_short": shortID,
+		"keyword_name_list": keyNameList,
+	}
+
+	var resp struct {
+		util.CommonError
+		TemplateID string `json:"template_id"`
+	}
+	if err := c.postJSON(ctx, templateAddURL, req, &resp, "AddTemplate"); err != nil {
+		return "", err
+	}
+
+	return resp.TemplateID, nil
+}
+
+// DeleteTemplate 删除模板
+func (c *OfficialAccountClient) DeleteTemplate(ctx context.Context, templateID string) error {
+	req := map[string]any{"template_id": templateID}
+	return c.postJSON(ctx, templateDeleteURL, req, &commonResponse{}, "DeleteTemplate")
+}
+
+// ==================== 带参数二维码 ====================
+
+// QRCodeTicket 带参数二维码
+type QRCodeTicket = basic.Ticket
+
+// CreateTempQRCode 生成字符串场景值的临时二维码
+// expire: 有效期，最长 30 天
+func (c *OfficialAccountClient) CreateTempQRCode(ctx context.Context, sceneStr string, expire time.Duration) (*QRCodeTicket, error) {
+	if sceneStr == "" || len(sceneStr) > 64 {
+		return nil, errors.New("场景值长度必须为 1 到 64")
+	}
+
+	req, err := newTempQRRequest(expire)
+	if err != nil {
+		return nil, err
+	}
+	req.ActionName = "QR_STR_SCENE"
+	req.ActionInfo.Scene.SceneStr = sceneStr
+
+	return c.createQRCode(ctx, req)
+}
+
+// CreateTempQRCodeWithID 生成整型场景值的临时二维码
+// expire: 有效期，最长 30 天
+func (c *OfficialAccountClient) CreateTempQRCodeWithID(ctx context.Context, sceneID int, expire time.Duration) (*QRCodeTicket, error) {
+	if sceneID <= 0 {
+		return nil, errors.New("场景值必须大于 0")
+	}
+
+	req, err := newTempQRRequest(expire)
+	if err != nil {
+		return nil, err
+	}
+	req.ActionName = "QR_SCENE"
+	req.ActionInfo.Scene.SceneID = sceneID
+
+	return c.createQRCode(ctx, req)
+}
+
+// CreatePermanentQRCode 生成字符串场景值的永久二维码，总数限制 10 万个
+func (c *OfficialAccountClient) CreatePermanentQRCode(ctx context.Context, sceneStr string) (*QRCodeTicket, error) {
+	if sceneStr == "" || len(sceneStr) > 64 {
+		return nil, errors.New("场景值长度必须为 1 到 64")
+	}
+
+	req := &basic.Request{ActionName: "QR_LIMIT_STR_SCENE"}
+	req.ActionInfo.Scene.SceneStr = sceneStr
+
+	return c.createQRCode(ctx, req)
+}
+
+// CreatePermanentQRCodeWithID 生成整型场景值的永久二维码，场景值范围 1 到 100000
+func (c *OfficialAccountClient) CreatePermanentQRCodeWithID(ctx context.Context, sceneID int) (*QRCodeTicket, error) {
+	if sceneID <= 0 || sceneID > 100000 {
+		return nil, errors.New("永久二维码场景值范围为 1 到 100000")
+	}
+
+	req := &basic.Request{ActionName: "QR_LIMIT_SCENE"}
+	req.ActionInfo.Scene.SceneID = sceneID
+
+	return c.createQRCode(ctx, req)
+}
+
+// GetQRCodeURL 获取二维码图片地址
+func (c *OfficialAccountClient) GetQRCodeURL(ticket *QRCodeTicket) string {
+	return basic.ShowQRCode(ticket)
+}
+
+// createQRCode 创建二维码 ticket
+func (c *OfficialAccountClient) createQRCode(ctx context.Context, req *basic.Request) (*QRCodeTicket, error) {
+	var ticket QRCodeTicket
+	if err := c.postJSON(ctx, qrCodeCreateURL, req, &ticket, "CreateQRCode"); err != nil {
+		return nil, err
+	}
+
+	return &ticket, nil
+}
+
+// newTempQRRequest 创建临时二维码请求
+func newTempQRRequest(expire time.Duration) (*basic.Request, error) {
+	if expire <= 0 || expire > qrCodeTempMaxExpire {
+		return nil, errors.New("临时二维码有效期必须大于 0 且不超过 30 天")
+	}
+
+	return &basic.Request{ExpireSeconds: int64(expire.Seconds())}, nil
+}
+
+// ==================== 用户管理 ====================
+
+// OfficialAccountUserInfo 公众号用户基本信息
+type OfficialAccountUserInfo = user.Info
+
+// GetUserInfo 获取用户基本信息，包括关注状态、UnionID 和标签
+func (c *OfficialAccountClient) GetUserInfo(ctx context.Context, openID string) (*OfficialAccountUserInfo, error) {
+	var info OfficialAccountUserInfo
+	if err := c.getJSON(ctx, userInfoURL, url.Values{"openid": {openID}, "lang": {"zh_CN"}}, &info, "GetUserInfo"); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// BatchGetUserInfo 批量获取用户基本信息，最多 100 个
+func (c *OfficialAccountClient) BatchGetUserInfo(ctx context.Context, openIDs []string, lang string) (*user.InfoList, error) {
+	if len(openIDs) > 100 {
+		return nil, errors.New("每次最多获取 100 个用户的信息")
+	}
+
+	params := user.BatchGetUserInfoParams{}
+	for _, openID := range openIDs {
+		params.UserList = append(params.UserList, user.BatchGetUserListItem{OpenID: openID, Lang: lang})
+	}
+
+	var list user.InfoList
+	if err := c.postJSON(ctx, userInfoBatchURL, params, &list, "BatchGetUserInfo"); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// UpdateUserRemark 设置用户备注名
+func (c *OfficialAccountClient) UpdateUserRemark(ctx context.Context, openID, remark string) error {
+	req := map[string]any{"openid": openID, "remark": remark}
+	return c.postJSON(ctx, userUpdateRemarkURL, req, &commonResponse{}, "UpdateUserRemark")
+}
+
+// ListUserOpenIDs 获取关注者列表，每次最多 10000 个
+// nextOpenID: 上一次拉取的最后一个 openid，第一次拉取不填
+func (c *OfficialAccountClient) ListUserOpenIDs(ctx context.Context, nextOpenID string) (*user.OpenidList, error) {
+	query := url.Values{}
+	if nextOpenID != "" {
+		query.Set("next_openid", nextOpenID)
+	}
+
+	var list user.OpenidList
+	if err := c.getJSON(ctx, userListURL, query, &list, "ListUserOpenIDs"); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// ==================== 用户标签 ====================
+
+// UserTag 用户标签
+type UserTag = user.TagInfo
+
+// CreateTag 创建标签
+func (c *OfficialAccountClient) CreateTag(ctx context.Context, name string) (*UserTag, error) {
+	req := map[string]any{"tag": map[string]any{"name": name}}
+
+	var resp struct {
+		util.CommonError
+		Tag *UserTag `json:"tag"`
+	}
+	if err := c.postJSON(ctx, tagCreateURL, req, &resp, "CreateTag"); err != nil {
+		return nil, err
+	}
+
+	return resp.Tag, nil
+}
+
+// UpdateTag 编辑标签
+func (c *OfficialAccountClient) UpdateTag(ctx context.Context, tagID int32, name string) error {
+	req := map[string]any{"tag": map[string]any{"id": tagID, "name": name}}
+	return c.postJSON(ctx, tagUpdateURL, req, &commonResponse{}, "UpdateTag")
+}
+
+// DeleteTag 删除标签
+func (c *OfficialAccountClient) DeleteTag(ctx context.Context, tagID int32) error {
+	req := map[string]any{"tag": map[string]any{"id": tagID}}
+	return c.postJSON(ctx, tagDeleteURL, req, &commonResponse{}, "DeleteTag")
+}
+
+// ListTags 获取已创建的标签
+func (c *OfficialAccountClient) ListTags(ctx context.Context) ([]*UserTag, error) {
+	var resp struct {
+		util.CommonError
+		Tags []*UserTag `json:"tags"`
+	}
+	if err := c.getJSON(ctx, tagListURL, nil, &resp, "ListTags"); err != nil {
+		return nil, err
+	}
+
+	return resp.Tags, nil
+}
+
+// ListTagUsers 获取标签下的用户，nextOpenID 为空时从头开始
+func (c *OfficialAccountClient) ListTagUsers(ctx context.Context, tagID int32, nextOpenID string) (*user.TagOpenIDList, error) {
+	req := map[string]any{"tagid": tagID, "next_openid": nextOpenID}
+
+	var resp struct {
+		util.CommonError
+		user.TagOpenIDList
+	}
+	if err := c.postJSON(ctx, tagUserListURL, req, &resp, "ListTagUsers"); err != nil {
+		return nil, err
+	}
+
+	return &resp.TagOpenIDList, nil
+}
+
+// TagUsers 批量为用户打标签，每次最多 50 个
+func (c *OfficialAccountClient) TagUsers(ctx context.Context, openIDs []string, tagID int32) error {
+	if len(openIDs) == 0 {
+		return nil
+	}
+
+	req := map[string]any{"openid_list": openIDs, "tagid": tagID}
+	return c.postJSON(ctx, tagBatchTaggingURL, req, &commonResponse{}, "TagUsers")
+}
+
+// UntagUsers 批量为用户取消标签，每次最多 50 个
+func (c *OfficialAccountClient) UntagUsers(ctx context.Context, openIDs []string, tagID int32) error {
+	if len(openIDs) == 0 {
+		return nil
+	}
+
+	req := map[string]any{"openid_list": openIDs, "tagid": tagID}
+	return c.postJSON(ctx, tagBatchUntaggingURL, req, &commonResponse{}, "UntagUsers")
+}
+
+// GetUserTags 获取用户身上的标签ID
+func (c *OfficialAccountClient) GetUserTags(ctx context.Context, openID string) ([]int32, error) {
+	req := map[string]any{"openid": openID}
+
+	var resp struct {
+		util.CommonError
+		TagIDList []int32 `json:"tagid_list"`
+	}
+	if err := c.postJSON(ctx, tagUserTagsURL, req, &resp, "GetUserTags"); err != nil {
+		return nil, err
+	}
+
+	return resp.TagIDList, nil
+}
+
+// ==================== 自定义菜单 ====================
+
+// MenuButton 菜单按钮
+type MenuButton = menu.Button
+
+// MenuMatchRule 个性化菜单匹配规则
+type MenuMatchRule = menu.MatchRule
+
+// CreateMenu 创建自定义菜单，会覆盖已有菜单
+func (c *OfficialAccountClient) CreateMenu(ctx context.Context, buttons []*MenuButton) error {
+	if len(buttons) == 0 || len(buttons) > 3 {
+		return errors.New("一级菜单数量必须为 1 到 3 个")
+	}
+	for _, btn := range buttons {
+		if len(btn.SubButtons) > 5 {
+			return fmt.Errorf("菜单 %s 的二级菜单最多 5 个", btn.Name)
+		}
+	}
+
+	req := map[string]any{"button": buttons}
+	return c.postJSON(ctx, menuCreateURL, req, &commonResponse{}, "CreateMenu")
+}
+
+// GetMenu 查询自定义菜单，包括个性化菜单
+func (c *OfficialAccountClient) GetMenu(ctx context.Context) (*menu.ResMenu, error) {
+	var resMenu menu.ResMenu
+	if err := c.getJSON(ctx, menuGetURL, nil, &resMenu, "GetMenu"); err != nil {
+		return nil, err
+	}
+
+	return &resMenu, nil
+}
+
+// DeleteMenu 删除自定义菜单，同时删除全部个性化菜单
+func (c *OfficialAccountClient) DeleteMenu(ctx context.Context) error {
+	return c.getJSON(ctx, menuDeleteURL, nil, &commonResponse{}, "DeleteMenu")
+}
+
+// AddConditionalMenu 创建个性化菜单
+func (c *OfficialAccountClient) AddConditionalMenu(ctx context.Context, buttons []*MenuButton, matchRule *MenuMatchRule) error {
+	req := map[string]any{"button": buttons, "matchrule": matchRule}
+	return c.postJSON(ctx, menuAddConditionalURL, req, &commonResponse{}, "AddConditionalMenu")
+}
+
+// DeleteConditionalMenu 删除个性化菜单
+func (c *OfficialAccountClient) DeleteConditionalMenu(ctx context.Context, menuID int64) error {
+	req := map[string]any{"menuid": menuID}
+	return c.postJSON(ctx, menuDeleteConditionalURL, req, &commonResponse{}, "DeleteConditionalMenu")
+}
+
+// ==================== 网页授权 ====================
+
+// OAuthAccessToken 网页授权 access_token，与基础 access_token 不同
+type OAuthAccessToken = oauth.ResAccessToken
+
+// OAuthUserInfo 网页授权获取的用户信息
+type OAuthUserInfo = oauth.UserInfo
+
+// GetOAuthRedirectURL 获取网页授权跳转地址
+// scope: OAuthScopeBase 或 OAuthScopeUserInfo
+// state: 重定向后原样带回，建议用于防止 CSRF
+func (c *OfficialAccountClient) GetOAuthRedirectURL(redirectURI, scope, state string) (string, error) {
+	if scope != OAuthScopeBase && scope != OAuthScopeUserInfo {
+		return "", fmt.Errorf("不支持的授权作用域: %s", scope)
+	}
+
+	return c.officialAccountIns.GetOauth().GetRedirectURL(redirectURI, scope, state)
+}
+
+// ExchangeOAuthCode 通过授权回调的 code 换取网页授权 access_token 和 openid
+func (c *OfficialAccountClient) ExchangeOAuthCode(ctx context.Context, code string) (*OAuthAccessToken, error) {
+	if code == "" {
+		return nil, errors.New("code 不能为空")
+	}
+
+	token, err := c.officialAccountIns.GetOauth().GetUserAccessTokenContext(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// RefreshOAuthToken 刷新网页授权 access_token
+func (c *OfficialAccountClient) RefreshOAuthToken(ctx context.Context, refreshToken string) (*OAuthAccessToken, error) {
+	token, err := c.officialAccountIns.GetOauth().RefreshAccessTokenContext(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// GetOAuthUserInfo 使用网页授权 access_token 获取用户信息，仅 snsapi_userinfo 作用域可用
+func (c *OfficialAccountClient) GetOAuthUserInfo(ctx context.Context, token *OAuthAccessToken, lang string) (*OAuthUserInfo, error) {
+	if token.Scope != "" && token.Scope != OAuthScopeUserInfo {
+		return nil, fmt.Errorf("作用域 %s 无法获取用户信息", token.Scope)
+	}
+
+	info, err := c.officialAccountIns.GetOauth().GetUserInfoContext(ctx, token.AccessToken, token.OpenID, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// postJSON 携带 access_token 调用公众号 JSON 接口并解析响应
+// urlFormat 中的 %s 会被替换为 access_token，resp 需内嵌 util.CommonError
+func (c *OfficialAccountClient) postJSON(ctx context.Context, urlFormat string, req, resp any, apiName string) error {
+	accessToken, err := c.officialAccountIns.GetAccessTokenContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	response, err := util.PostJSONContext(ctx, fmt.Sprintf(urlFormat, accessToken), req)
+	if err != nil {
+		return err
+	}
+
+	return util.DecodeWithError(response, resp, apiName)
+}
+
+// getJSON 携带 access_token 调用公众号 GET 接口并解析响应，query 为额外的查询参数
+func (c *OfficialAccountClient) getJSON(ctx context.Context, urlFormat string, query url.Values, resp any, apiName string) error {
+	accessToken, err := c.officialAccountIns.GetAccessTokenContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	uri := fmt.Sprintf(urlFormat, accessToken)
+	if len(query) > 0 {
+		uri += "&" + query.Encode()
+	}
+	response, err := util.HTTPGetContext(ctx, uri)
+	if err != nil {
+		return err
+	}
+
+	return util.DecodeWithError(response, resp, apiName)
+}
@@ -0,0 +1,409 @@
+package wechat
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 公众号消息类型和事件类型
+const (
+	OfficialAccountMsgTypeText  = "text"
+	OfficialAccountMsgTypeImage = "image"
+	OfficialAccountMsgTypeEvent = "event"
+
+	OfficialAccountEventSubscribe             = "subscribe"             // 关注，扫描带参数二维码关注时 EventKey 以 qrscene_ 开头
+	OfficialAccountEventUnsubscribe           = "unsubscribe"           // 取消关注
+	OfficialAccountEventScan                  = "SCAN"                  // 已关注用户扫描带参数二维码
+	OfficialAccountEventClick                 = "CLICK"                 // 点击菜单拉取消息
+	OfficialAccountEventView                  = "VIEW"                  // 点击菜单跳转链接
+	OfficialAccountEventLocation              = "LOCATION"              // 上报地理位置
+	OfficialAccountEventTemplateSendJobFinish = "TEMPLATESENDJOBFINISH" // 模板消息发送结果
+)
+
+// qrScenePrefix 扫码关注事件 EventKey 的前缀
+const qrScenePrefix = "qrscene_"
+
+// OfficialAccountMessage 公众号推送的消息或事件
+// 通过 Text、SubscribeEvent 等方法获取对应类型的字段
+type OfficialAccountMessage struct {
+	ToUserName   string // 公众号原始ID
+	FromUserName string // 发送者的 openid
+	CreateTime   int64  // 消息创建时间
+	MsgType      string // 消息类型
+	Event        string // 事件类型，MsgType 为 event 时有效
+	MsgID        int64  // 消息ID，普通消息有效
+	Raw          []byte // 解密后的原始 XML
+
+	fields officialAccountMessageFields
+}
+
+// officialAccountMessageFields 公众号推送的全部字段
+type officialAccountMessageFields struct {
+	ToUserName   string  `xml:"ToUserName"`
+	FromUserName string  `xml:"FromUserName"`
+	CreateTime   int64   `xml:"CreateTime"`
+	MsgType      string  `xml:"MsgType"`
+	Event        string  `xml:"Event"`
+	MsgId        int64   `xml:"MsgId"`
+	MsgID        int64   `xml:"MsgID"`
+	Content      string  `xml:"Content"`
+	PicURL       string  `xml:"PicUrl"`
+	MediaID      string  `xml:"MediaId"`
+	EventKey     string  `xml:"EventKey"`
+	Ticket       string  `xml:"Ticket"`
+	MenuID       string  `xml:"MenuId"`
+	Latitude     float64 `xml:"Latitude"`
+	Longitude    float64 `xml:"Longitude"`
+	Precision    float64 `xml:"Precision"`
+	Status       string  `xml:"Status"`
+}
+
+// OfficialAccountTextMessage 文本消息
+type OfficialAccountTextMessage struct {
+	Content string // 文本内容
+}
+
+// OfficialAccountImageMessage 图片消息
+type OfficialAccountImageMessage struct {
+	PicURL  string // 图片链接
+	MediaID string // 图片媒体ID
+}
+
+// OfficialAccountSubscribeEvent 关注事件
+type OfficialAccountSubscribeEvent struct {
+	EventKey string // 扫描带参数二维码关注时为 qrscene_ 加场景值
+	Ticket   string // 二维码 ticket，扫码关注时有效
+	SceneStr string // 去掉 qrscene_ 前缀的场景值，普通关注时为空
+}
+
+// OfficialAccountScanEvent 已关注用户扫描带参数二维码事件
+type OfficialAccountScanEvent struct {
+	SceneStr string // 二维码场景值
+	Ticket   string // 二维码 ticket
+}
+
+// OfficialAccountClickEvent 点击菜单拉取消息事件
+type OfficialAccountClickEvent struct {
+	EventKey string // 菜单 key
+}
+
+// OfficialAccountViewEvent 点击菜单跳转链接事件
+type OfficialAccountViewEvent struct {
+	URL    string // 跳转的链接
+	MenuID string // 个性化菜单ID
+}
+
+// OfficialAccountLocationEvent 上报地理位置事件
+type OfficialAccountLocationEvent struct {
+	Latitude  float64 // 纬度
+	Longitude float64 // 经度
+	Precision float64 // 精度
+}
+
+// OfficialAccountTemplateSendEvent 模板消息发送结果事件
+type OfficialAccountTemplateSendEvent struct {
+	MsgID  int64  // 模板消息ID
+	Status string // 发送状态，success 为成功，failed:user block 为用户拒收，failed: system failed 为其他原因
+}
+
+// Text 获取文本消息
+func (m *OfficialAccountMessage) Text() (*OfficialAccountTextMessage, bool) {
+	if m.MsgType != OfficialAccountMsgTypeText {
+		return nil, false
+	}
+	return &OfficialAccountTextMessage{Content: m.fields.Content}, true
+}
+
+// Image 获取图片消息
+func (m *OfficialAccountMessage) Image() (*OfficialAccountImageMessage, bool) {
+	if m.MsgType != OfficialAccountMsgTypeImage {
+		return nil, false
+	}
+	return &OfficialAccountImageMessage{PicURL: m.fields.PicURL, MediaID: m.fields.MediaID}, true
+}
+
+// SubscribeEvent 获取关注事件
+func (m *OfficialAccountMessage) SubscribeEvent() (*OfficialAccountSubscribeEvent, bool) {
+	if !m.isEvent(OfficialAccountEventSubscribe) {
+		return nil, false
+	}
+	return &OfficialAccountSubscribeEvent{
+		EventKey: m.fields.EventKey,
+		Ticket:   m.fields.Ticket,
+		SceneStr: strings.TrimPrefix(m.fields.EventKey, qrScenePrefix),
+	}, true
+}
+
+// ScanEvent 获取已关注用户扫码事件
+func (m *OfficialAccountMessage) ScanEvent() (*OfficialAccountScanEvent, bool) {
+	if !m.isEvent(OfficialAccountEventScan) {
+		return nil, false
+	}
+	return &OfficialAccountScanEvent{SceneStr: m.fields.EventKey, Ticket: m.fields.Ticket}, true
+}
+
+// ClickEvent 获取点击菜单拉取消息事件
+func (m *OfficialAccountMessage) ClickEvent() (*OfficialAccountClickEvent, bool) {
+	if !m.isEvent(OfficialAccountEventClick) {
+		return nil, false
+	}
+	return &OfficialAccountClickEvent{EventKey: m.fields.EventKey}, true
+}
+
+// ViewEvent 获取点击菜单跳转链接事件
+func (m *OfficialAccountMessage) ViewEvent() (*OfficialAccountViewEvent, bool) {
+	if !m.isEvent(OfficialAccountEventView) {
+		return nil, false
+	}
+	return &OfficialAccountViewEvent{URL: m.fields.EventKey, MenuID: m.fields.MenuID}, true
+}
+
+// LocationEvent 获取上报地理位置事件
+func (m *OfficialAccountMessage) LocationEvent() (*OfficialAccountLocationEvent, bool) {
+	if !m.isEvent(OfficialAccountEventLocation) {
+		return nil, false
+	}
+	return &OfficialAccountLocationEvent{
+		Latitude:  m.fields.Latitude,
+		Longitude: m.fields.Longitude,
+		Precision: m.fields.Precision,
+	}, true
+}
+
+// TemplateSendEvent 获取模板消息发送结果事件
+func (m *OfficialAccountMessage) TemplateSendEvent() (*OfficialAccountTemplateSendEvent, bool) {
+	if !m.isEvent(OfficialAccountEventTemplateSendJobFinish) {
+		return nil, false
+	}
+	return &OfficialAccountTemplateSendEvent{MsgID: m.fields.MsgID, Status: m.fields.Status}, true
+}
+
+// isEvent 是否为指定类型的事件
+func (m *OfficialAccountMessage) isEvent(event string) bool {
+	return m.MsgType == OfficialAccountMsgTypeEvent && m.Event == event
+}
+
+// parseOfficialAccountMessage 解析公众号推送的 XML
+func parseOfficialAccountMessage(plain []byte) (*OfficialAccountMessage, error) {
+	var fields officialAccountMessageFields
+	if err := xml.Unmarshal(plain, &fields); err != nil {
+		return nil, err
+	}
+
+	return &OfficialAccountMessage{
+		ToUserName:   fields.ToUserName,
+		FromUserName: fields.FromUserName,
+		CreateTime:   fields.CreateTime,
+		MsgType:      fields.MsgType,
+		Event:        fields.Event,
+		MsgID:        fields.MsgId,
+		Raw:          plain,
+		fields:       fields,
+	}, nil
+}
+
+// ==================== 被动回复 ====================
+
+// OfficialAccountReply 被动回复消息，需在 5 秒内返回
+type OfficialAccountReply struct {
+	msgType   string
+	content   string
+	mediaID   string
+	articles  []*ReplyArticle
+	kfAccount string
+}
+
+// ReplyArticle 图文回复中的文章
+type ReplyArticle struct {
+	Title       string `xml:"Title"`       // 标题
+	Description string `xml:"Description"` // 描述
+	PicURL      string `xml:"PicUrl"`      // 图片链接，大图 360*200，小图 200*200
+	URL         string `xml:"Url"`         // 点击跳转链接
+}
+
+// NewTextReply 回复文本消息
+func NewTextReply(content string) *OfficialAccountReply {
+	return &OfficialAccountReply{msgType: "text", content: content}
+}
+
+// NewImageReply 回复图片消息
+func NewImageReply(mediaID string) *OfficialAccountReply {
+	return &OfficialAccountReply{msgType: "image", mediaID: mediaID}
+}
+
+// NewNewsReply 回复图文消息，目前只支持一条图文
+func NewNewsReply(articles ...*ReplyArticle) *OfficialAccountReply {
+	return &OfficialAccountReply{msgType: "news", articles: articles}
+}
+
+// NewTransferCustomerServiceReply 将消息转发到客服
+// kfAccount: 指定接待的客服账号，为空时由系统分配
+func NewTransferCustomerServiceReply(kfAccount string) *OfficialAccountReply {
+	return &OfficialAccountReply{msgType: "transfer_customer_service", kfAccount: kfAccount}
+}
+
+// officialAccountReplyXML 被动回复的 XML 结构
+type officialAccountReplyXML struct {
+	XMLName      xml.Name `xml:"xml"`
+	ToUserName   cdata    `xml:"ToUserName"`
+	FromUserName cdata    `xml:"FromUserName"`
+	CreateTime   int64    `xml:"CreateTime"`
+	MsgType      cdata    `xml:"MsgType"`
+	Content      cdata    `xml:"Content,omitempty"`
+	Image        *struct {
+		MediaID cdata `xml:"MediaId"`
+	} `xml:"Image,omitempty"`
+	ArticleCount int `xml:"ArticleCount,omitempty"`
+	Articles     *struct {
+		Items []*ReplyArticle `xml:"item"`
+	} `xml:"Articles,omitempty"`
+	TransInfo *struct {
+		KfAccount cdata `xml:"KfAccount"`
+	} `xml:"TransInfo,omitempty"`
+}
+
+// marshal 生成回复给 msg 发送者的 XML
+func (r *OfficialAccountReply) marshal(msg *OfficialAccountMessage) ([]byte, error) {
+	data := &officialAccountReplyXML{
+		ToUserName:   cdata(msg.FromUserName),
+		FromUserName: cdata(msg.ToUserName),
+		CreateTime:   time.Now().Unix(),
+		MsgType:      cdata(r.msgType),
+		Content:      cdata(r.content),
+	}
+	switch r.msgType {
+	case "image":
+		data.Image = &struct {
+			MediaID cdata `xml:"MediaId"`
+		}{MediaID: cdata(r.mediaID)}
+	case "news":
+		if len(r.articles) == 0 {
+			return nil, errors.New("图文回复至少需要一篇文章")
+		}
+		data.ArticleCount = len(r.articles)
+		data.Articles = &struct {
+			Items []*ReplyArticle `xml:"item"`
+		}{Items: r.articles}
+	case "transfer_customer_service":
+		if r.kfAccount != "" {
+			data.TransInfo = &struct {
+				KfAccount cdata `xml:"KfAccount"`
+			}{KfAccount: cdata(r.kfAccount)}
+		}
+	}
+
+	return xml.Marshal(data)
+}
+
+// ==================== HTTP 处理器 ====================
+
+// OfficialAccountMessageHandler 公众号消息处理器
+// 返回的回复不为 nil 时作为被动回复发送给用户
+type OfficialAccountMessageHandler interface {
+	OnIncomingMessage(msg *OfficialAccountMessage) (*OfficialAccountReply, error)
+}
+
+// OfficialAccountMessageHandlerFunc 函数形式的公众号消息处理器
+type OfficialAccountMessageHandlerFunc func(msg *OfficialAccountMessage) (*OfficialAccountReply, error)
+
+// OnIncomingMessage 处理消息
+func (f OfficialAccountMessageHandlerFunc) OnIncomingMessage(msg *OfficialAccountMessage) (*OfficialAccountReply, error) {
+	return f(msg)
+}
+
+// OfficialAccountHTTPHandler 公众号消息 HTTP 处理器
+type OfficialAccountHTTPHandler struct {
+	appID          string
+	token          string
+	encodingAESKey string
+	handler        OfficialAccountMessageHandler
+}
+
+var _ http.Handler = (*OfficialAccountHTTPHandler)(nil)
+
+// CreateHTTPHandler 创建HTTP处理器用于接收公众号消息和事件
+// 支持明文、兼容和安全模式，安全模式下被动回复同样加密
+func (c *OfficialAccountClient) CreateHTTPHandler(handler OfficialAccountMessageHandler) (*OfficialAccountHTTPHandler, error) {
+	if c.config.Token == "" {
+		return nil, errors.New("服务器配置 Token 不能为空")
+	}
+	if c.config.EncodingAESKey != "" && len(c.config.EncodingAESKey) != 43 {
+		return nil, errors.New("EncodingAESKey 长度必须为 43")
+	}
+	if handler == nil {
+		return nil, errors.New("消息处理器不能为空")
+	}
+
+	return &OfficialAccountHTTPHandler{
+		appID:          c.config.AppId,
+		token:          c.config.Token,
+		encodingAESKey: c.config.EncodingAESKey,
+		handler:        handler,
+	}, nil
+}
+
+// ServeHTTP 处理公众号推送请求
+func (h *OfficialAccountHTTPHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet:
+		// 配置服务器地址时的 URL 验证
+		if !verifyCallbackSignature(query.Get("signature"), h.token, query.Get("timestamp"), query.Get("nonce")) {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte(query.Get("echostr")))
+
+	case http.MethodPost:
+		defer r.Body.Close()
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_, plain, err := openCallbackBody(query, body, h.appID, h.token, h.encodingAESKey)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		msg, err := parseOfficialAccountMessage(plain)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		reply, err := h.handler.OnIncomingMessage(msg)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if reply == nil {
+			rw.WriteHeader(http.StatusOK)
+			_, _ = rw.Write([]byte("success"))
+			return
+		}
+
+		data, err := reply.marshal(msg)
+		if err == nil && query.Get("encrypt_type") == "aes" {
+			data, err = encryptXMLReply(data, h.appID, h.token, h.encodingAESKey, query.Get("timestamp"), query.Get("nonce"))
+		}
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write(data)
+
+	default:
+		rw.WriteHeader(http.StatusNotImplemented)
+	}
+}
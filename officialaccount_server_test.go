@@ -0,0 +1,131 @@
+package wechat_test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darwinOrg/go-wechat"
+	"github.com/silenceper/wechat/v2/util"
+)
+
+// TestOfficialAccountHTTPHandler 测试公众号消息解析、被动回复和安全模式加解密
+func TestOfficialAccountHTTPHandler(t *testing.T) {
+	oaClient := wechat.NewOfficialAccountClient(&wechat.OfficialAccountConfig{
+		AppId:          "wx_test_oa_appid",
+		AppSecret:      "test_secret",
+		Token:          testPushToken,
+		EncodingAESKey: testPushAESKey,
+	})
+
+	// 明文模式使用未配置 EncodingAESKey 的公众号
+	plainClient := wechat.NewOfficialAccountClient(&wechat.OfficialAccountConfig{
+		AppId:     "wx_test_oa_appid",
+		AppSecret: "test_secret",
+		Token:     testPushToken,
+	})
+
+	var templateEvent *wechat.OfficialAccountTemplateSendEvent
+	msgHandler := wechat.OfficialAccountMessageHandlerFunc(func(msg *wechat.OfficialAccountMessage) (*wechat.OfficialAccountReply, error) {
+		if text, ok := msg.Text(); ok {
+			if text.Content == "人工" {
+				return wechat.NewTransferCustomerServiceReply(""), nil
+			}
+			return wechat.NewTextReply("收到: " + text.Content), nil
+		}
+		if event, ok := msg.SubscribeEvent(); ok {
+			return wechat.NewNewsReply(&wechat.ReplyArticle{Title: "欢迎关注", Description: event.SceneStr, URL: "https://example.com"}), nil
+		}
+		if event, ok := msg.TemplateSendEvent(); ok {
+			templateEvent = event
+		}
+		return nil, nil
+	})
+	handler, err := oaClient.CreateHTTPHandler(msgHandler)
+	if err != nil {
+		t.Fatalf("CreateHTTPHandler failed: %v", err)
+	}
+	plainHandler, err := plainClient.CreateHTTPHandler(msgHandler)
+	if err != nil {
+		t.Fatalf("CreateHTTPHandler failed: %v", err)
+	}
+
+	// 明文模式文本消息
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	query := url.Values{"timestamp": {timestamp}, "nonce": {"nonce"}}
+	query.Set("signature", util.Signature(testPushToken, timestamp, "nonce"))
+	body := `<xml><ToUserName><![CDATA[gh_test]]></ToUserName><FromUserName><![CDATA[test_openid]]></FromUserName><CreateTime>1700000000</CreateTime><MsgType><![CDATA[text]]></MsgType><Content><![CDATA[你好]]></Content><MsgId>1</MsgId></xml>`
+	rec := httptest.NewRecorder()
+	plainHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/oa?"+query.Encode(), strings.NewReader(body)))
+	var textReply struct {
+		ToUserName   string
+		FromUserName string
+		MsgType      string
+		Content      string
+	}
+	if err := xml.Unmarshal(rec.Body.Bytes(), &textReply); err != nil {
+		t.Fatalf("unmarshal reply failed: %v, %s", err, rec.Body.String())
+	}
+	if textReply.ToUserName != "test_openid" || textReply.FromUserName != "gh_test" || textReply.Content != "收到: 你好" {
+		t.Fatalf("unexpected text reply: %+v", textReply)
+	}
+
+	// 明文模式模板消息发送结果事件，无回复
+	body = `<xml><ToUserName>gh_test</ToUserName><FromUserName>test_openid</FromUserName><CreateTime>1700000000</CreateTime><MsgType>event</MsgType><Event>TEMPLATESENDJOBFINISH</Event><MsgID>200</MsgID><Status>success</Status></xml>`
+	rec = httptest.NewRecorder()
+	plainHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/oa?"+query.Encode(), strings.NewReader(body)))
+	if rec.Body.String() != "success" || templateEvent == nil || templateEvent.MsgID != 200 || templateEvent.Status != "success" {
+		t.Fatalf("unexpected template event: %s %+v", rec.Body.String(), templateEvent)
+	}
+
+	// 安全模式扫码关注事件，回复图文并加密
+	plain := `<xml><ToUserName>gh_test</ToUserName><FromUserName>test_openid</FromUserName><CreateTime>1700000000</CreateTime><MsgType>event</MsgType><Event>subscribe</Event><EventKey>qrscene_invite_1</EventKey><Ticket>ticket_1</Ticket></xml>`
+	encrypted, err := util.EncryptMsg([]byte("0123456789abcdef"), []byte(plain), "wx_test_oa_appid", testPushAESKey)
+	if err != nil {
+		t.Fatalf("EncryptMsg failed: %v", err)
+	}
+	query = url.Values{"timestamp": {timestamp}, "nonce": {"nonce"}, "encrypt_type": {"aes"}}
+	query.Set("msg_signature", util.Signature(testPushToken, timestamp, "nonce", string(encrypted)))
+	body = fmt.Sprintf(`<xml><ToUserName>gh_test</ToUserName><Encrypt><![CDATA[%s]]></Encrypt></xml>`, encrypted)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/oa?"+query.Encode(), strings.NewReader(body)))
+
+	var envelope struct {
+		Encrypt      string
+		MsgSignature string
+		TimeStamp    string
+		Nonce        string
+	}
+	if err := xml.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("unmarshal encrypted reply failed: %v, %s", err, rec.Body.String())
+	}
+	if envelope.MsgSignature != util.Signature(testPushToken, envelope.TimeStamp, envelope.Nonce, envelope.Encrypt) {
+		t.Fatalf("invalid reply signature: %+v", envelope)
+	}
+	_, replyPlain, err := util.DecryptMsg("wx_test_oa_appid", envelope.Encrypt, testPushAESKey)
+	if err != nil {
+		t.Fatalf("DecryptMsg failed: %v", err)
+	}
+	var newsReply struct {
+		MsgType      string
+		ArticleCount int
+		Articles     struct {
+			Items []struct {
+				Title       string
+				Description string
+			} `xml:"item"`
+		}
+	}
+	if err := xml.Unmarshal(replyPlain, &newsReply); err != nil {
+		t.Fatalf("unmarshal news reply failed: %v", err)
+	}
+	if newsReply.MsgType != "news" || newsReply.ArticleCount != 1 || newsReply.Articles.Items[0].Description != "invite_1" {
+		t.Fatalf("unexpected news reply: %+v", newsReply)
+	}
+}
@@ -0,0 +1,122 @@
+package wechat_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darwinOrg/go-wechat"
+	"github.com/silenceper/wechat/v2/util"
+)
+
+// newMockOfficialAccountClient 创建连接到模拟接口的公众号客户端
+func newMockOfficialAccountClient(t *testing.T, mux *http.ServeMux) *wechat.OfficialAccountClient {
+	mux.HandleFunc("/cgi-bin/stable_token", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "mock_token", "expires_in": 7200})
+	})
+	server := httptest.NewServer(mux)
+	util.SetURIModifier(func(uri string) string {
+		return strings.Replace(uri, "https://api.weixin.qq.com", server.URL, 1)
+	})
+	t.Cleanup(func() {
+		util.SetURIModifier(nil)
+		server.Close()
+	})
+
+	return wechat.NewOfficialAccountClient(&wechat.OfficialAccountConfig{
+		AppId:     "wx_test_oa_appid",
+		AppSecret: "test_secret",
+	})
+}
+
+// TestOfficialAccountClient_TemplateAndQRCode 测试模板消息、临时二维码和用户信息
+func TestOfficialAccountClient_TemplateAndQRCode(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgi-bin/message/template/send", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Query().Get("access_token") != "mock_token" || req["template_id"] != "tpl_1" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 40037, "errmsg": "invalid template_id"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "msgid": 200})
+	})
+	mux.HandleFunc("/cgi-bin/qrcode/create", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["action_name"] != "QR_STR_SCENE" || req["expire_seconds"] != float64(3600) {
+			_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 40001, "errmsg": "invalid request"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"ticket": "ticket_1", "expire_seconds": 3600, "url": "http://weixin.qq.com/q/1"})
+	})
+	mux.HandleFunc("/cgi-bin/user/info", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("openid") != "test_openid" || r.URL.Query().Get("lang") != "zh_CN" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 40003, "errmsg": "invalid openid"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"subscribe": 1, "openid": "test_openid", "unionid": "test_unionid"})
+	})
+	oaClient := newMockOfficialAccountClient(t, mux)
+	ctx := context.Background()
+
+	msgID, err := oaClient.SendTemplateMessage(ctx, &wechat.TemplateMessage{
+		ToUser:     "test_openid",
+		TemplateID: "tpl_1",
+		Data:       map[string]*wechat.TemplateDataItem{"thing1": {Value: "测试"}},
+	})
+	if err != nil || msgID != 200 {
+		t.Fatalf("SendTemplateMessage failed: %d, %v", msgID, err)
+	}
+
+	ticket, err := oaClient.CreateTempQRCode(ctx, "invite_1", time.Hour)
+	if err != nil || ticket.Ticket != "ticket_1" {
+		t.Fatalf("CreateTempQRCode failed: %+v, %v", ticket, err)
+	}
+	if _, err := oaClient.CreateTempQRCode(ctx, "invite_1", 31*24*time.Hour); err == nil || !strings.Contains(err.Error(), "30 天") {
+		t.Fatalf("expected error for expire over 30 days, got %v", err)
+	}
+
+	info, err := oaClient.GetUserInfo(ctx, "test_openid")
+	if err != nil || info.UnionID != "test_unionid" {
+		t.Fatalf("GetUserInfo failed: %+v, %v", info, err)
+	}
+	if _, err := oaClient.GetUserInfo(ctx, "unknown"); err == nil {
+		t.Fatal("expected error for unknown openid")
+	}
+}
+
+// TestOfficialAccountClient_OAuth 测试网页授权换取用户信息
+func TestOfficialAccountClient_OAuth(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sns/oauth2/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("code") != "test_code" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 40029, "errmsg": "invalid code"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "user_token", "expires_in": 7200, "openid": "test_openid", "scope": "snsapi_userinfo"})
+	})
+	mux.HandleFunc("/sns/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"openid": r.URL.Query().Get("openid"), "nickname": "测试用户"})
+	})
+	oaClient := newMockOfficialAccountClient(t, mux)
+	ctx := context.Background()
+
+	redirectURL, err := oaClient.GetOAuthRedirectURL("https://example.com/callback", wechat.OAuthScopeBase, "state_1")
+	if err != nil || !strings.Contains(redirectURL, "scope=snsapi_base") {
+		t.Fatalf("GetOAuthRedirectURL failed: %s, %v", redirectURL, err)
+	}
+
+	token, err := oaClient.ExchangeOAuthCode(ctx, "test_code")
+	if err != nil || token.OpenID != "test_openid" {
+		t.Fatalf("ExchangeOAuthCode failed: %+v, %v", token, err)
+	}
+	info, err := oaClient.GetOAuthUserInfo(ctx, token, "zh_CN")
+	if err != nil || info.Nickname != "测试用户" {
+		t.Fatalf("GetOAuthUserInfo failed: %+v, %v", info, err)
+	}
+}
@@ -0,0 +1,258 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/silenceper/wechat/v2/cache"
+	"github.com/silenceper/wechat/v2/util"
+)
+
+const (
+	// defaultPayBaseURL 微信支付 APIv3 接口地址
+	defaultPayBaseURL = "https://api.mch.weixin.qq.com"
+	// paySignatureMaxSkew 应答和回调签名时间戳允许的最大偏差
+	paySignatureMaxSkew = 5 * time.Minute
+)
+
+// PayConfig 微信支付配置
+type PayConfig struct {
+	AppId      string       `json:"appId" yaml:"appId" mapstructure:"appId"`                // 发起支付的小程序或公众号 appid
+	MchId      string       `json:"mchId" yaml:"mchId" mapstructure:"mchId"`                // 商户号
+	SerialNo   string       `json:"serialNo" yaml:"serialNo" mapstructure:"serialNo"`       // 商户 API 证书序列号
+	PrivateKey string       `json:"privateKey" yaml:"privateKey" mapstructure:"privateKey"` // 商户 API 证书私钥，PEM 格式
+	APIv3Key   string       `json:"apiV3Key" yaml:"apiV3Key" mapstructure:"apiV3Key"`       // APIv3 密钥，32 字节
+	NotifyURL  string       `json:"notifyUrl" yaml:"notifyUrl" mapstructure:"notifyUrl"`    // 默认支付结果通知地址
+	BaseURL    string       `json:"baseUrl" yaml:"baseUrl" mapstructure:"baseUrl"`          // 接口地址，可选，默认为微信支付正式环境
+	RedisAddr  string       `json:"redisAddr" yaml:"redisAddr" mapstructure:"redisAddr"`    // Redis地址，可选，用于回调通知去重，配置 Redis 时忽略
+	Redis      *RedisConfig `json:"redis" yaml:"redis" mapstructure:"redis"`                // Redis 连接配置，可选，相同配置的客户端共用连接池
+}
+
+// PayClient 微信支付 APIv3 客户端
+type PayClient struct {
+	config     *PayConfig
+	baseURL    string
+	privateKey *rsa.PrivateKey
+	httpClient *http.Client
+	cache      cache.Cache
+
+	certMu          sync.RWMutex
+	certificates    map[string]*PlatformCertificate
+	certRefreshedAt time.Time  // 最近一次下载平台证书的时间
+	certRefreshMu   sync.Mutex // 串行下载平台证书，并发请求共用一次下载结果
+}
+
+// PayError 微信支付返回的错误
+type PayError struct {
+	StatusCode int             `json:"-"`       // HTTP 状态码
+	Code       string          `json:"code"`    // 错误码
+	Message    string          `json:"message"` // 错误描述
+	Detail     json.RawMessage `json:"detail"`  // 错误详情
+}
+
+func (e *PayError) Error() string {
+	return fmt.Sprintf("微信支付返回错误: %d %s - %s", e.StatusCode, e.Code, e.Message)
+}
+
+// NewPayClient 创建微信支付客户端
+func NewPayClient(cfg *PayConfig) (*PayClient, error) {
+	if cfg.MchId == "" || cfg.SerialNo == "" {
+		return nil, errors.New("商户号和证书序列号不能为空")
+	}
+	if len(cfg.APIv3Key) != 32 {
+		return nil, errors.New("APIv3 密钥长度必须为 32 字节")
+	}
+
+	privateKey, err := parsePayPrivateKey(cfg.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultPayBaseURL
+	}
+
+	return &PayClient{
+		config:     cfg,
+		baseURL:    baseURL,
+		privateKey: privateKey,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		cache:        newCache(cfg.Redis, cfg.RedisAddr),
+		certificates: make(map[string]*PlatformCertificate),
+	}, nil
+}
+
+// GetConfig 获取配置
+func (c *PayClient) GetConfig() *PayConfig {
+	return c.config
+}
+
+// parsePayPrivateKey 解析 PEM 格式的商户私钥，支持 PKCS#8 和 PKCS#1
+func parsePayPrivateKey(pemData string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemData))
+	if block == nil {
+		return nil, errors.New("商户私钥不是有效的 PEM 格式")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("商户私钥不是 RSA 私钥")
+		}
+		return rsaKey, nil
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析商户私钥失败: %w", err)
+	}
+
+	return key, nil
+}
+
+// sign 使用商户私钥进行 SHA256-RSA 签名
+func (c *PayClient) sign(message string) (string, error) {
+	hashed := sha256.Sum256([]byte(message))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.privateKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", fmt.Errorf("签名失败: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// authorization 生成请求的 Authorization 头
+func (c *PayClient) authorization(method, canonicalURL string, body []byte) (string, error) {
+	nonce := util.RandomStr(32)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature, err := c.sign(method + "\n" + canonicalURL + "\n" + timestamp + "\n" + nonce + "\n" + string(body) + "\n")
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`WECHATPAY2-SHA256-RSA2048 mchid="%s",nonce_str="%s",signature="%s",timestamp="%s",serial_no="%s"`,
+		c.config.MchId, nonce, signature, timestamp, c.config.SerialNo), nil
+}
+
+// verifySignature 使用平台证书验证应答或回调的签名
+// minRefreshInterval 为本地没有对应证书时距上次下载的最小间隔，为 0 时总是重新下载
+func (c *PayClient) verifySignature(ctx context.Context, header http.Header, body []byte, minRefreshInterval time.Duration) error {
+	cert, err := c.platformCertificate(ctx, header.Get("Wechatpay-Serial"), minRefreshInterval)
+	if err != nil {
+		return err
+	}
+
+	return verifyPaySignature(cert, header, body)
+}
+
+// verifyPaySignature 验证签名，签名串为 时间戳\n随机串\n报文主体\n
+func verifyPaySignature(cert *PlatformCertificate, header http.Header, body []byte) error {
+	timestamp := header.Get("Wechatpay-Timestamp")
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("签名时间戳无效")
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > paySignatureMaxSkew || skew < -paySignatureMaxSkew {
+		return errors.New("签名时间戳已过期")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(header.Get("Wechatpay-Signature"))
+	if err != nil {
+		return errors.New("签名格式无效")
+	}
+
+	publicKey, ok := cert.Certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("平台证书不是 RSA 公钥")
+	}
+	hashed := sha256.Sum256([]byte(timestamp + "\n" + header.Get("Wechatpay-Nonce") + "\n" + string(body) + "\n"))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], signature); err != nil {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// doRequest 发送签名后的请求，返回应答头和应答体，HTTP 状态码非 2xx 时返回 PayError
+func (c *PayClient) doRequest(ctx context.Context, method, path string, reqBody any) (http.Header, []byte, error) {
+	var body []byte
+	if reqBody != nil {
+		var err error
+		if body, err = json.Marshal(reqBody); err != nil {
+			return nil, nil, fmt.Errorf("序列化请求失败: %w", err)
+		}
+	}
+
+	auth, err := c.authorization(method, path, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	httpReq.Header.Set("Authorization", auth)
+	httpReq.Header.Set("Accept", "application/json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		payErr := &PayError{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(respBody, payErr)
+		return nil, nil, payErr
+	}
+
+	return resp.Header, respBody, nil
+}
+
+// call 发送请求并验证应答签名，result 不为 nil 时解析应答体
+func (c *PayClient) call(ctx context.Context, method, path string, reqBody, result any) error {
+	header, body, err := c.doRequest(ctx, method, path, reqBody)
+	if err != nil {
+		return err
+	}
+
+	if err := c.verifySignature(ctx, header, body, 0); err != nil {
+		return fmt.Errorf("验证应答签名失败: %w", err)
+	}
+
+	if result != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, result); err != nil {
+			return fmt.Errorf("解析响应失败: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package wechat
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	payCertificatesPath = "/v3/certificates"
+	// payAEADAlgorithm 微信支付回调和平台证书使用的加密算法
+	payAEADAlgorithm = "AEAD_AES_256_GCM"
+	// payCertificateMinRefreshInterval 回调通知触发下载平台证书的最小间隔，避免伪造的证书序列号频繁触发下载
+	payCertificateMinRefreshInterval = 5 * time.Minute
+)
+
+// ErrPlatformCertificateNotFound 未找到平台证书
+var ErrPlatformCertificateNotFound = errors.New("未找到平台证书")
+
+// PlatformCertificate 微信支付平台证书
+type PlatformCertificate struct {
+	SerialNo      string            // 证书序列号
+	EffectiveTime time.Time         // 生效时间
+	ExpireTime    time.Time         // 过期时间
+	Certificate   *x509.Certificate // 证书
+}
+
+// PayEncryptedResource 微信支付加密数据
+type PayEncryptedResource struct {
+	Algorithm      string `json:"algorithm"`               // 加密算法，固定为 AEAD_AES_256_GCM
+	Ciphertext     string `json:"ciphertext"`              // Base64 编码的密文
+	AssociatedData string `json:"associated_data"`         // 附加数据
+	Nonce          string `json:"nonce"`                   // 加密使用的随机串
+	OriginalType   string `json:"original_type,omitempty"` // 原始数据类型
+}
+
+// decryptResource 使用 APIv3 密钥解密数据
+func (c *PayClient) decryptResource(r *PayEncryptedResource) ([]byte, error) {
+	if r.Algorithm != payAEADAlgorithm {
+		return nil, fmt.Errorf("不支持的加密算法: %s", r.Algorithm)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(r.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("密文格式无效: %w", err)
+	}
+
+	block, err := aes.NewCipher([]byte(c.config.APIv3Key))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCMWithNonceSize(block, len(r.Nonce))
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, []byte(r.Nonce), ciphertext, []byte(r.AssociatedData))
+	if err != nil {
+		return nil, fmt.Errorf("解密失败: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// DownloadCertificates 下载平台证书并替换本地证书列表
+// 新证书会在旧证书过期前下发，定期下载即可完成证书轮换
+func (c *PayClient) DownloadCertificates(ctx context.Context) ([]*PlatformCertificate, error) {
+	header, body, err := c.doRequest(ctx, http.MethodGet, payCertificatesPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data []struct {
+			SerialNo           string                `json:"serial_no"`
+			EffectiveTime      time.Time             `json:"effective_time"`
+			ExpireTime         time.Time             `json:"expire_time"`
+			EncryptCertificate *PayEncryptedResource `json:"encrypt_certificate"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	certs := make(map[string]*PlatformCertificate, len(resp.Data))
+	list := make([]*PlatformCertificate, 0, len(resp.Data))
+	for _, item := range resp.Data {
+		if item.EncryptCertificate == nil {
+			continue
+		}
+		plaintext, err := c.decryptResource(item.EncryptCertificate)
+		if err != nil {
+			return nil, fmt.Errorf("解密平台证书 %s 失败: %w", item.SerialNo, err)
+		}
+		block, _ := pem.Decode(plaintext)
+		if block == nil {
+			return nil, fmt.Errorf("平台证书 %s 不是有效的 PEM 格式", item.SerialNo)
+		}
+		x509Cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("解析平台证书 %s 失败: %w", item.SerialNo, err)
+		}
+
+		cert := &PlatformCertificate{
+			SerialNo:      item.SerialNo,
+			EffectiveTime: item.EffectiveTime,
+			ExpireTime:    item.ExpireTime,
+			Certificate:   x509Cert,
+		}
+		certs[cert.SerialNo] = cert
+		list = append(list, cert)
+	}
+
+	// 证书列表应答使用其中的证书签名，验证通过后才替换本地证书
+	cert, ok := certs[header.Get("Wechatpay-Serial")]
+	if !ok {
+		return nil, fmt.Errorf("应答签名使用的平台证书 %s 不在下载列表中", header.Get("Wechatpay-Serial"))
+	}
+	if err := verifyPaySignature(cert, header, body); err != nil {
+		return nil, fmt.Errorf("验证应答签名失败: %w", err)
+	}
+
+	c.certMu.Lock()
+	c.certificates = certs
+	c.certRefreshedAt = time.Now()
+	c.certMu.Unlock()
+
+	return list, nil
+}
+
+// GetCertificates 获取本地的平台证书列表
+func (c *PayClient) GetCertificates() []*PlatformCertificate {
+	c.certMu.RLock()
+	defer c.certMu.RUnlock()
+
+	list := make([]*PlatformCertificate, 0, len(c.certificates))
+	for _, cert := range c.certificates {
+		list = append(list, cert)
+	}
+
+	return list
+}
+
+// StartCertificateRotation 定期下载平台证书，ctx 取消后停止
+// onError 用于接收下载失败的错误，可为 nil
+func (c *PayClient) StartCertificateRotation(ctx context.Context, interval time.Duration, onError func(error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := c.DownloadCertificates(ctx); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
+
+// platformCertificate 根据序列号获取平台证书，本地不存在时重新下载
+// 并发请求共用一次下载，距上次下载不足 minRefreshInterval 时不再下载
+func (c *PayClient) platformCertificate(ctx context.Context, serialNo string, minRefreshInterval time.Duration) (*PlatformCertificate, error) {
+	if serialNo == "" {
+		return nil, errors.New("缺少平台证书序列号")
+	}
+
+	if cert := c.lookupCertificate(serialNo); cert != nil {
+		return cert, nil
+	}
+
+	c.certRefreshMu.Lock()
+	defer c.certRefreshMu.Unlock()
+
+	// 等待期间其他请求可能已完成下载
+	if cert := c.lookupCertificate(serialNo); cert != nil {
+		return cert, nil
+	}
+
+	c.certMu.Lock()
+	recent := minRefreshInterval > 0 && time.Since(c.certRefreshedAt) < minRefreshInterval
+	if !recent {
+		// 下载失败同样计入间隔，避免微信支付不可用时重复请求
+		c.certRefreshedAt = time.Now()
+	}
+	c.certMu.Unlock()
+	if recent {
+		return nil, fmt.Errorf("%w: %s", ErrPlatformCertificateNotFound, serialNo)
+	}
+
+	if _, err := c.DownloadCertificates(ctx); err != nil {
+		return nil, fmt.Errorf("下载平台证书失败: %w", err)
+	}
+	if cert := c.lookupCertificate(serialNo); cert != nil {
+		return cert, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrPlatformCertificateNotFound, serialNo)
+}
+
+// lookupCertificate 查找本地未过期的平台证书
+func (c *PayClient) lookupCertificate(serialNo string) *PlatformCertificate {
+	c.certMu.RLock()
+	defer c.certMu.RUnlock()
+
+	cert, ok := c.certificates[serialNo]
+	if !ok || (!cert.ExpireTime.IsZero() && time.Now().After(cert.ExpireTime)) {
+		return nil
+	}
+
+	return cert
+}
@@ -0,0 +1,267 @@
+package wechat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/silenceper/wechat/v2/cache"
+)
+
+const (
+	// defaultPayNotifyDedupTTL 回调通知去重记录的保存时长，微信支付会在 24 小时内多次重试
+	defaultPayNotifyDedupTTL = 48 * time.Hour
+	// payNotifyClaimTTL 处理中记录的保存时长，处理过程中进程退出时到期后可重新处理
+	payNotifyClaimTTL = 5 * time.Minute
+)
+
+// 去重记录的值
+const (
+	payNotifyProcessing = "processing"
+	payNotifyDone       = "done"
+)
+
+// ErrPayNotifyProcessing 同一通知正在处理中，应答失败等待微信支付稍后重试
+var ErrPayNotifyProcessing = errors.New("通知正在处理中")
+
+// 回调通知事件类型
+const (
+	PayEventTransactionSuccess = "TRANSACTION.SUCCESS" // 支付成功
+	PayEventRefundSuccess      = "REFUND.SUCCESS"      // 退款成功
+	PayEventRefundAbnormal     = "REFUND.ABNORMAL"     // 退款异常
+	PayEventRefundClosed       = "REFUND.CLOSED"       // 退款关闭
+)
+
+// PayNotification 回调通知
+type PayNotification struct {
+	ID           string                `json:"id"`            // 通知ID，重复推送的通知ID相同
+	CreateTime   string                `json:"create_time"`   // 通知创建时间
+	EventType    string                `json:"event_type"`    // 通知类型
+	ResourceType string                `json:"resource_type"` // 通知数据类型
+	Summary      string                `json:"summary"`       // 回调摘要
+	Resource     *PayEncryptedResource `json:"resource"`      // 加密的通知数据
+	Plaintext    []byte                `json:"-"`             // 解密后的通知数据
+}
+
+// PayRefundNotification 退款结果通知
+type PayRefundNotification struct {
+	MchID               string `json:"mchid"`
+	OutTradeNo          string `json:"out_trade_no"`          // 商户订单号
+	TransactionID       string `json:"transaction_id"`        // 微信支付订单号
+	OutRefundNo         string `json:"out_refund_no"`         // 商户退款单号
+	RefundID            string `json:"refund_id"`             // 微信支付退款单号
+	RefundStatus        string `json:"refund_status"`         // 退款状态
+	SuccessTime         string `json:"success_time"`          // 退款成功时间
+	UserReceivedAccount string `json:"user_received_account"` // 退款入账账户
+	Amount              struct {
+		Total       int64 `json:"total"`        // 订单金额
+		Refund      int64 `json:"refund"`       // 退款金额
+		PayerTotal  int64 `json:"payer_total"`  // 用户支付金额
+		PayerRefund int64 `json:"payer_refund"` // 用户退款金额
+	} `json:"amount"`
+}
+
+// PayNotifyHandlers 回调通知处理函数
+// 处理函数返回错误时应答失败，微信支付会稍后重试
+type PayNotifyHandlers struct {
+	OnTransaction func(ctx context.Context, notification *PayNotification, transaction *PayTransaction) error   // 支付成功通知
+	OnRefund      func(ctx context.Context, notification *PayNotification, refund *PayRefundNotification) error // 退款结果通知
+}
+
+// PayNotifyDedupStore 回调通知去重存储
+// 多实例部署时需使用支持原子写入的存储实现（如 Redis SET NX），保证同一通知只被一个实例处理
+type PayNotifyDedupStore interface {
+	// Claim 处理通知前占用通知ID，返回 false 表示通知已处理完成，通知正在处理中时返回 ErrPayNotifyProcessing
+	Claim(ctx context.Context, id string) (bool, error)
+	// Complete 标记通知已处理完成
+	Complete(ctx context.Context, id string) error
+	// Release 处理失败时释放占用，微信支付重试时可再次处理
+	Release(ctx context.Context, id string) error
+}
+
+// cachePayNotifyDedupStore 基于 cache.Cache 的回调通知去重存储
+type cachePayNotifyDedupStore struct {
+	cache     cache.Cache
+	keyPrefix string
+	ttl       time.Duration
+	locks     keyLocks // 同一通知ID串行占用
+}
+
+// NewCachePayNotifyDedupStore 创建基于 cache.Cache 的回调通知去重存储
+// 只在进程内按通知ID加锁，多实例部署时并发推送同一通知仍可能重复处理
+// ttl 小于等于 0 时使用默认值 48 小时
+func NewCachePayNotifyDedupStore(c cache.Cache, mchID string, ttl time.Duration) PayNotifyDedupStore {
+	if ttl <= 0 {
+		ttl = defaultPayNotifyDedupTTL
+	}
+
+	return &cachePayNotifyDedupStore{
+		cache:     c,
+		keyPrefix: "pay:notify:" + mchID + ":",
+		ttl:       ttl,
+	}
+}
+
+// Claim 占用通知ID
+func (s *cachePayNotifyDedupStore) Claim(ctx context.Context, id string) (bool, error) {
+	unlock := s.locks.lock(id)
+	defer unlock()
+
+	switch cache.GetContext(ctx, s.cache, s.keyPrefix+id) {
+	case nil:
+	case payNotifyProcessing:
+		return false, ErrPayNotifyProcessing
+	default:
+		return false, nil
+	}
+
+	if err := cache.SetContext(ctx, s.cache, s.keyPrefix+id, payNotifyProcessing, payNotifyClaimTTL); err != nil {
+		return false, fmt.Errorf("保存通知处理记录失败: %w", err)
+	}
+	return true, nil
+}
+
+// Complete 标记通知已处理完成
+func (s *cachePayNotifyDedupStore) Complete(ctx context.Context, id string) error {
+	return cache.SetContext(ctx, s.cache, s.keyPrefix+id, payNotifyDone, s.ttl)
+}
+
+// Release 释放占用
+func (s *cachePayNotifyDedupStore) Release(ctx context.Context, id string) error {
+	return cache.DeleteContext(ctx, s.cache, s.keyPrefix+id)
+}
+
+// PayNotifyHandler 微信支付回调通知 HTTP 处理器
+type PayNotifyHandler struct {
+	client   *PayClient
+	handlers *PayNotifyHandlers
+	dedup    PayNotifyDedupStore
+}
+
+var _ http.Handler = (*PayNotifyHandler)(nil)
+
+// CreateNotifyHandler 创建HTTP处理器用于接收支付和退款结果通知
+// 处理前按通知ID占用去重记录，重复推送的通知直接应答成功，不会再次调用处理函数
+// 同一通知正在处理中时应答失败，处理函数返回错误时释放占用，由微信支付稍后重试
+func (c *PayClient) CreateNotifyHandler(handlers *PayNotifyHandlers) *PayNotifyHandler {
+	if handlers == nil {
+		handlers = &PayNotifyHandlers{}
+	}
+
+	return &PayNotifyHandler{
+		client:   c,
+		handlers: handlers,
+		dedup:    NewCachePayNotifyDedupStore(c.cache, c.config.MchId, defaultPayNotifyDedupTTL),
+	}
+}
+
+// SetDedupStore 自定义回调通知去重存储，默认使用客户端的缓存
+func (h *PayNotifyHandler) SetDedupStore(store PayNotifyDedupStore) {
+	h.dedup = store
+}
+
+// ParseNotification 验证签名并解密回调通知
+// 通知的证书序列号不可信，本地没有对应证书且最近已下载过证书时直接返回错误，不再重新下载
+func (c *PayClient) ParseNotification(ctx context.Context, header http.Header, body []byte) (*PayNotification, error) {
+	if err := c.verifySignature(ctx, header, body, payCertificateMinRefreshInterval); err != nil {
+		return nil, fmt.Errorf("验证通知签名失败: %w", err)
+	}
+
+	var notification PayNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, fmt.Errorf("解析通知失败: %w", err)
+	}
+	if notification.Resource == nil {
+		return nil, errors.New("通知缺少 resource")
+	}
+
+	plaintext, err := c.decryptResource(notification.Resource)
+	if err != nil {
+		return nil, fmt.Errorf("解密通知失败: %w", err)
+	}
+	notification.Plaintext = plaintext
+
+	return &notification, nil
+}
+
+// ServeHTTP 处理回调通知
+func (h *PayNotifyHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writePayNotifyFail(rw, http.StatusInternalServerError, "读取通知失败")
+		return
+	}
+
+	ctx := r.Context()
+	notification, err := h.client.ParseNotification(ctx, r.Header, body)
+	if err != nil {
+		writePayNotifyFail(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	claimed, err := h.dedup.Claim(ctx, notification.ID)
+	if err != nil {
+		writePayNotifyFail(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !claimed {
+		rw.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := h.dispatch(ctx, notification); err != nil {
+		// 处理函数可能因请求取消而失败，释放时不受请求取消影响；释放失败时处理中记录到期后仍可重试
+		_ = h.dedup.Release(context.WithoutCancel(ctx), notification.ID)
+		writePayNotifyFail(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// 写入去重记录失败不影响应答，重复推送时由处理函数自行保证幂等
+	_ = h.dedup.Complete(ctx, notification.ID)
+	rw.WriteHeader(http.StatusOK)
+}
+
+// dispatch 按通知类型解析并分发给对应的处理函数
+func (h *PayNotifyHandler) dispatch(ctx context.Context, notification *PayNotification) error {
+	switch {
+	case strings.HasPrefix(notification.EventType, "TRANSACTION."):
+		if h.handlers.OnTransaction == nil {
+			return nil
+		}
+		var transaction PayTransaction
+		if err := json.Unmarshal(notification.Plaintext, &transaction); err != nil {
+			return fmt.Errorf("解析支付通知失败: %w", err)
+		}
+		return h.handlers.OnTransaction(ctx, notification, &transaction)
+
+	case strings.HasPrefix(notification.EventType, "REFUND."):
+		if h.handlers.OnRefund == nil {
+			return nil
+		}
+		var refund PayRefundNotification
+		if err := json.Unmarshal(notification.Plaintext, &refund); err != nil {
+			return fmt.Errorf("解析退款通知失败: %w", err)
+		}
+		return h.handlers.OnRefund(ctx, notification, &refund)
+	}
+
+	return nil
+}
+
+// writePayNotifyFail 应答处理失败
+func writePayNotifyFail(rw http.ResponseWriter, status int, message string) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(map[string]string{"code": "FAIL", "message": message})
+}
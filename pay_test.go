@@ -0,0 +1,464 @@
+package wechat_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/darwinOrg/go-wechat"
+)
+
+const testPayAPIv3Key = "0123456789abcdef0123456789abcdef"
+
+// mockPayPlatform 模拟微信支付平台，校验请求签名并对应答签名
+type mockPayPlatform struct {
+	t           *testing.T
+	merchantKey *rsa.PrivateKey
+
+	mu            sync.Mutex
+	serialNo      string
+	key           *rsa.PrivateKey
+	certPEM       []byte
+	certDownloads int // 平台证书下载次数
+}
+
+// newMockPayPlatform 创建模拟平台并生成平台证书
+func newMockPayPlatform(t *testing.T) *mockPayPlatform {
+	t.Helper()
+
+	merchantKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	p := &mockPayPlatform{t: t, merchantKey: merchantKey}
+	p.rotate("PLATFORM_SERIAL_1")
+
+	return p
+}
+
+// rotate 更换平台证书
+func (p *mockPayPlatform) rotate(serialNo string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		p.t.Fatalf("GenerateKey failed: %v", err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "Tenpay.com Root CA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		p.t.Fatalf("CreateCertificate failed: %v", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.serialNo = serialNo
+	p.key = key
+	p.certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// merchantKeyPEM 商户私钥 PEM
+func (p *mockPayPlatform) merchantKeyPEM() string {
+	der, _ := x509.MarshalPKCS8PrivateKey(p.merchantKey)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+var authorizationRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// verifyMerchantSignature 校验请求的 Authorization 签名
+func (p *mockPayPlatform) verifyMerchantSignature(r *http.Request, body []byte) error {
+	fields := map[string]string{}
+	for _, m := range authorizationRegexp.FindAllStringSubmatch(r.Header.Get("Authorization"), -1) {
+		fields[m[1]] = m[2]
+	}
+	if fields["mchid"] != "test_mchid" || fields["serial_no"] != "MERCHANT_SERIAL" {
+		return errors.New("invalid authorization")
+	}
+	message := r.Method + "\n" + r.URL.RequestURI() + "\n" + fields["timestamp"] + "\n" + fields["nonce_str"] + "\n" + string(body) + "\n"
+
+	return verifyRSA(&p.merchantKey.PublicKey, message, fields["signature"])
+}
+
+// signHeaders 生成平台签名的应答头或通知头
+func (p *mockPayPlatform) signHeaders(body []byte) http.Header {
+	p.mu.Lock()
+	serialNo, key := p.serialNo, p.key
+	p.mu.Unlock()
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := "mock_nonce"
+	hashed := sha256.Sum256([]byte(timestamp + "\n" + nonce + "\n" + string(body) + "\n"))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+
+	header := http.Header{}
+	header.Set("Wechatpay-Serial", serialNo)
+	header.Set("Wechatpay-Timestamp", timestamp)
+	header.Set("Wechatpay-Nonce", nonce)
+	header.Set("Wechatpay-Signature", base64.StdEncoding.EncodeToString(signature))
+
+	return header
+}
+
+// writeSigned 写入带平台签名的应答
+func (p *mockPayPlatform) writeSigned(w http.ResponseWriter, status int, body []byte) {
+	for k, v := range p.signHeaders(body) {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// encryptResource 使用 APIv3 密钥加密数据
+func encryptResource(plaintext []byte, associatedData string) map[string]any {
+	nonce := "mock_nonce12"
+	block, _ := aes.NewCipher([]byte(testPayAPIv3Key))
+	aead, _ := cipher.NewGCM(block)
+	ciphertext := aead.Seal(nil, []byte(nonce), plaintext, []byte(associatedData))
+
+	return map[string]any{
+		"algorithm":       "AEAD_AES_256_GCM",
+		"nonce":           nonce,
+		"associated_data": associatedData,
+		"ciphertext":      base64.StdEncoding.EncodeToString(ciphertext),
+	}
+}
+
+// handle 注册需要校验签名的接口
+func (p *mockPayPlatform) handle(mux *http.ServeMux, pattern string, fn func(r *http.Request, body []byte) (int, any)) {
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := p.verifyMerchantSignature(r, body); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"code":"SIGN_ERROR","message":"签名错误"}`))
+			return
+		}
+		status, resp := fn(r, body)
+		var data []byte
+		if resp != nil {
+			data, _ = json.Marshal(resp)
+		}
+		p.writeSigned(w, status, data)
+	})
+}
+
+// newMockPayClient 创建连接到模拟平台的支付客户端
+func newMockPayClient(t *testing.T, p *mockPayPlatform, mux *http.ServeMux) *wechat.PayClient {
+	t.Helper()
+
+	p.handle(mux, "/v3/certificates", func(r *http.Request, body []byte) (int, any) {
+		p.mu.Lock()
+		serialNo, certPEM := p.serialNo, p.certPEM
+		p.certDownloads++
+		p.mu.Unlock()
+
+		return http.StatusOK, map[string]any{"data": []map[string]any{{
+			"serial_no":           serialNo,
+			"effective_time":      time.Now().Add(-time.Hour).Format(time.RFC3339),
+			"expire_time":         time.Now().Add(24 * time.Hour).Format(time.RFC3339),
+			"encrypt_certificate": encryptResource(certPEM, "certificate"),
+		}}}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	payClient, err := wechat.NewPayClient(&wechat.PayConfig{
+		AppId:      "wx_test_appid",
+		MchId:      "test_mchid",
+		SerialNo:   "MERCHANT_SERIAL",
+		PrivateKey: p.merchantKeyPEM(),
+		APIv3Key:   testPayAPIv3Key,
+		NotifyURL:  "https://example.com/pay/notify",
+		BaseURL:    server.URL,
+	})
+	if err != nil {
+		t.Fatalf("NewPayClient failed: %v", err)
+	}
+
+	return payClient
+}
+
+// verifyRSA 校验 SHA256-RSA 签名
+func verifyRSA(pub *rsa.PublicKey, message, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+	hashed := sha256.Sum256([]byte(message))
+	return rsa.VerifyPKCS1v15(pub, crypto.SHA256, hashed[:], sig)
+}
+
+// TestPayClient_JSAPIPayment 测试下单并生成调起支付参数
+func TestPayClient_JSAPIPayment(t *testing.T) {
+	platform := newMockPayPlatform(t)
+	mux := http.NewServeMux()
+	platform.handle(mux, "/v3/pay/transactions/jsapi", func(r *http.Request, body []byte) (int, any) {
+		var req map[string]any
+		_ = json.Unmarshal(body, &req)
+		if req["appid"] != "wx_test_appid" || req["mchid"] != "test_mchid" || req["notify_url"] != "https://example.com/pay/notify" {
+			return http.StatusBadRequest, map[string]any{"code": "PARAM_ERROR", "message": "参数错误"}
+		}
+		return http.StatusOK, map[string]any{"prepay_id": "wx_prepay_1"}
+	})
+	payClient := newMockPayClient(t, platform, mux)
+
+	params, err := payClient.CreateJSAPIPayment(context.Background(), &wechat.PayJSAPIRequest{
+		Description: "测试商品",
+		OutTradeNo:  "order_1",
+		Amount:      wechat.PayAmount{Total: 100},
+		Payer:       wechat.PayPayer{OpenID: "test_openid"},
+	})
+	if err != nil {
+		t.Fatalf("CreateJSAPIPayment failed: %v", err)
+	}
+	if params.Package != "prepay_id=wx_prepay_1" || params.SignType != "RSA" {
+		t.Fatalf("unexpected params: %+v", params)
+	}
+	message := "wx_test_appid\n" + params.TimeStamp + "\n" + params.NonceStr + "\n" + params.Package + "\n"
+	if err := verifyRSA(&platform.merchantKey.PublicKey, message, params.PaySign); err != nil {
+		t.Fatalf("invalid paySign: %v", err)
+	}
+}
+
+// TestPayClient_OrderAndRefund 测试查单、关单、退款以及错误应答
+func TestPayClient_OrderAndRefund(t *testing.T) {
+	platform := newMockPayPlatform(t)
+	mux := http.NewServeMux()
+	platform.handle(mux, "/v3/pay/transactions/out-trade-no/order_1", func(r *http.Request, body []byte) (int, any) {
+		if r.URL.Query().Get("mchid") != "test_mchid" {
+			return http.StatusBadRequest, map[string]any{"code": "PARAM_ERROR", "message": "缺少 mchid"}
+		}
+		return http.StatusOK, map[string]any{"out_trade_no": "order_1", "transaction_id": "tx_1", "trade_state": "SUCCESS", "amount": map[string]any{"total": 100, "payer_total": 100}}
+	})
+	platform.handle(mux, "/v3/pay/transactions/out-trade-no/order_2/close", func(r *http.Request, body []byte) (int, any) {
+		return http.StatusNoContent, nil
+	})
+	platform.handle(mux, "/v3/pay/transactions/out-trade-no/order_404", func(r *http.Request, body []byte) (int, any) {
+		return http.StatusNotFound, map[string]any{"code": "ORDER_NOT_EXIST", "message": "订单不存在"}
+	})
+	platform.handle(mux, "/v3/refund/domestic/refunds", func(r *http.Request, body []byte) (int, any) {
+		var req map[string]any
+		_ = json.Unmarshal(body, &req)
+		return http.StatusOK, map[string]any{"refund_id": "refund_1", "out_refund_no": req["out_refund_no"], "status": "PROCESSING"}
+	})
+	payClient := newMockPayClient(t, platform, mux)
+	ctx := context.Background()
+
+	transaction, err := payClient.QueryOrderByOutTradeNo(ctx, "order_1")
+	if err != nil || !transaction.Paid() || transaction.Amount.PayerTotal != 100 {
+		t.Fatalf("QueryOrderByOutTradeNo failed: %+v, %v", transaction, err)
+	}
+	if err := payClient.CloseOrder(ctx, "order_2"); err != nil {
+		t.Fatalf("CloseOrder failed: %v", err)
+	}
+
+	_, err = payClient.QueryOrderByOutTradeNo(ctx, "order_404")
+	var payErr *wechat.PayError
+	if !errors.As(err, &payErr) || payErr.Code != "ORDER_NOT_EXIST" || payErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected PayError, got %v", err)
+	}
+
+	refund, err := payClient.Refund(ctx, &wechat.PayRefundRequest{
+		OutTradeNo:  "order_1",
+		OutRefundNo: "refund_order_1",
+		Amount:      wechat.PayRefundAmount{Refund: 50, Total: 100},
+	})
+	if err != nil || refund.RefundID != "refund_1" || refund.Status != wechat.PayRefundStatusProcessing {
+		t.Fatalf("Refund failed: %+v, %v", refund, err)
+	}
+}
+
+// TestPayClient_CertificateRotation 测试平台证书更换后自动重新下载
+func TestPayClient_CertificateRotation(t *testing.T) {
+	platform := newMockPayPlatform(t)
+	mux := http.NewServeMux()
+	platform.handle(mux, "/v3/pay/transactions/id/tx_1", func(r *http.Request, body []byte) (int, any) {
+		return http.StatusOK, map[string]any{"transaction_id": "tx_1", "trade_state": "NOTPAY"}
+	})
+	payClient := newMockPayClient(t, platform, mux)
+	ctx := context.Background()
+
+	certs, err := payClient.DownloadCertificates(ctx)
+	if err != nil || len(certs) != 1 || certs[0].SerialNo != "PLATFORM_SERIAL_1" {
+		t.Fatalf("DownloadCertificates failed: %+v, %v", certs, err)
+	}
+
+	platform.rotate("PLATFORM_SERIAL_2")
+	if _, err := payClient.QueryOrderByTransactionID(ctx, "tx_1"); err != nil {
+		t.Fatalf("QueryOrderByTransactionID after rotation failed: %v", err)
+	}
+	if certs := payClient.GetCertificates(); len(certs) != 1 || certs[0].SerialNo != "PLATFORM_SERIAL_2" {
+		t.Fatalf("unexpected certificates after rotation: %+v", certs)
+	}
+}
+
+// TestPayNotifyHandler 测试支付通知验签、解密和重复推送去重
+func TestPayNotifyHandler(t *testing.T) {
+	platform := newMockPayPlatform(t)
+	payClient := newMockPayClient(t, platform, http.NewServeMux())
+
+	var handled []*wechat.PayTransaction
+	handler := payClient.CreateNotifyHandler(&wechat.PayNotifyHandlers{
+		OnTransaction: func(ctx context.Context, notification *wechat.PayNotification, transaction *wechat.PayTransaction) error {
+			handled = append(handled, transaction)
+			return nil
+		},
+	})
+
+	transaction, _ := json.Marshal(map[string]any{"out_trade_no": "order_1", "transaction_id": "tx_1", "trade_state": "SUCCESS", "amount": map[string]any{"total": 100}})
+	body, _ := json.Marshal(map[string]any{
+		"id":            "EV-2018022511223320873",
+		"create_time":   time.Now().Format(time.RFC3339),
+		"resource_type": "encrypt-resource",
+		"event_type":    wechat.PayEventTransactionSuccess,
+		"summary":       "支付成功",
+		"resource":      encryptResource(transaction, "transaction"),
+	})
+	notify := func(header http.Header) int {
+		req := httptest.NewRequest(http.MethodPost, "/pay/notify", bytes.NewReader(body))
+		req.Header = header
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	header := platform.signHeaders(body)
+	if code := notify(header); code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	if code := notify(header); code != http.StatusOK {
+		t.Fatalf("unexpected status on redelivery: %d", code)
+	}
+	if len(handled) != 1 || handled[0].OutTradeNo != "order_1" || !handled[0].Paid() {
+		t.Fatalf("unexpected handled transactions: %+v", handled)
+	}
+
+	header.Set("Wechatpay-Signature", base64.StdEncoding.EncodeToString([]byte("bad")))
+	if code := notify(header); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad signature, got %d", code)
+	}
+
+	// 伪造的证书序列号只触发一次证书下载，之后直接拒绝
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			forged := platform.signHeaders(body)
+			forged.Set("Wechatpay-Serial", "FORGED_SERIAL")
+			if _, err := payClient.ParseNotification(context.Background(), forged, body); !errors.Is(err, wechat.ErrPlatformCertificateNotFound) {
+				t.Errorf("expected ErrPlatformCertificateNotFound, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	platform.mu.Lock()
+	downloads := platform.certDownloads
+	platform.mu.Unlock()
+	if downloads != 1 {
+		t.Fatalf("expected 1 certificate download, got %d", downloads)
+	}
+}
+
+// TestPayNotifyHandler_ConcurrentRedelivery 测试并发重复推送的通知只处理一次，处理失败后可重试
+func TestPayNotifyHandler_ConcurrentRedelivery(t *testing.T) {
+	platform := newMockPayPlatform(t)
+	payClient := newMockPayClient(t, platform, http.NewServeMux())
+
+	var (
+		calls   atomic.Int32
+		failing atomic.Bool
+	)
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	handler := payClient.CreateNotifyHandler(&wechat.PayNotifyHandlers{
+		OnTransaction: func(ctx context.Context, notification *wechat.PayNotification, transaction *wechat.PayTransaction) error {
+			calls.Add(1)
+			if failing.Load() {
+				return errors.New("处理失败")
+			}
+			started <- struct{}{}
+			<-release
+			return nil
+		},
+	})
+
+	notify := func(id string) int {
+		transaction, _ := json.Marshal(map[string]any{"out_trade_no": "order_" + id, "transaction_id": "tx_" + id, "trade_state": "SUCCESS"})
+		body, _ := json.Marshal(map[string]any{
+			"id":            id,
+			"create_time":   time.Now().Format(time.RFC3339),
+			"resource_type": "encrypt-resource",
+			"event_type":    wechat.PayEventTransactionSuccess,
+			"resource":      encryptResource(transaction, "transaction"),
+		})
+		req := httptest.NewRequest(http.MethodPost, "/pay/notify", bytes.NewReader(body))
+		req.Header = platform.signHeaders(body)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// 第一次推送处理过程中并发重复推送，均应答失败等待重试
+	first := make(chan int)
+	go func() { first <- notify("EV-1") }()
+	<-started
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if code := notify("EV-1"); code != http.StatusInternalServerError {
+				t.Errorf("expected 500 while processing, got %d", code)
+			}
+		}()
+	}
+	wg.Wait()
+	close(release)
+	if code := <-first; code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	if code := notify("EV-1"); code != http.StatusOK {
+		t.Fatalf("unexpected status on redelivery: %d", code)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected notification handled once, got %d", n)
+	}
+
+	// 处理失败时释放占用，重试时再次处理
+	failing.Store(true)
+	if code := notify("EV-2"); code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 when handler fails, got %d", code)
+	}
+	failing.Store(false)
+	go func() { <-started }()
+	if code := notify("EV-2"); code != http.StatusOK {
+		t.Fatalf("unexpected status on retry: %d", code)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("expected failed notification to be retried, calls=%d", n)
+	}
+}
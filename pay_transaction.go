@@ -0,0 +1,307 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/silenceper/wechat/v2/util"
+)
+
+const (
+	payJSAPIPrepayPath         = "/v3/pay/transactions/jsapi"
+	payQueryByOutTradeNoPath   = "/v3/pay/transactions/out-trade-no/%s?mchid=%s"
+	payQueryByTransactionPath  = "/v3/pay/transactions/id/%s?mchid=%s"
+	payCloseOrderPath          = "/v3/pay/transactions/out-trade-no/%s/close"
+	payRefundPath              = "/v3/refund/domestic/refunds"
+	payQueryRefundPath         = "/v3/refund/domestic/refunds/%s"
+	payRequestPaymentSignType  = "RSA"
+	payRequestPaymentPackageID = "prepay_id="
+)
+
+// 交易状态
+const (
+	PayTradeStateSuccess    = "SUCCESS"    // 支付成功
+	PayTradeStateRefund     = "REFUND"     // 转入退款
+	PayTradeStateNotPay     = "NOTPAY"     // 未支付
+	PayTradeStateClosed     = "CLOSED"     // 已关闭
+	PayTradeStateRevoked    = "REVOKED"    // 已撤销（仅付款码支付）
+	PayTradeStateUserPaying = "USERPAYING" // 用户支付中（仅付款码支付）
+	PayTradeStatePayError   = "PAYERROR"   // 支付失败
+)
+
+// 退款状态
+const (
+	PayRefundStatusSuccess    = "SUCCESS"    // 退款成功
+	PayRefundStatusClosed     = "CLOSED"     // 退款关闭
+	PayRefundStatusProcessing = "PROCESSING" // 退款处理中
+	PayRefundStatusAbnormal   = "ABNORMAL"   // 退款异常
+)
+
+// PayAmount 订单金额
+type PayAmount struct {
+	Total    int64  `json:"total"`              // 订单总金额，单位为分
+	Currency string `json:"currency,omitempty"` // 货币类型，默认 CNY
+}
+
+// PayPayer 支付者
+type PayPayer struct {
+	OpenID string `json:"openid"` // 用户在 appid 下的 openid
+}
+
+// PayJSAPIRequest JSAPI/小程序下单请求
+type PayJSAPIRequest struct {
+	AppID         string    `json:"-"`                        // appid，为空时使用配置中的 AppId
+	Description   string    `json:"description"`              // 商品描述
+	OutTradeNo    string    `json:"out_trade_no"`             // 商户订单号
+	TimeExpire    time.Time `json:"-"`                        // 交易结束时间，可选
+	Attach        string    `json:"attach,omitempty"`         // 附加数据，在查询和支付通知中原样返回
+	NotifyURL     string    `json:"-"`                        // 支付结果通知地址，为空时使用配置中的 NotifyURL
+	GoodsTag      string    `json:"goods_tag,omitempty"`      // 订单优惠标记
+	Amount        PayAmount `json:"amount"`                   // 订单金额
+	Payer         PayPayer  `json:"payer"`                    // 支付者
+	SupportFapiao bool      `json:"support_fapiao,omitempty"` // 电子发票入口开放标识
+}
+
+// RequestPaymentParams 小程序 wx.requestPayment 的参数
+type RequestPaymentParams struct {
+	TimeStamp string `json:"timeStamp"`
+	NonceStr  string `json:"nonceStr"`
+	Package   string `json:"package"`
+	SignType  string `json:"signType"`
+	PaySign   string `json:"paySign"`
+}
+
+// PayTransaction 支付订单
+type PayTransaction struct {
+	AppID          string `json:"appid"`
+	MchID          string `json:"mchid"`
+	OutTradeNo     string `json:"out_trade_no"`     // 商户订单号
+	TransactionID  string `json:"transaction_id"`   // 微信支付订单号
+	TradeType      string `json:"trade_type"`       // 交易类型
+	TradeState     string `json:"trade_state"`      // 交易状态
+	TradeStateDesc string `json:"trade_state_desc"` // 交易状态描述
+	BankType       string `json:"bank_type"`        // 付款银行
+	Attach         string `json:"attach"`           // 附加数据
+	SuccessTime    string `json:"success_time"`     // 支付完成时间
+	Payer          struct {
+		OpenID string `json:"openid"`
+	} `json:"payer"`
+	Amount struct {
+		Total         int64  `json:"total"`          // 订单总金额，单位为分
+		PayerTotal    int64  `json:"payer_total"`    // 用户支付金额，单位为分
+		Currency      string `json:"currency"`       // 货币类型
+		PayerCurrency string `json:"payer_currency"` // 用户支付币种
+	} `json:"amount"`
+}
+
+// Paid 是否支付成功
+func (t *PayTransaction) Paid() bool {
+	return t.TradeState == PayTradeStateSuccess
+}
+
+// JSAPIPrepay JSAPI/小程序下单，返回 prepay_id
+func (c *PayClient) JSAPIPrepay(ctx context.Context, req *PayJSAPIRequest) (string, error) {
+	if req.OutTradeNo == "" || req.Description == "" {
+		return "", errors.New("out_trade_no 和 description 不能为空")
+	}
+	if req.Amount.Total <= 0 {
+		return "", errors.New("订单金额必须大于 0")
+	}
+	if req.Payer.OpenID == "" {
+		return "", errors.New("payer.openid 不能为空")
+	}
+
+	appID := c.appID(req.AppID)
+	if appID == "" {
+		return "", errors.New("appid 不能为空")
+	}
+
+	notifyURL := req.NotifyURL
+	if notifyURL == "" {
+		notifyURL = c.config.NotifyURL
+	}
+	if notifyURL == "" {
+		return "", errors.New("notify_url 不能为空")
+	}
+
+	body := struct {
+		AppID      string `json:"appid"`
+		MchID      string `json:"mchid"`
+		NotifyURL  string `json:"notify_url"`
+		TimeExpire string `json:"time_expire,omitempty"`
+		*PayJSAPIRequest
+	}{
+		AppID:           appID,
+		MchID:           c.config.MchId,
+		NotifyURL:       notifyURL,
+		PayJSAPIRequest: req,
+	}
+	if !req.TimeExpire.IsZero() {
+		body.TimeExpire = req.TimeExpire.Format(time.RFC3339)
+	}
+
+	var resp struct {
+		PrepayID string `json:"prepay_id"`
+	}
+	if err := c.call(ctx, http.MethodPost, payJSAPIPrepayPath, body, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.PrepayID, nil
+}
+
+// BuildRequestPaymentParams 根据 prepay_id 生成小程序调起支付的参数
+// appID 需与下单时使用的 appid 一致，为空时使用配置中的 AppId
+func (c *PayClient) BuildRequestPaymentParams(appID, prepayID string) (*RequestPaymentParams, error) {
+	params := &RequestPaymentParams{
+		TimeStamp: strconv.FormatInt(time.Now().Unix(), 10),
+		NonceStr:  util.RandomStr(32),
+		Package:   payRequestPaymentPackageID + prepayID,
+		SignType:  payRequestPaymentSignType,
+	}
+
+	paySign, err := c.sign(c.appID(appID) + "\n" + params.TimeStamp + "\n" + params.NonceStr + "\n" + params.Package + "\n")
+	if err != nil {
+		return nil, err
+	}
+	params.PaySign = paySign
+
+	return params, nil
+}
+
+// CreateJSAPIPayment 下单并生成小程序调起支付的参数
+func (c *PayClient) CreateJSAPIPayment(ctx context.Context, req *PayJSAPIRequest) (*RequestPaymentParams, error) {
+	prepayID, err := c.JSAPIPrepay(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.BuildRequestPaymentParams(req.AppID, prepayID)
+}
+
+// QueryOrderByOutTradeNo 根据商户订单号查询订单
+func (c *PayClient) QueryOrderByOutTradeNo(ctx context.Context, outTradeNo string) (*PayTransaction, error) {
+	if outTradeNo == "" {
+		return nil, errors.New("out_trade_no 不能为空")
+	}
+
+	var transaction PayTransaction
+	path := fmt.Sprintf(payQueryByOutTradeNoPath, url.PathEscape(outTradeNo), url.QueryEscape(c.config.MchId))
+	if err := c.call(ctx, http.MethodGet, path, nil, &transaction); err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+// QueryOrderByTransactionID 根据微信支付订单号查询订单
+func (c *PayClient) QueryOrderByTransactionID(ctx context.Context, transactionID string) (*PayTransaction, error) {
+	if transactionID == "" {
+		return nil, errors.New("transaction_id 不能为空")
+	}
+
+	var transaction PayTransaction
+	path := fmt.Sprintf(payQueryByTransactionPath, url.PathEscape(transactionID), url.QueryEscape(c.config.MchId))
+	if err := c.call(ctx, http.MethodGet, path, nil, &transaction); err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+// CloseOrder 关闭未支付的订单
+func (c *PayClient) CloseOrder(ctx context.Context, outTradeNo string) error {
+	if outTradeNo == "" {
+		return errors.New("out_trade_no 不能为空")
+	}
+
+	path := fmt.Sprintf(payCloseOrderPath, url.PathEscape(outTradeNo))
+	return c.call(ctx, http.MethodPost, path, map[string]string{"mchid": c.config.MchId}, nil)
+}
+
+// PayRefundRequest 退款请求，transaction_id 和 out_trade_no 二选一
+type PayRefundRequest struct {
+	TransactionID string          `json:"transaction_id,omitempty"` // 微信支付订单号
+	OutTradeNo    string          `json:"out_trade_no,omitempty"`   // 商户订单号
+	OutRefundNo   string          `json:"out_refund_no"`            // 商户退款单号
+	Reason        string          `json:"reason,omitempty"`         // 退款原因
+	NotifyURL     string          `json:"notify_url,omitempty"`     // 退款结果通知地址
+	Amount        PayRefundAmount `json:"amount"`                   // 退款金额
+}
+
+// PayRefundAmount 退款金额
+type PayRefundAmount struct {
+	Refund   int64  `json:"refund"`             // 退款金额，单位为分
+	Total    int64  `json:"total"`              // 原订单金额，单位为分
+	Currency string `json:"currency,omitempty"` // 货币类型，默认 CNY
+}
+
+// PayRefund 退款单
+type PayRefund struct {
+	RefundID            string `json:"refund_id"`             // 微信支付退款单号
+	OutRefundNo         string `json:"out_refund_no"`         // 商户退款单号
+	TransactionID       string `json:"transaction_id"`        // 微信支付订单号
+	OutTradeNo          string `json:"out_trade_no"`          // 商户订单号
+	Channel             string `json:"channel"`               // 退款渠道
+	UserReceivedAccount string `json:"user_received_account"` // 退款入账账户
+	SuccessTime         string `json:"success_time"`          // 退款成功时间
+	CreateTime          string `json:"create_time"`           // 退款创建时间
+	Status              string `json:"status"`                // 退款状态
+	Amount              struct {
+		Total       int64  `json:"total"`        // 订单金额
+		Refund      int64  `json:"refund"`       // 退款金额
+		PayerTotal  int64  `json:"payer_total"`  // 用户支付金额
+		PayerRefund int64  `json:"payer_refund"` // 用户退款金额
+		Currency    string `json:"currency"`     // 货币类型
+	} `json:"amount"`
+}
+
+// Refund 申请退款
+func (c *PayClient) Refund(ctx context.Context, req *PayRefundRequest) (*PayRefund, error) {
+	if req.TransactionID == "" && req.OutTradeNo == "" {
+		return nil, errors.New("transaction_id 和 out_trade_no 不能同时为空")
+	}
+	if req.OutRefundNo == "" {
+		return nil, errors.New("out_refund_no 不能为空")
+	}
+	if req.Amount.Refund <= 0 || req.Amount.Refund > req.Amount.Total {
+		return nil, errors.New("退款金额必须大于 0 且不超过订单金额")
+	}
+	if req.Amount.Currency == "" {
+		req.Amount.Currency = "CNY"
+	}
+
+	var refund PayRefund
+	if err := c.call(ctx, http.MethodPost, payRefundPath, req, &refund); err != nil {
+		return nil, err
+	}
+
+	return &refund, nil
+}
+
+// QueryRefund 根据商户退款单号查询退款
+func (c *PayClient) QueryRefund(ctx context.Context, outRefundNo string) (*PayRefund, error) {
+	if outRefundNo == "" {
+		return nil, errors.New("out_refund_no 不能为空")
+	}
+
+	var refund PayRefund
+	if err := c.call(ctx, http.MethodGet, fmt.Sprintf(payQueryRefundPath, url.PathEscape(outRefundNo)), nil, &refund); err != nil {
+		return nil, err
+	}
+
+	return &refund, nil
+}
+
+// appID 返回请求使用的 appid，为空时使用配置中的 AppId
+func (c *PayClient) appID(appID string) string {
+	if appID == "" {
+		return c.config.AppId
+	}
+	return appID
+}
@@ -0,0 +1,368 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/silenceper/wechat/v2/cache"
+	"github.com/xen0n/go-workwx/v2"
+)
+
+// ErrAppNotFound 未找到应用配置
+var ErrAppNotFound = errors.New("应用配置不存在")
+
+// ConfigSource 应用配置来源，注册中心按需从中加载配置创建客户端
+// 找不到配置时返回 ErrAppNotFound
+type ConfigSource interface {
+	// MiniProgramConfig 获取小程序配置
+	MiniProgramConfig(ctx context.Context, appID string) (*MiniProgramConfig, error)
+	// OfficialAccountConfig 获取公众号配置
+	OfficialAccountConfig(ctx context.Context, appID string) (*OfficialAccountConfig, error)
+	// WorkwxConfig 获取企业微信应用配置
+	WorkwxConfig(ctx context.Context, corpID string, agentID int64) (*WorkwxConfig, error)
+}
+
+// StaticConfigSource 基于固定配置列表的配置来源
+type StaticConfigSource struct {
+	MiniPrograms     []*MiniProgramConfig     `json:"miniPrograms" yaml:"miniPrograms" mapstructure:"miniPrograms"`             // 小程序配置列表
+	OfficialAccounts []*OfficialAccountConfig `json:"officialAccounts" yaml:"officialAccounts" mapstructure:"officialAccounts"` // 公众号配置列表
+	Workwx           []*WorkwxConfig          `json:"workwx" yaml:"workwx" mapstructure:"workwx"`                               // 企业微信应用配置列表
+}
+
+var _ ConfigSource = (*StaticConfigSource)(nil)
+
+// MiniProgramConfig 获取小程序配置
+func (s *StaticConfigSource) MiniProgramConfig(_ context.Context, appID string) (*MiniProgramConfig, error) {
+	for _, cfg := range s.MiniPrograms {
+		if cfg.AppId == appID {
+			return cfg, nil
+		}
+	}
+	return nil, ErrAppNotFound
+}
+
+// OfficialAccountConfig 获取公众号配置
+func (s *StaticConfigSource) OfficialAccountConfig(_ context.Context, appID string) (*OfficialAccountConfig, error) {
+	for _, cfg := range s.OfficialAccounts {
+		if cfg.AppId == appID {
+			return cfg, nil
+		}
+	}
+	return nil, ErrAppNotFound
+}
+
+// WorkwxConfig 获取企业微信应用配置
+func (s *StaticConfigSource) WorkwxConfig(_ context.Context, corpID string, agentID int64) (*WorkwxConfig, error) {
+	for _, cfg := range s.Workwx {
+		if cfg.CorpID == corpID && cfg.AgentID == agentID {
+			return cfg, nil
+		}
+	}
+	return nil, ErrAppNotFound
+}
+
+// workwxAgentKey 企业微信应用标识
+type workwxAgentKey struct {
+	corpID  string
+	agentID int64
+}
+
+// Registry 多应用客户端注册中心
+// 客户端默认共用注册中心的缓存，应用单独配置了 Redis 时使用该配置，相同配置的应用共用连接池
+// 客户端在首次使用时从配置来源创建
+type Registry struct {
+	cache  cache.Cache
+	source ConfigSource
+
+	mu               sync.RWMutex
+	miniPrograms     map[string]*MiniProgramClient
+	officialAccounts map[string]*OfficialAccountClient
+	workwxClients    map[workwxAgentKey]*WorkwxClient
+}
+
+// NewRegistry 创建多应用客户端注册中心
+// c 为空时使用内存缓存；source 为空时只能使用通过 Add 方法注册的应用
+func NewRegistry(c cache.Cache, source ConfigSource) *Registry {
+	if c == nil {
+		c = cache.NewMemory()
+	}
+
+	return &Registry{
+		cache:            c,
+		source:           source,
+		miniPrograms:     make(map[string]*MiniProgramClient),
+		officialAccounts: make(map[string]*OfficialAccountClient),
+		workwxClients:    make(map[workwxAgentKey]*WorkwxClient),
+	}
+}
+
+// GetCache 获取共用的缓存
+func (r *Registry) GetCache() cache.Cache {
+	return r.cache
+}
+
+// cacheFor 返回应用使用的缓存，应用未单独配置 Redis 时使用共用的缓存
+func (r *Registry) cacheFor(redisCfg *RedisConfig, redisAddr string) cache.Cache {
+	if redisCfg == nil && redisAddr == "" {
+		return r.cache
+	}
+	return newCache(redisCfg, redisAddr)
+}
+
+// ==================== 小程序 ====================
+
+// MiniProgram 获取小程序客户端，未创建时从配置来源加载
+func (r *Registry) MiniProgram(ctx context.Context, appID string) (*MiniProgramClient, error) {
+	r.mu.RLock()
+	client, ok := r.miniPrograms[appID]
+	r.mu.RUnlock()
+	if ok {
+		return client, nil
+	}
+
+	if r.source == nil {
+		return nil, ErrAppNotFound
+	}
+	cfg, err := r.source.MiniProgramConfig(ctx, appID)
+	if err != nil {
+		return nil, fmt.Errorf("加载小程序 %s 配置失败: %w", appID, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if client, ok := r.miniPrograms[appID]; ok {
+		return client, nil
+	}
+	client = NewMiniProgramClientWithCache(cfg, r.cacheFor(cfg.Redis, cfg.RedisAddr))
+	r.miniPrograms[appID] = client
+
+	return client, nil
+}
+
+// AddMiniProgram 注册小程序，已存在时替换为新配置创建的客户端
+func (r *Registry) AddMiniProgram(cfg *MiniProgramConfig) *MiniProgramClient {
+	client := NewMiniProgramClientWithCache(cfg, r.cacheFor(cfg.Redis, cfg.RedisAddr))
+
+	r.mu.Lock()
+	r.miniPrograms[cfg.AppId] = client
+	r.mu.Unlock()
+
+	return client
+}
+
+// RemoveMiniProgram 移除小程序客户端，下次获取时重新从配置来源加载
+func (r *Registry) RemoveMiniProgram(appID string) {
+	r.mu.Lock()
+	delete(r.miniPrograms, appID)
+	r.mu.Unlock()
+}
+
+// MiniProgramRouter 创建按 appid 路由的小程序消息推送处理器
+// appIDFunc 从请求中获取 appid，如 func(r *http.Request) string { return r.PathValue("appid") }
+// handlersFunc 为每个小程序提供消息处理函数，每个客户端只调用一次，客户端被替换后重新调用
+func (r *Registry) MiniProgramRouter(appIDFunc func(*http.Request) string, handlersFunc func(client *MiniProgramClient) *MiniProgramMessageHandlers) http.Handler {
+	var handlers routeHandlers[string, *MiniProgramClient]
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		appID := appIDFunc(req)
+		client, err := r.MiniProgram(req.Context(), appID)
+		if err != nil {
+			writeRouteError(rw, err)
+			return
+		}
+		handler, err := handlers.get(appID, client, func() (http.Handler, error) {
+			return client.CreateHTTPHandler(handlersFunc(client))
+		})
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+// ==================== 公众号 ====================
+
+// OfficialAccount 获取公众号客户端，未创建时从配置来源加载
+func (r *Registry) OfficialAccount(ctx context.Context, appID string) (*OfficialAccountClient, error) {
+	r.mu.RLock()
+	client, ok := r.officialAccounts[appID]
+	r.mu.RUnlock()
+	if ok {
+		return client, nil
+	}
+
+	if r.source == nil {
+		return nil, ErrAppNotFound
+	}
+	cfg, err := r.source.OfficialAccountConfig(ctx, appID)
+	if err != nil {
+		return nil, fmt.Errorf("加载公众号 %s 配置失败: %w", appID, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if client, ok := r.officialAccounts[appID]; ok {
+		return client, nil
+	}
+	client = NewOfficialAccountClientWithCache(cfg, r.cacheFor(cfg.Redis, cfg.RedisAddr))
+	r.officialAccounts[appID] = client
+
+	return client, nil
+}
+
+// AddOfficialAccount 注册公众号，已存在时替换为新配置创建的客户端
+func (r *Registry) AddOfficialAccount(cfg *OfficialAccountConfig) *OfficialAccountClient {
+	client := NewOfficialAccountClientWithCache(cfg, r.cacheFor(cfg.Redis, cfg.RedisAddr))
+
+	r.mu.Lock()
+	r.officialAccounts[cfg.AppId] = client
+	r.mu.Unlock()
+
+	return client
+}
+
+// RemoveOfficialAccount 移除公众号客户端，下次获取时重新从配置来源加载
+func (r *Registry) RemoveOfficialAccount(appID string) {
+	r.mu.Lock()
+	delete(r.officialAccounts, appID)
+	r.mu.Unlock()
+}
+
+// OfficialAccountRouter 创建按 appid 路由的公众号消息处理器
+// handlerFunc 每个客户端只调用一次，客户端被替换后重新调用
+func (r *Registry) OfficialAccountRouter(appIDFunc func(*http.Request) string, handlerFunc func(client *OfficialAccountClient) OfficialAccountMessageHandler) http.Handler {
+	var handlers routeHandlers[string, *OfficialAccountClient]
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		appID := appIDFunc(req)
+		client, err := r.OfficialAccount(req.Context(), appID)
+		if err != nil {
+			writeRouteError(rw, err)
+			return
+		}
+		handler, err := handlers.get(appID, client, func() (http.Handler, error) {
+			return client.CreateHTTPHandler(handlerFunc(client))
+		})
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+// ==================== 企业微信 ====================
+
+// Workwx 获取企业微信应用客户端，未创建时从配置来源加载
+func (r *Registry) Workwx(ctx context.Context, corpID string, agentID int64) (*WorkwxClient, error) {
+	key := workwxAgentKey{corpID: corpID, agentID: agentID}
+
+	r.mu.RLock()
+	client, ok := r.workwxClients[key]
+	r.mu.RUnlock()
+	if ok {
+		return client, nil
+	}
+
+	if r.source == nil {
+		return nil, ErrAppNotFound
+	}
+	cfg, err := r.source.WorkwxConfig(ctx, corpID, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("加载企业微信应用 %s/%d 配置失败: %w", corpID, agentID, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if client, ok := r.workwxClients[key]; ok {
+		return client, nil
+	}
+	client = NewWorkwxClientWithCache(cfg, r.cacheFor(cfg.Redis, cfg.RedisAddr))
+	r.workwxClients[key] = client
+
+	return client, nil
+}
+
+// AddWorkwx 注册企业微信应用，已存在时替换为新配置创建的客户端
+func (r *Registry) AddWorkwx(cfg *WorkwxConfig) *WorkwxClient {
+	client := NewWorkwxClientWithCache(cfg, r.cacheFor(cfg.Redis, cfg.RedisAddr))
+
+	r.mu.Lock()
+	r.workwxClients[workwxAgentKey{corpID: cfg.CorpID, agentID: cfg.AgentID}] = client
+	r.mu.Unlock()
+
+	return client
+}
+
+// RemoveWorkwx 移除企业微信应用客户端，下次获取时重新从配置来源加载
+func (r *Registry) RemoveWorkwx(corpID string, agentID int64) {
+	r.mu.Lock()
+	delete(r.workwxClients, workwxAgentKey{corpID: corpID, agentID: agentID})
+	r.mu.Unlock()
+}
+
+// WorkwxRouter 创建按 corpid 和 agentid 路由的企业微信回调处理器
+// agentFunc 从请求中获取 corpid 和 agentid，通常取自回调 URL 的路径
+// handlerFunc 每个客户端只调用一次，客户端被替换后重新调用
+func (r *Registry) WorkwxRouter(agentFunc func(*http.Request) (string, int64), handlerFunc func(client *WorkwxClient) workwx.RxMessageHandler) http.Handler {
+	var handlers routeHandlers[workwxAgentKey, *WorkwxClient]
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		corpID, agentID := agentFunc(req)
+		client, err := r.Workwx(req.Context(), corpID, agentID)
+		if err != nil {
+			writeRouteError(rw, err)
+			return
+		}
+		handler, err := handlers.get(workwxAgentKey{corpID: corpID, agentID: agentID}, client, func() (http.Handler, error) {
+			return client.CreateHTTPHandler(handlerFunc(client))
+		})
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+// routeHandler 路由缓存的消息处理器及创建它的客户端
+type routeHandler[C comparable] struct {
+	client  C
+	handler http.Handler
+}
+
+// routeHandlers 按应用缓存路由的消息处理器，应用的客户端被替换后重新创建
+type routeHandlers[K comparable, C comparable] struct {
+	mu       sync.Mutex
+	handlers map[K]routeHandler[C]
+}
+
+// get 获取客户端对应的消息处理器，不存在或客户端已被替换时调用 create 创建
+func (h *routeHandlers[K, C]) get(key K, client C, create func() (http.Handler, error)) (http.Handler, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if cached, ok := h.handlers[key]; ok && cached.client == client {
+		return cached.handler, nil
+	}
+	handler, err := create()
+	if err != nil {
+		return nil, err
+	}
+	if h.handlers == nil {
+		h.handlers = make(map[K]routeHandler[C])
+	}
+	h.handlers[key] = routeHandler[C]{client: client, handler: handler}
+
+	return handler, nil
+}
+
+// writeRouteError 应答路由失败，未知应用返回 404
+func writeRouteError(rw http.ResponseWriter, err error) {
+	if errors.Is(err, ErrAppNotFound) {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	rw.WriteHeader(http.StatusInternalServerError)
+}
@@ -0,0 +1,112 @@
+package wechat_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/darwinOrg/go-wechat"
+	"github.com/silenceper/wechat/v2/cache"
+	"github.com/silenceper/wechat/v2/util"
+)
+
+// TestRegistry 测试按需创建、热更新和按 appid 路由消息推送
+func TestRegistry(t *testing.T) {
+	source := &wechat.StaticConfigSource{
+		MiniPrograms: []*wechat.MiniProgramConfig{
+			{AppId: "wx_mp_1", AppSecret: "secret_1", Token: testPushToken},
+			{AppId: "wx_mp_2", AppSecret: "secret_2", Token: "other_token"},
+		},
+		Workwx: []*wechat.WorkwxConfig{
+			{CorpID: "test_corp_id", AgentID: 1000001, AgentSecret: "test_secret"},
+		},
+	}
+	sharedCache := cache.NewMemory()
+	registry := wechat.NewRegistry(sharedCache, source)
+	ctx := context.Background()
+
+	client, err := registry.MiniProgram(ctx, "wx_mp_1")
+	if err != nil || client.GetConfig().AppId != "wx_mp_1" {
+		t.Fatalf("MiniProgram failed: %v", err)
+	}
+	if again, _ := registry.MiniProgram(ctx, "wx_mp_1"); again != client {
+		t.Fatal("expected client to be reused")
+	}
+	if _, err := registry.MiniProgram(ctx, "wx_unknown"); !errors.Is(err, wechat.ErrAppNotFound) {
+		t.Fatalf("expected ErrAppNotFound, got %v", err)
+	}
+	if _, err := registry.Workwx(ctx, "test_corp_id", 1000001); err != nil {
+		t.Fatalf("Workwx failed: %v", err)
+	}
+
+	// 单独配置 Redis 的应用使用自己的缓存而不是共用缓存
+	separate := registry.AddMiniProgram(&wechat.MiniProgramConfig{AppId: "wx_mp_3", AppSecret: "secret_3", Redis: &wechat.RedisConfig{Addr: "127.0.0.1:1"}})
+	if err := separate.GetSessionKeyStore().Save(ctx, "openid", "session_key"); err == nil {
+		t.Fatal("expected app with its own Redis config not to use the shared cache")
+	}
+	if err := client.GetSessionKeyStore().Save(ctx, "openid", "session_key"); err != nil {
+		t.Fatalf("shared cache Save failed: %v", err)
+	}
+
+	// 热更新：替换配置后使用新客户端，移除后重新从配置来源加载
+	added := registry.AddMiniProgram(&wechat.MiniProgramConfig{AppId: "wx_mp_1", AppSecret: "secret_new", Token: testPushToken})
+	if current, _ := registry.MiniProgram(ctx, "wx_mp_1"); current != added {
+		t.Fatal("expected added client to replace existing one")
+	}
+	registry.RemoveMiniProgram("wx_mp_1")
+	if reloaded, _ := registry.MiniProgram(ctx, "wx_mp_1"); reloaded == added || reloaded.GetConfig().AppSecret != "secret_1" {
+		t.Fatal("expected client to be reloaded from source")
+	}
+
+	var handlerBuilds int
+	mux := http.NewServeMux()
+	mux.Handle("/wxpush/{appid}", registry.MiniProgramRouter(func(r *http.Request) string {
+		return r.PathValue("appid")
+	}, func(client *wechat.MiniProgramClient) *wechat.MiniProgramMessageHandlers {
+		handlerBuilds++
+		return &wechat.MiniProgramMessageHandlers{}
+	}))
+
+	query := url.Values{"timestamp": {"1700000000"}, "nonce": {"nonce"}, "echostr": {"echo"}}
+	query.Set("signature", util.Signature(testPushToken, "1700000000", "nonce"))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wxpush/wx_mp_1?"+query.Encode(), nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "echo" {
+		t.Fatalf("unexpected response for wx_mp_1: %d %s", rec.Code, rec.Body.String())
+	}
+	// 同一客户端复用已创建的处理器
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wxpush/wx_mp_1?"+query.Encode(), nil))
+	if rec.Code != http.StatusOK || handlerBuilds != 1 {
+		t.Fatalf("expected handler to be built once, got %d builds", handlerBuilds)
+	}
+
+	// wx_mp_2 使用不同的 Token，签名校验失败
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wxpush/wx_mp_2?"+query.Encode(), nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for wx_mp_2, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wxpush/wx_unknown?"+query.Encode(), nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown app, got %d", rec.Code)
+	}
+}
+
+// TestRegistry_NilCache 测试未指定缓存时使用内存缓存
+func TestRegistry_NilCache(t *testing.T) {
+	registry := wechat.NewRegistry(nil, nil)
+	if registry.GetCache() == nil {
+		t.Fatal("expected default memory cache")
+	}
+
+	client := registry.AddMiniProgram(&wechat.MiniProgramConfig{AppId: "wx_mp_1", AppSecret: "secret_1"})
+	if err := client.GetSessionKeyStore().Save(context.Background(), "openid", "session_key"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+}
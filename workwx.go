@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"os"
+	"net/url"
 	"strings"
 	"time"
 
@@ -16,39 +18,48 @@ import (
 
 // WorkwxConfig 企业微信配置
 type WorkwxConfig struct {
-	CorpID         string `json:"corpId" mapstructure:"corpId"`                 // 企业ID
-	AgentID        int64  `json:"agentId" mapstructure:"agentId"`               // 应用ID
-	AgentSecret    string `json:"agentSecret" mapstructure:"agentSecret"`       // 应用Secret
-	Token          string `json:"token" mapstructure:"token"`                   // 回调Token
-	EncodingAESKey string `json:"encodingAESKey" mapstructure:"encodingAESKey"` // 回调加解密Key
-	RedisAddr      string `json:"redisAddr" mapstructure:"redisAddr"`           // Redis地址，可选
+	CorpID         string       `json:"corpId" yaml:"corpId" mapstructure:"corpId"`                         // 企业ID
+	AgentID        int64        `json:"agentId" yaml:"agentId" mapstructure:"agentId"`                      // 应用ID
+	AgentSecret    string       `json:"agentSecret" yaml:"agentSecret" mapstructure:"agentSecret"`          // 应用Secret
+	Token          string       `json:"token" yaml:"token" mapstructure:"token"`                            // 回调Token
+	EncodingAESKey string       `json:"encodingAESKey" yaml:"encodingAESKey" mapstructure:"encodingAESKey"` // 回调加解密Key
+	RedisAddr      string       `json:"redisAddr" yaml:"redisAddr" mapstructure:"redisAddr"`                // Redis地址，可选，配置 Redis 时忽略
+	Redis          *RedisConfig `json:"redis" yaml:"redis" mapstructure:"redis"`                            // Redis 连接配置，可选，相同配置的客户端共用连接池
+	BaseURL        string       `json:"baseUrl" yaml:"baseUrl" mapstructure:"baseUrl"`                      // 接口地址，可选，默认为企业微信正式环境
 }
 
+// defaultWorkwxBaseURL 企业微信接口地址
+const defaultWorkwxBaseURL = "https://qyapi.weixin.qq.com"
+
 // WorkwxClient 企业微信客户端
 type WorkwxClient struct {
 	workwxApp           *workwx.WorkwxApp
 	config              *WorkwxConfig
 	accessTokenProvider workwx.ITokenProvider
 	httpClient          *http.Client
+	cache               cache.Cache
+	baseURL             string
 }
 
 // NewWorkwxClient 创建企业微信客户端
 func NewWorkwxClient(cfg *WorkwxConfig) *WorkwxClient {
+	// 如果配置了 Redis，使用 Redis 缓存 access_token
+	return NewWorkwxClientWithCache(cfg, newCache(cfg.Redis, cfg.RedisAddr))
+}
+
+// NewWorkwxClientWithCache 使用指定缓存创建企业微信客户端，忽略 RedisAddr 配置
+// 多个客户端可共用同一个缓存连接池
+func NewWorkwxClientWithCache(cfg *WorkwxConfig, myCache cache.Cache) *WorkwxClient {
 	var opts []workwx.CtorOption
-	var myCache cache.Cache
 
-	// 如果配置了 Redis，使用 Redis 缓存 access_token
-	if cfg.RedisAddr != "" {
-		myCache = cache.NewRedis(context.Background(), &cache.RedisOpts{
-			Host:     cfg.RedisAddr,
-			Username: os.Getenv("REDIS_USERNAME"),
-			Password: os.Getenv("REDIS_PASSWORD"),
-		})
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultWorkwxBaseURL
 	} else {
-		myCache = cache.NewMemory()
+		opts = append(opts, workwx.WithQYAPIHost(baseURL))
 	}
 
-	accessTokenProvider := NewWorkwxAccessTokenProvider(cfg.CorpID, cfg.AgentSecret, myCache)
+	accessTokenProvider := newWorkwxAccessTokenProvider(baseURL, cfg.CorpID, cfg.AgentSecret, myCache)
 	opts = append(opts, workwx.WithAccessTokenProvider(accessTokenProvider))
 
 	wx := workwx.New(cfg.CorpID, opts...)
@@ -61,6 +72,8 @@ func NewWorkwxClient(cfg *WorkwxConfig) *WorkwxClient {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		cache:   myCache,
+		baseURL: baseURL,
 	}
 }
 
@@ -315,6 +328,11 @@ func (c *WorkwxClient) KfSendCALinkMessage(touser, openKfID, msgID, linkURL stri
 
 // sendKfMessage 发送客服消息的通用方法
 func (c *WorkwxClient) sendKfMessage(touser, openKfID, msgID string, msgData map[string]any) (*KfSendMessageResponse, error) {
+	return c.sendKfMessageContext(context.Background(), touser, openKfID, msgID, msgData)
+}
+
+// sendKfMessageContext 发送客服消息的通用方法，支持通过 ctx 取消请求
+func (c *WorkwxClient) sendKfMessageContext(ctx context.Context, touser, openKfID, msgID string, msgData map[string]any) (*KfSendMessageResponse, error) {
 	// 构建请求体
 	req := map[string]any{
 		"touser":    touser,
@@ -334,28 +352,28 @@ func (c *WorkwxClient) sendKfMessage(touser, openKfID, msgID string, msgData map
 	// 由于 go-workwx 没有直接暴露 HTTP 客户端，我们需要手动实现
 	// 这里先实现一个简单的 HTTP 调用
 
-	return c.kfSendMessage(req)
+	return c.kfSendMessage(ctx, req)
 }
 
 // kfSendMessage 实际调用企业微信客服发送消息接口
-func (c *WorkwxClient) kfSendMessage(req map[string]any) (*KfSendMessageResponse, error) {
+func (c *WorkwxClient) kfSendMessage(ctx context.Context, req map[string]any) (*KfSendMessageResponse, error) {
 	// 由于 go-workwx 没有直接暴露底层的 HTTP 客户端
 	// 这里需要手动实现 HTTP 调用
 	// 可以使用 c.workwxApp 的内部方法，或者使用标准库
 
-	return c.doKfSendMessage(req)
+	return c.doKfSendMessage(ctx, req)
 }
 
 // doKfSendMessage 执行客服发送消息的 HTTP 请求
-func (c *WorkwxClient) doKfSendMessage(req map[string]any) (*KfSendMessageResponse, error) {
+func (c *WorkwxClient) doKfSendMessage(ctx context.Context, req map[string]any) (*KfSendMessageResponse, error) {
 	// 获取 access_token
-	token, err := c.accessTokenProvider.GetToken(context.Background())
+	token, err := c.accessTokenProvider.GetToken(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("获取 access_token 失败: %w", err)
 	}
 
 	// 构建请求 URL
-	url := fmt.Sprintf("https://qyapi.weixin.qq.com/cgi-bin/kf/send_msg?access_token=%s", token)
+	url := fmt.Sprintf("%s/cgi-bin/kf/send_msg?access_token=%s", c.baseURL, token)
 
 	// 序列化请求体
 	jsonData, err := json.Marshal(req)
@@ -364,7 +382,7 @@ func (c *WorkwxClient) doKfSendMessage(req map[string]any) (*KfSendMessageRespon
 	}
 
 	// 创建 HTTP 请求
-	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -391,3 +409,117 @@ func (c *WorkwxClient) doKfSendMessage(req map[string]any) (*KfSendMessageRespon
 
 	return &result, nil
 }
+
+// ==================== 通用请求 ====================
+
+// workwxResponse 企业微信接口通用响应
+type workwxResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// apiError 返回接口错误
+func (r *workwxResponse) apiError() error {
+	if r.ErrCode != 0 {
+		return fmt.Errorf("企业微信 API 返回错误: %d - %s", r.ErrCode, r.ErrMsg)
+	}
+	return nil
+}
+
+// workwxResult 内嵌 workwxResponse 的接口响应
+type workwxResult interface {
+	apiError() error
+}
+
+// postJSON 调用企业微信 POST 接口并解析响应
+func (c *WorkwxClient) postJSON(ctx context.Context, path string, req any, resp workwxResult) error {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	return c.doRequest(ctx, http.MethodPost, path, nil, bytes.NewReader(jsonData), resp)
+}
+
+// getJSON 调用企业微信 GET 接口并解析响应
+func (c *WorkwxClient) getJSON(ctx context.Context, path string, query url.Values, resp workwxResult) error {
+	return c.doRequest(ctx, http.MethodGet, path, query, nil, resp)
+}
+
+// doRequest 携带 access_token 调用企业微信接口
+func (c *WorkwxClient) doRequest(ctx context.Context, method, path string, query url.Values, body io.Reader, resp workwxResult) error {
+	token, err := c.accessTokenProvider.GetToken(ctx)
+	if err != nil {
+		return fmt.Errorf("获取 access_token 失败: %w", err)
+	}
+
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("access_token", token)
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, c.baseURL+path+"?"+query.Encode(), body)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	if err := json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	return resp.apiError()
+}
+
+// ==================== 身份转换 ====================
+
+// UnionIDToExternalUserID 通过 unionid 和 openid 查询客户的 external_userid
+// openID 为企业绑定的小程序或公众号下该用户的 openid
+// 用户不是企业客户时返回空字符串
+func (c *WorkwxClient) UnionIDToExternalUserID(ctx context.Context, unionID, openID string) (string, error) {
+	if unionID == "" || openID == "" {
+		return "", errors.New("unionid 和 openid 不能为空")
+	}
+
+	var resp struct {
+		workwxResponse
+		ExternalUserID string `json:"external_userid"`
+	}
+	err := c.postJSON(ctx, "/cgi-bin/externalcontact/unionid_to_external_userid", map[string]string{
+		"unionid": unionID,
+		"openid":  openID,
+	}, &resp)
+	if err != nil {
+		return "", fmt.Errorf("unionid 转换 external_userid 失败: %w", err)
+	}
+
+	return resp.ExternalUserID, nil
+}
+
+// ConvertToOpenID 将客户的 external_userid 转换为企业绑定的微信支付 appid 下的 openid
+func (c *WorkwxClient) ConvertToOpenID(ctx context.Context, externalUserID string) (string, error) {
+	if externalUserID == "" {
+		return "", errors.New("external_userid 不能为空")
+	}
+
+	var resp struct {
+		workwxResponse
+		OpenID string `json:"openid"`
+	}
+	err := c.postJSON(ctx, "/cgi-bin/externalcontact/convert_to_openid", map[string]string{
+		"external_userid": externalUserID,
+	}, &resp)
+	if err != nil {
+		return "", fmt.Errorf("external_userid 转换 openid 失败: %w", err)
+	}
+
+	return resp.OpenID, nil
+}
@@ -32,10 +32,15 @@ type getTokenResponse struct {
 
 // NewWorkwxAccessTokenProvider 创建基于 cache.Cache 的 AccessToken 提供者
 func NewWorkwxAccessTokenProvider(corpID, secret string, cache cache.Cache) workwx.ITokenProvider {
+	return newWorkwxAccessTokenProvider(defaultWorkwxBaseURL, corpID, secret, cache)
+}
+
+// newWorkwxAccessTokenProvider 创建指定接口地址的 AccessToken 提供者
+func newWorkwxAccessTokenProvider(baseURL, corpID, secret string, cache cache.Cache) *workwxAccessTokenProvider {
 	return &workwxAccessTokenProvider{
 		corpID:   corpID,
 		secret:   secret,
-		tokenUrl: fmt.Sprintf("https://qyapi.weixin.qq.com/cgi-bin/gettoken?corpid=%s&corpsecret=%s", corpID, secret),
+		tokenUrl: fmt.Sprintf("%s/cgi-bin/gettoken?corpid=%s&corpsecret=%s", baseURL, corpID, secret),
 		cache:    cache,
 		cacheKey: "workwx:access_token:" + secret,
 		httpClient: &http.Client{
@@ -0,0 +1,339 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/silenceper/wechat/v2/cache"
+	"github.com/xen0n/go-workwx/v2"
+)
+
+// defaultKfCursorTTL 客服消息游标缓存时长
+// 游标丢失后会从头拉取最近 3 天的消息，处理函数需按 msgid 保证幂等
+const defaultKfCursorTTL = 30 * 24 * time.Hour
+
+// 客服消息类型
+const (
+	KfMsgTypeText         = "text"
+	KfMsgTypeImage        = "image"
+	KfMsgTypeVoice        = "voice"
+	KfMsgTypeVideo        = "video"
+	KfMsgTypeFile         = "file"
+	KfMsgTypeLocation     = "location"
+	KfMsgTypeLink         = "link"
+	KfMsgTypeBusinessCard = "business_card"
+	KfMsgTypeMiniProgram  = "miniprogram"
+	KfMsgTypeMsgMenu      = "msgmenu"
+	KfMsgTypeEvent        = "event"
+)
+
+// 客服消息来源
+const (
+	KfOriginCustomer = 3 // 微信客户发送的消息
+	KfOriginSystem   = 4 // 系统推送的事件消息
+	KfOriginServicer = 5 // 接待人员在企业微信客户端发送的消息
+)
+
+// 客服事件类型
+const (
+	KfEventEnterSession                  = "enter_session"                     // 用户进入会话
+	KfEventMsgSendFail                   = "msg_send_fail"                     // 消息发送失败
+	KfEventServicerStatusChange          = "servicer_status_change"            // 接待人员接待状态变更
+	KfEventSessionStatusChange           = "session_status_change"             // 会话状态变更
+	KfEventUserRecallMsg                 = "user_recall_msg"                   // 用户撤回消息
+	KfEventServicerRecallMsg             = "servicer_recall_msg"               // 接待人员撤回消息
+	KfEventRejectCustomerMsgSwitchChange = "reject_customer_msg_switch_change" // 拒收客户消息开关变更
+)
+
+// KfMessage 微信客服消息，按 MsgType 读取对应类型的字段
+type KfMessage struct {
+	MsgID          string `json:"msgid"`           // 消息ID
+	OpenKfID       string `json:"open_kfid"`       // 客服账号ID，事件消息不返回
+	ExternalUserID string `json:"external_userid"` // 客户的 external_userid，事件消息不返回
+	SendTime       int64  `json:"send_time"`       // 消息发送时间
+	Origin         int    `json:"origin"`          // 消息来源
+	ServicerUserID string `json:"servicer_userid"` // 发送消息的接待人员 userid，Origin 为 5 时返回
+	MsgType        string `json:"msgtype"`         // 消息类型
+
+	Text         *KfText         `json:"text,omitempty"`
+	Image        *KfMedia        `json:"image,omitempty"`
+	Voice        *KfMedia        `json:"voice,omitempty"`
+	Video        *KfMedia        `json:"video,omitempty"`
+	File         *KfMedia        `json:"file,omitempty"`
+	Location     *KfLocation     `json:"location,omitempty"`
+	Link         *KfLink         `json:"link,omitempty"`
+	BusinessCard *KfBusinessCard `json:"business_card,omitempty"`
+	MiniProgram  *KfMiniProgram  `json:"miniprogram,omitempty"`
+	MsgMenu      *KfMsgMenu      `json:"msgmenu,omitempty"`
+	Event        *KfEvent        `json:"event,omitempty"`
+}
+
+// KfText 文本消息
+type KfText struct {
+	Content string `json:"content"`           // 文本内容
+	MenuID  string `json:"menu_id,omitempty"` // 客户点击菜单消息时对应的菜单ID
+}
+
+// KfMedia 图片、语音、视频和文件消息
+type KfMedia struct {
+	MediaID string `json:"media_id"` // 媒体文件ID，3 天内有效
+}
+
+// KfLocation 地理位置消息
+type KfLocation struct {
+	Latitude  float64 `json:"latitude"`  // 纬度
+	Longitude float64 `json:"longitude"` // 经度
+	Name      string  `json:"name"`      // 位置名
+	Address   string  `json:"address"`   // 地址详情
+}
+
+// KfLink 链接消息
+type KfLink struct {
+	Title  string `json:"title"`   // 标题
+	Desc   string `json:"desc"`    // 描述
+	URL    string `json:"url"`     // 点击后跳转的链接
+	PicURL string `json:"pic_url"` // 缩略图链接
+}
+
+// KfBusinessCard 名片消息
+type KfBusinessCard struct {
+	UserID string `json:"userid"` // 名片 userid
+}
+
+// KfMiniProgram 小程序消息
+type KfMiniProgram struct {
+	Title        string `json:"title"`          // 标题
+	AppID        string `json:"appid"`          // 小程序 appid
+	PagePath     string `json:"pagepath"`       // 点击消息卡片后进入的小程序页面路径
+	ThumbMediaID string `json:"thumb_media_id"` // 封面的 media_id
+}
+
+// KfMsgMenu 菜单消息
+type KfMsgMenu struct {
+	HeadContent string           `json:"head_content,omitempty"` // 起始文本
+	List        []*KfMsgMenuItem `json:"list,omitempty"`         // 菜单项
+	TailContent string           `json:"tail_content,omitempty"` // 结束文本
+}
+
+// KfMsgMenuItem 菜单项，Type 为 click、view、miniprogram 或 text
+type KfMsgMenuItem struct {
+	Type        string                `json:"type"`
+	Click       *KfMsgMenuClick       `json:"click,omitempty"`
+	View        *KfMsgMenuView        `json:"view,omitempty"`
+	MiniProgram *KfMsgMenuMiniProgram `json:"miniprogram,omitempty"`
+	Text        *KfMsgMenuText        `json:"text,omitempty"`
+}
+
+// KfMsgMenuClick 回复菜单，客户点击后发送菜单内容并回传菜单ID
+type KfMsgMenuClick struct {
+	ID      string `json:"id,omitempty"` // 菜单ID
+	Content string `json:"content"`      // 菜单显示内容
+}
+
+// KfMsgMenuView 超链接菜单
+type KfMsgMenuView struct {
+	URL     string `json:"url"`     // 点击后跳转的链接
+	Content string `json:"content"` // 菜单显示内容
+}
+
+// KfMsgMenuMiniProgram 小程序菜单
+type KfMsgMenuMiniProgram struct {
+	AppID    string `json:"appid"`    // 小程序 appid
+	PagePath string `json:"pagepath"` // 点击后进入的小程序页面路径
+	Content  string `json:"content"`  // 菜单显示内容
+}
+
+// KfMsgMenuText 文本菜单
+type KfMsgMenuText struct {
+	Content   string `json:"content"`              // 文本内容
+	NoNewline int    `json:"no_newline,omitempty"` // 内容后是否不换行，1 为不换行
+}
+
+// KfEvent 客服事件
+type KfEvent struct {
+	EventType         string           `json:"event_type"`                    // 事件类型
+	OpenKfID          string           `json:"open_kfid,omitempty"`           // 客服账号ID
+	ExternalUserID    string           `json:"external_userid,omitempty"`     // 客户的 external_userid
+	ServicerUserID    string           `json:"servicer_userid,omitempty"`     // 接待人员 userid
+	Scene             string           `json:"scene,omitempty"`               // 进入会话的场景值
+	SceneParam        string           `json:"scene_param,omitempty"`         // 进入会话的自定义场景参数
+	WelcomeCode       string           `json:"welcome_code,omitempty"`        // 发送欢迎语的凭证，20 秒内有效
+	WechatChannels    *KfWechatChannel `json:"wechat_channels,omitempty"`     // 从视频号进入会话时的视频号信息
+	FailMsgID         string           `json:"fail_msgid,omitempty"`          // 发送失败的消息ID
+	FailType          int              `json:"fail_type,omitempty"`           // 发送失败的原因
+	Status            int              `json:"status,omitempty"`              // 接待人员的接待状态
+	StopType          int              `json:"stop_type,omitempty"`           // 接待人员的停止接待类型
+	ChangeType        int              `json:"change_type,omitempty"`         // 会话状态变更类型
+	OldServicerUserID string           `json:"old_servicer_userid,omitempty"` // 原接待人员 userid
+	NewServicerUserID string           `json:"new_servicer_userid,omitempty"` // 新接待人员 userid
+	MsgCode           string           `json:"msg_code,omitempty"`            // 发送结束语等事件消息的凭证
+	RecallMsgID       string           `json:"recall_msgid,omitempty"`        // 撤回的消息ID
+	RejectSwitch      int              `json:"reject_switch,omitempty"`       // 拒收客户消息开关，1 为开启
+}
+
+// KfWechatChannel 视频号信息
+type KfWechatChannel struct {
+	Nickname     string `json:"nickname"`      // 视频号名称
+	ShopNickname string `json:"shop_nickname"` // 视频号小店名称
+	Scene        int    `json:"scene"`         // 视频号场景
+}
+
+// KfSyncMsgRequest 读取客服消息请求
+type KfSyncMsgRequest struct {
+	Cursor      string `json:"cursor,omitempty"`       // 上一次调用时返回的 next_cursor
+	Token       string `json:"token,omitempty"`        // 回调事件返回的 token，10 分钟内有效，携带时调用频率限制更宽松
+	Limit       int    `json:"limit,omitempty"`        // 期望请求的数据量，默认值和最大值都为 1000
+	VoiceFormat int    `json:"voice_format,omitempty"` // 语音消息类型，0 为 Amr，1 为 Silk
+	OpenKfID    string `json:"open_kfid"`              // 客服账号ID
+}
+
+// KfSyncMsgResponse 读取客服消息响应
+type KfSyncMsgResponse struct {
+	NextCursor string       `json:"next_cursor"` // 下次调用带上该值，则从当前的位置继续往后拉
+	HasMore    int          `json:"has_more"`    // 是否还有更多数据，0 为否，1 为是
+	MsgList    []*KfMessage `json:"msg_list"`    // 消息列表
+}
+
+// KfSyncMsg 读取客服消息
+func (c *WorkwxClient) KfSyncMsg(ctx context.Context, req *KfSyncMsgRequest) (*KfSyncMsgResponse, error) {
+	if req.OpenKfID == "" {
+		return nil, errors.New("open_kfid 不能为空")
+	}
+
+	var resp struct {
+		workwxResponse
+		KfSyncMsgResponse
+	}
+	if err := c.postJSON(ctx, "/cgi-bin/kf/sync_msg", req, &resp); err != nil {
+		return nil, fmt.Errorf("读取客服消息失败: %w", err)
+	}
+
+	return &resp.KfSyncMsgResponse, nil
+}
+
+// KfMessageHandler 客服消息处理器
+type KfMessageHandler interface {
+	OnKfMessage(ctx context.Context, msg *KfMessage) error
+}
+
+// KfMessageHandlerFunc 函数形式的客服消息处理器
+type KfMessageHandlerFunc func(ctx context.Context, msg *KfMessage) error
+
+// OnKfMessage 处理客服消息
+func (f KfMessageHandlerFunc) OnKfMessage(ctx context.Context, msg *KfMessage) error {
+	return f(ctx, msg)
+}
+
+// KfConsumerOptions 客服消息消费者选项
+type KfConsumerOptions struct {
+	Limit       int                              // 每次拉取的消息数量，默认 1000
+	VoiceFormat int                              // 语音消息格式，0 为 Amr，1 为 Silk
+	CursorTTL   time.Duration                    // 游标缓存时长，默认 30 天
+	Timeout     time.Duration                    // 回调触发的异步拉取超时时间，默认 5 分钟
+	OnError     func(openKfID string, err error) // 回调触发的异步拉取失败时调用
+}
+
+// KfConsumer 微信客服消息消费者
+// 收到 kf_msg_or_event 回调后通过 kf/sync_msg 拉取消息，游标按客服账号保存在客户端缓存中
+type KfConsumer struct {
+	client  *WorkwxClient
+	handler KfMessageHandler
+	opts    KfConsumerOptions
+	locks   keyLocks // 同一客服账号串行拉取
+}
+
+var _ workwx.RxMessageHandler = (*KfConsumer)(nil)
+
+// NewKfConsumer 创建客服消息消费者
+func (c *WorkwxClient) NewKfConsumer(handler KfMessageHandler, opts *KfConsumerOptions) *KfConsumer {
+	consumer := &KfConsumer{client: c, handler: handler}
+	if opts != nil {
+		consumer.opts = *opts
+	}
+	if consumer.opts.CursorTTL <= 0 {
+		consumer.opts.CursorTTL = defaultKfCursorTTL
+	}
+	if consumer.opts.Timeout <= 0 {
+		consumer.opts.Timeout = 5 * time.Minute
+	}
+
+	return consumer
+}
+
+// OnIncomingMessage 实现 workwx.RxMessageHandler 接口
+// 收到 kf_msg_or_event 事件时异步拉取消息，以便在 5 秒内应答回调，其他消息忽略
+// 可直接传给 WorkwxClient.CreateHTTPHandler 使用
+func (k *KfConsumer) OnIncomingMessage(msg *workwx.RxMessage) error {
+	event, ok := msg.EventKfMsgOrEvent()
+	if !ok {
+		return nil
+	}
+
+	openKfID, token := event.GetOpenKfID(), event.GetToken()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), k.opts.Timeout)
+		defer cancel()
+		if err := k.Sync(ctx, openKfID, token); err != nil && k.opts.OnError != nil {
+			k.opts.OnError(openKfID, err)
+		}
+	}()
+
+	return nil
+}
+
+// Sync 从保存的游标开始拉取客服账号的全部新消息并逐条处理
+// 每页消息全部处理成功后才保存游标，处理失败时返回错误，下次拉取会重新投递该页消息
+func (k *KfConsumer) Sync(ctx context.Context, openKfID, token string) error {
+	unlock := k.locks.lock(openKfID)
+	defer unlock()
+
+	cursor := k.Cursor(ctx, openKfID)
+	for {
+		resp, err := k.client.KfSyncMsg(ctx, &KfSyncMsgRequest{
+			Cursor:      cursor,
+			Token:       token,
+			Limit:       k.opts.Limit,
+			VoiceFormat: k.opts.VoiceFormat,
+			OpenKfID:    openKfID,
+		})
+		if err != nil {
+			return err
+		}
+		// 没有新游标时继续拉取只会重复返回同一页
+		if resp.HasMore != 0 && resp.NextCursor == "" {
+			return errors.New("拉取客服消息失败: has_more 为 1 但 next_cursor 为空")
+		}
+		if resp.HasMore != 0 && resp.NextCursor == cursor {
+			return fmt.Errorf("拉取客服消息失败: has_more 为 1 但 next_cursor 未变化: %s", cursor)
+		}
+
+		for _, msg := range resp.MsgList {
+			if err := k.handler.OnKfMessage(ctx, msg); err != nil {
+				return fmt.Errorf("处理客服消息 %s 失败: %w", msg.MsgID, err)
+			}
+		}
+
+		if resp.NextCursor != "" {
+			cursor = resp.NextCursor
+			if err := cache.SetContext(ctx, k.client.cache, k.cursorKey(openKfID), cursor, k.opts.CursorTTL); err != nil {
+				return fmt.Errorf("保存客服消息游标失败: %w", err)
+			}
+		}
+		if resp.HasMore == 0 {
+			return nil
+		}
+	}
+}
+
+// Cursor 获取客服账号已保存的消息游标
+func (k *KfConsumer) Cursor(ctx context.Context, openKfID string) string {
+	cursor, _ := cache.GetContext(ctx, k.client.cache, k.cursorKey(openKfID)).(string)
+	return cursor
+}
+
+// cursorKey 游标的缓存键
+func (k *KfConsumer) cursorKey(openKfID string) string {
+	return "workwx:kf:cursor:" + k.client.config.CorpID + ":" + openKfID
+}
@@ -0,0 +1,275 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"unicode/utf8"
+)
+
+const (
+	// maxKfAccountNameLength 客服名称最大长度
+	maxKfAccountNameLength = 16
+	// maxKfAccountListLimit 获取客服账号列表每页最大数量
+	maxKfAccountListLimit = 100
+	// maxKfServicerBatchSize 每次添加或删除的接待人员和部门最大数量
+	maxKfServicerBatchSize = 100
+)
+
+// kfContactScenePattern 客服链接场景值格式，不超过 32 字节，只能包含数字、字母、下划线和短横线
+var kfContactScenePattern = regexp.MustCompile(`^[0-9a-zA-Z_-]{0,32}$`)
+
+// KfAccount 客服账号
+type KfAccount struct {
+	OpenKfID        string `json:"open_kfid"`        // 客服账号ID
+	Name            string `json:"name"`             // 客服名称
+	Avatar          string `json:"avatar"`           // 客服头像链接
+	ManagePrivilege bool   `json:"manage_privilege"` // 当前调用接口的应用是否有管理权限
+}
+
+// CreateKfAccount 添加客服账号
+// name: 客服名称，不超过 16 个字符
+// mediaID: 客服头像临时素材ID
+func (c *WorkwxClient) CreateKfAccount(ctx context.Context, name, mediaID string) (string, error) {
+	if err := validateKfAccountName(name); err != nil {
+		return "", err
+	}
+	if mediaID == "" {
+		return "", errors.New("客服头像 media_id 不能为空")
+	}
+
+	var resp struct {
+		workwxResponse
+		OpenKfID string `json:"open_kfid"`
+	}
+	err := c.postJSON(ctx, "/cgi-bin/kf/account/add", map[string]string{
+		"name":     name,
+		"media_id": mediaID,
+	}, &resp)
+	if err != nil {
+		return "", fmt.Errorf("添加客服账号失败: %w", err)
+	}
+
+	return resp.OpenKfID, nil
+}
+
+// UpdateKfAccount 修改客服账号，name 或 mediaID 为空时不修改对应字段
+func (c *WorkwxClient) UpdateKfAccount(ctx context.Context, openKfID, name, mediaID string) error {
+	if openKfID == "" {
+		return errors.New("open_kfid 不能为空")
+	}
+	if name == "" && mediaID == "" {
+		return errors.New("客服名称和头像不能同时为空")
+	}
+
+	req := map[string]string{"open_kfid": openKfID}
+	if name != "" {
+		if err := validateKfAccountName(name); err != nil {
+			return err
+		}
+		req["name"] = name
+	}
+	if mediaID != "" {
+		req["media_id"] = mediaID
+	}
+
+	var resp workwxResponse
+	if err := c.postJSON(ctx, "/cgi-bin/kf/account/update", req, &resp); err != nil {
+		return fmt.Errorf("修改客服账号失败: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteKfAccount 删除客服账号
+func (c *WorkwxClient) DeleteKfAccount(ctx context.Context, openKfID string) error {
+	if openKfID == "" {
+		return errors.New("open_kfid 不能为空")
+	}
+
+	var resp workwxResponse
+	if err := c.postJSON(ctx, "/cgi-bin/kf/account/del", map[string]string{"open_kfid": openKfID}, &resp); err != nil {
+		return fmt.Errorf("删除客服账号失败: %w", err)
+	}
+
+	return nil
+}
+
+// ListKfAccounts 分页获取客服账号列表
+// limit: 每页数量，1 到 100，小于等于 0 时使用 100
+func (c *WorkwxClient) ListKfAccounts(ctx context.Context, offset, limit int) ([]*KfAccount, error) {
+	if offset < 0 {
+		return nil, errors.New("offset 不能为负数")
+	}
+	if limit <= 0 || limit > maxKfAccountListLimit {
+		limit = maxKfAccountListLimit
+	}
+
+	var resp struct {
+		workwxResponse
+		AccountList []*KfAccount `json:"account_list"`
+	}
+	err := c.postJSON(ctx, "/cgi-bin/kf/account/list", map[string]int{
+		"offset": offset,
+		"limit":  limit,
+	}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("获取客服账号列表失败: %w", err)
+	}
+
+	return resp.AccountList, nil
+}
+
+// ListAllKfAccounts 获取全部客服账号
+func (c *WorkwxClient) ListAllKfAccounts(ctx context.Context) ([]*KfAccount, error) {
+	var accounts []*KfAccount
+	for offset := 0; ; offset += maxKfAccountListLimit {
+		page, err := c.ListKfAccounts(ctx, offset, maxKfAccountListLimit)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, page...)
+		if len(page) < maxKfAccountListLimit {
+			return accounts, nil
+		}
+	}
+}
+
+// GetKfContactURL 获取客服账号链接
+// scene: 场景值，不超过 32 字节，只能包含数字、字母、下划线和短横线，用户进入会话事件中会返回该值
+func (c *WorkwxClient) GetKfContactURL(ctx context.Context, openKfID, scene string) (string, error) {
+	if openKfID == "" {
+		return "", errors.New("open_kfid 不能为空")
+	}
+	if !kfContactScenePattern.MatchString(scene) {
+		return "", fmt.Errorf("scene 格式不正确: %s", scene)
+	}
+
+	req := map[string]string{"open_kfid": openKfID}
+	if scene != "" {
+		req["scene"] = scene
+	}
+
+	var resp struct {
+		workwxResponse
+		URL string `json:"url"`
+	}
+	if err := c.postJSON(ctx, "/cgi-bin/kf/add_contact_way", req, &resp); err != nil {
+		return "", fmt.Errorf("获取客服账号链接失败: %w", err)
+	}
+
+	return resp.URL, nil
+}
+
+// validateKfAccountName 校验客服名称
+func validateKfAccountName(name string) error {
+	if name == "" {
+		return errors.New("客服名称不能为空")
+	}
+	if utf8.RuneCountInString(name) > maxKfAccountNameLength {
+		return fmt.Errorf("客服名称不能超过 %d 个字符", maxKfAccountNameLength)
+	}
+	return nil
+}
+
+// ==================== 接待人员 ====================
+
+// 接待人员的接待状态
+const (
+	KfServicerStatusReceiving = 0 // 接待中
+	KfServicerStatusStopped   = 1 // 停止接待
+)
+
+// KfServicer 接待人员
+// 按成员添加时返回 UserID，按部门添加时返回 DepartmentID
+type KfServicer struct {
+	UserID       string `json:"userid,omitempty"`        // 接待人员 userid
+	DepartmentID int64  `json:"department_id,omitempty"` // 接待部门ID
+	Status       int    `json:"status"`                  // 接待状态，仅成员返回
+	StopType     int    `json:"stop_type"`               // 停止接待类型，0 为停止接待，1 为暂时挂起
+}
+
+// KfServicerResult 添加或删除接待人员的结果
+type KfServicerResult struct {
+	UserID       string `json:"userid,omitempty"`        // 接待人员 userid
+	DepartmentID int64  `json:"department_id,omitempty"` // 接待部门ID
+	ErrCode      int    `json:"errcode"`                 // 该成员或部门的处理结果
+	ErrMsg       string `json:"errmsg"`
+}
+
+// AddKfServicers 添加接待人员，userIDs 和 departmentIDs 至少填一个，每种最多 100 个
+// 部分成员或部门添加失败时同时返回全部结果和失败原因
+func (c *WorkwxClient) AddKfServicers(ctx context.Context, openKfID string, userIDs []string, departmentIDs []int64) ([]*KfServicerResult, error) {
+	return c.changeKfServicers(ctx, "/cgi-bin/kf/servicer/add", "添加接待人员", openKfID, userIDs, departmentIDs)
+}
+
+// DeleteKfServicers 删除接待人员，userIDs 和 departmentIDs 至少填一个，每种最多 100 个
+// 部分成员或部门删除失败时同时返回全部结果和失败原因
+func (c *WorkwxClient) DeleteKfServicers(ctx context.Context, openKfID string, userIDs []string, departmentIDs []int64) ([]*KfServicerResult, error) {
+	return c.changeKfServicers(ctx, "/cgi-bin/kf/servicer/del", "删除接待人员", openKfID, userIDs, departmentIDs)
+}
+
+// ListKfServicers 获取接待人员列表
+func (c *WorkwxClient) ListKfServicers(ctx context.Context, openKfID string) ([]*KfServicer, error) {
+	if openKfID == "" {
+		return nil, errors.New("open_kfid 不能为空")
+	}
+
+	var resp struct {
+		workwxResponse
+		ServicerList []*KfServicer `json:"servicer_list"`
+	}
+	if err := c.getJSON(ctx, "/cgi-bin/kf/servicer/list", url.Values{"open_kfid": {openKfID}}, &resp); err != nil {
+		return nil, fmt.Errorf("获取接待人员列表失败: %w", err)
+	}
+
+	return resp.ServicerList, nil
+}
+
+// changeKfServicers 添加或删除接待人员
+func (c *WorkwxClient) changeKfServicers(ctx context.Context, path, action, openKfID string, userIDs []string, departmentIDs []int64) ([]*KfServicerResult, error) {
+	if openKfID == "" {
+		return nil, errors.New("open_kfid 不能为空")
+	}
+	if len(userIDs) == 0 && len(departmentIDs) == 0 {
+		return nil, errors.New("接待人员和部门不能同时为空")
+	}
+	if len(userIDs) > maxKfServicerBatchSize || len(departmentIDs) > maxKfServicerBatchSize {
+		return nil, fmt.Errorf("接待人员和部门每次最多 %d 个", maxKfServicerBatchSize)
+	}
+
+	req := map[string]any{"open_kfid": openKfID}
+	if len(userIDs) > 0 {
+		req["userid_list"] = userIDs
+	}
+	if len(departmentIDs) > 0 {
+		req["department_id_list"] = departmentIDs
+	}
+
+	var resp struct {
+		workwxResponse
+		ResultList []*KfServicerResult `json:"result_list"`
+	}
+	if err := c.postJSON(ctx, path, req, &resp); err != nil {
+		return nil, fmt.Errorf("%s失败: %w", action, err)
+	}
+
+	var errs []error
+	for _, result := range resp.ResultList {
+		if result.ErrCode == 0 {
+			continue
+		}
+		if result.UserID != "" {
+			errs = append(errs, fmt.Errorf("成员 %s: %d - %s", result.UserID, result.ErrCode, result.ErrMsg))
+		} else {
+			errs = append(errs, fmt.Errorf("部门 %d: %d - %s", result.DepartmentID, result.ErrCode, result.ErrMsg))
+		}
+	}
+	if len(errs) > 0 {
+		return resp.ResultList, fmt.Errorf("%s部分失败: %w", action, errors.Join(errs...))
+	}
+
+	return resp.ResultList, nil
+}
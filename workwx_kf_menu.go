@@ -0,0 +1,241 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// 菜单消息限制，长度均按字节计算
+const (
+	maxKfMsgMenuItems          = 10   // 菜单项最大数量
+	maxKfMsgMenuHeadTailLength = 1024 // 起始和结束文本最大长度
+	maxKfMsgMenuClickLength    = 128  // 回复菜单ID和内容最大长度
+	maxKfMsgMenuContentLength  = 1024 // 超链接和小程序菜单内容最大长度
+	maxKfMsgMenuURLLength      = 2048 // 超链接最大长度
+	maxKfMsgMenuTextLength     = 256  // 文本菜单内容最大长度
+)
+
+// 菜单项类型
+const (
+	KfMsgMenuTypeClick       = "click"
+	KfMsgMenuTypeView        = "view"
+	KfMsgMenuTypeMiniProgram = "miniprogram"
+	KfMsgMenuTypeText        = "text"
+)
+
+// Validate 校验菜单消息的菜单项数量和文本长度，返回全部错误
+func (m *KfMsgMenu) Validate() error {
+	var errs []error
+	if len(m.HeadContent) > maxKfMsgMenuHeadTailLength {
+		errs = append(errs, fmt.Errorf("head_content 不能超过 %d 字节", maxKfMsgMenuHeadTailLength))
+	}
+	if len(m.TailContent) > maxKfMsgMenuHeadTailLength {
+		errs = append(errs, fmt.Errorf("tail_content 不能超过 %d 字节", maxKfMsgMenuHeadTailLength))
+	}
+	if len(m.List) == 0 {
+		errs = append(errs, errors.New("菜单项不能为空"))
+	}
+	if len(m.List) > maxKfMsgMenuItems {
+		errs = append(errs, fmt.Errorf("菜单项不能超过 %d 个", maxKfMsgMenuItems))
+	}
+	for i, item := range m.List {
+		if err := item.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("list[%d]: %w", i, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validate 校验菜单项
+func (item *KfMsgMenuItem) validate() error {
+	switch item.Type {
+	case KfMsgMenuTypeClick:
+		if item.Click == nil {
+			return errors.New("click 不能为空")
+		}
+		return errors.Join(
+			checkKfMsgMenuLength("click.id", item.Click.ID, 0, maxKfMsgMenuClickLength),
+			checkKfMsgMenuLength("click.content", item.Click.Content, 1, maxKfMsgMenuClickLength),
+		)
+	case KfMsgMenuTypeView:
+		if item.View == nil {
+			return errors.New("view 不能为空")
+		}
+		return errors.Join(
+			checkKfMsgMenuLength("view.url", item.View.URL, 1, maxKfMsgMenuURLLength),
+			checkKfMsgMenuLength("view.content", item.View.Content, 1, maxKfMsgMenuContentLength),
+		)
+	case KfMsgMenuTypeMiniProgram:
+		if item.MiniProgram == nil {
+			return errors.New("miniprogram 不能为空")
+		}
+		return errors.Join(
+			checkKfMsgMenuLength("miniprogram.appid", item.MiniProgram.AppID, 1, maxKfMsgMenuContentLength),
+			checkKfMsgMenuLength("miniprogram.pagepath", item.MiniProgram.PagePath, 1, maxKfMsgMenuContentLength),
+			checkKfMsgMenuLength("miniprogram.content", item.MiniProgram.Content, 1, maxKfMsgMenuContentLength),
+		)
+	case KfMsgMenuTypeText:
+		if item.Text == nil {
+			return errors.New("text 不能为空")
+		}
+		return checkKfMsgMenuLength("text.content", item.Text.Content, 1, maxKfMsgMenuTextLength)
+	default:
+		return fmt.Errorf("未知的菜单项类型: %s", item.Type)
+	}
+}
+
+// checkKfMsgMenuLength 校验字段字节长度
+func checkKfMsgMenuLength(field, value string, minLen, maxLen int) error {
+	if len(value) < minLen {
+		return fmt.Errorf("%s 不能为空", field)
+	}
+	if len(value) > maxLen {
+		return fmt.Errorf("%s 不能超过 %d 字节", field, maxLen)
+	}
+	return nil
+}
+
+// KfMsgMenuBuilder 菜单消息构建器
+type KfMsgMenuBuilder struct {
+	menu KfMsgMenu
+}
+
+// NewKfMsgMenuBuilder 创建菜单消息构建器
+func NewKfMsgMenuBuilder() *KfMsgMenuBuilder {
+	return &KfMsgMenuBuilder{}
+}
+
+// Head 设置起始文本
+func (b *KfMsgMenuBuilder) Head(content string) *KfMsgMenuBuilder {
+	b.menu.HeadContent = content
+	return b
+}
+
+// Tail 设置结束文本
+func (b *KfMsgMenuBuilder) Tail(content string) *KfMsgMenuBuilder {
+	b.menu.TailContent = content
+	return b
+}
+
+// Click 添加回复菜单，客户点击后发送 content 并在文本消息的 menu_id 中回传 id
+func (b *KfMsgMenuBuilder) Click(id, content string) *KfMsgMenuBuilder {
+	b.menu.List = append(b.menu.List, &KfMsgMenuItem{
+		Type:  KfMsgMenuTypeClick,
+		Click: &KfMsgMenuClick{ID: id, Content: content},
+	})
+	return b
+}
+
+// View 添加超链接菜单
+func (b *KfMsgMenuBuilder) View(url, content string) *KfMsgMenuBuilder {
+	b.menu.List = append(b.menu.List, &KfMsgMenuItem{
+		Type: KfMsgMenuTypeView,
+		View: &KfMsgMenuView{URL: url, Content: content},
+	})
+	return b
+}
+
+// MiniProgram 添加小程序菜单
+func (b *KfMsgMenuBuilder) MiniProgram(appID, pagePath, content string) *KfMsgMenuBuilder {
+	b.menu.List = append(b.menu.List, &KfMsgMenuItem{
+		Type:        KfMsgMenuTypeMiniProgram,
+		MiniProgram: &KfMsgMenuMiniProgram{AppID: appID, PagePath: pagePath, Content: content},
+	})
+	return b
+}
+
+// Text 添加文本，noNewline 为 true 时内容后不换行
+func (b *KfMsgMenuBuilder) Text(content string, noNewline bool) *KfMsgMenuBuilder {
+	item := &KfMsgMenuText{Content: content}
+	if noNewline {
+		item.NoNewline = 1
+	}
+	b.menu.List = append(b.menu.List, &KfMsgMenuItem{Type: KfMsgMenuTypeText, Text: item})
+	return b
+}
+
+// Build 校验并返回菜单消息
+func (b *KfMsgMenuBuilder) Build() (*KfMsgMenu, error) {
+	menu := b.menu
+	menu.List = append([]*KfMsgMenuItem(nil), b.menu.List...)
+	if err := menu.Validate(); err != nil {
+		return nil, err
+	}
+	return &menu, nil
+}
+
+// KfSendMsgMenuMessage 客服发送菜单消息
+// menu: 菜单内容，可使用 KfMsgMenuBuilder 构建
+func (c *WorkwxClient) KfSendMsgMenuMessage(touser, openKfID, msgID string, menu *KfMsgMenu) (*KfSendMessageResponse, error) {
+	if menu == nil {
+		return nil, errors.New("菜单内容不能为空")
+	}
+	if err := menu.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c.sendKfMessage(touser, openKfID, msgID, map[string]any{
+		"msgtype": "msgmenu",
+		"msgmenu": menu,
+	})
+}
+
+// ==================== 事件响应消息 ====================
+
+// KfSendTextOnEvent 发送文本事件响应消息
+// code: 事件响应消息凭证，如用户进入会话事件的 welcome_code 或结束会话返回的 msg_code
+// 每个凭证只能使用一次，welcome_code 需在 20 秒内使用
+func (c *WorkwxClient) KfSendTextOnEvent(ctx context.Context, code, msgID, content string) (string, error) {
+	if content == "" {
+		return "", errors.New("消息内容不能为空")
+	}
+
+	return c.sendKfMessageOnEvent(ctx, code, msgID, map[string]any{
+		"msgtype": "text",
+		"text": map[string]any{
+			"content": content,
+		},
+	})
+}
+
+// KfSendMsgMenuOnEvent 发送菜单事件响应消息，可用于欢迎语菜单和结束会话后的满意度评价
+func (c *WorkwxClient) KfSendMsgMenuOnEvent(ctx context.Context, code, msgID string, menu *KfMsgMenu) (string, error) {
+	if menu == nil {
+		return "", errors.New("菜单内容不能为空")
+	}
+	if err := menu.Validate(); err != nil {
+		return "", err
+	}
+
+	return c.sendKfMessageOnEvent(ctx, code, msgID, map[string]any{
+		"msgtype": "msgmenu",
+		"msgmenu": menu,
+	})
+}
+
+// sendKfMessageOnEvent 发送事件响应消息，返回消息ID
+func (c *WorkwxClient) sendKfMessageOnEvent(ctx context.Context, code, msgID string, msgData map[string]any) (string, error) {
+	if code == "" {
+		return "", errors.New("事件响应消息凭证不能为空")
+	}
+
+	req := map[string]any{"code": code}
+	if msgID != "" {
+		req["msgid"] = msgID
+	}
+	for k, v := range msgData {
+		req[k] = v
+	}
+
+	var resp struct {
+		workwxResponse
+		MsgID string `json:"msgid"`
+	}
+	if err := c.postJSON(ctx, "/cgi-bin/kf/send_msg_on_event", req, &resp); err != nil {
+		return "", fmt.Errorf("发送事件响应消息失败: %w", err)
+	}
+
+	return resp.MsgID, nil
+}
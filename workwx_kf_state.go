@@ -0,0 +1,135 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidKfServiceStateTransition 不允许的会话状态变更
+var ErrInvalidKfServiceStateTransition = errors.New("不允许的客服会话状态变更")
+
+// KfServiceState 客服会话状态
+type KfServiceState int
+
+// 客服会话状态
+const (
+	KfServiceStateUntreated KfServiceState = 0 // 未处理，新会话接入
+	KfServiceStateAssistant KfServiceState = 1 // 由智能助手接待
+	KfServiceStateQueued    KfServiceState = 2 // 待接入池排队中
+	KfServiceStateServicing KfServiceState = 3 // 由人工接待
+	KfServiceStateEnded     KfServiceState = 4 // 已结束或未开始
+)
+
+// kfServiceStateTransitions 允许的会话状态变更
+var kfServiceStateTransitions = map[KfServiceState][]KfServiceState{
+	KfServiceStateUntreated: {KfServiceStateAssistant, KfServiceStateQueued, KfServiceStateServicing, KfServiceStateEnded},
+	KfServiceStateAssistant: {KfServiceStateQueued, KfServiceStateServicing, KfServiceStateEnded},
+	KfServiceStateQueued:    {KfServiceStateServicing, KfServiceStateEnded},
+	KfServiceStateServicing: {KfServiceStateServicing, KfServiceStateEnded},
+	KfServiceStateEnded:     {KfServiceStateAssistant, KfServiceStateQueued, KfServiceStateServicing},
+}
+
+// String 返回会话状态名称
+func (s KfServiceState) String() string {
+	switch s {
+	case KfServiceStateUntreated:
+		return "untreated"
+	case KfServiceStateAssistant:
+		return "assistant"
+	case KfServiceStateQueued:
+		return "queued"
+	case KfServiceStateServicing:
+		return "servicing"
+	case KfServiceStateEnded:
+		return "ended"
+	default:
+		return fmt.Sprintf("KfServiceState(%d)", int(s))
+	}
+}
+
+// Valid 是否为已知的会话状态
+func (s KfServiceState) Valid() bool {
+	_, ok := kfServiceStateTransitions[s]
+	return ok
+}
+
+// CanTransitionTo 是否允许从当前状态变更为 to
+// 由人工接待变更为由人工接待表示转接给其他接待人员
+func (s KfServiceState) CanTransitionTo(to KfServiceState) bool {
+	for _, state := range kfServiceStateTransitions[s] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+// KfServiceStateInfo 客服会话状态
+type KfServiceStateInfo struct {
+	ServiceState   KfServiceState `json:"service_state"`   // 会话状态
+	ServicerUserID string         `json:"servicer_userid"` // 接待人员 userid，仅由人工接待时返回
+}
+
+// GetKfServiceState 获取客服会话状态
+func (c *WorkwxClient) GetKfServiceState(ctx context.Context, openKfID, externalUserID string) (*KfServiceStateInfo, error) {
+	if openKfID == "" || externalUserID == "" {
+		return nil, errors.New("open_kfid 和 external_userid 不能为空")
+	}
+
+	var resp struct {
+		workwxResponse
+		KfServiceStateInfo
+	}
+	err := c.postJSON(ctx, "/cgi-bin/kf/service_state/get", map[string]string{
+		"open_kfid":       openKfID,
+		"external_userid": externalUserID,
+	}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("获取客服会话状态失败: %w", err)
+	}
+
+	return &resp.KfServiceStateInfo, nil
+}
+
+// TransKfServiceState 变更客服会话状态
+// 变更前先查询当前状态并校验是否允许变更，变更为由人工接待时 servicerUserID 必填
+// 返回的 msgCode 可用于发送结束语等事件消息，没有时为空
+func (c *WorkwxClient) TransKfServiceState(ctx context.Context, openKfID, externalUserID string, to KfServiceState, servicerUserID string) (string, error) {
+	if !to.Valid() {
+		return "", fmt.Errorf("未知的客服会话状态: %d", int(to))
+	}
+	if to == KfServiceStateServicing && servicerUserID == "" {
+		return "", errors.New("变更为由人工接待时 servicer_userid 不能为空")
+	}
+
+	current, err := c.GetKfServiceState(ctx, openKfID, externalUserID)
+	if err != nil {
+		return "", err
+	}
+	if !current.ServiceState.CanTransitionTo(to) {
+		return "", fmt.Errorf("%w: %s -> %s", ErrInvalidKfServiceStateTransition, current.ServiceState, to)
+	}
+	if to == KfServiceStateServicing && current.ServicerUserID == servicerUserID {
+		return "", fmt.Errorf("%w: 会话已由 %s 接待", ErrInvalidKfServiceStateTransition, servicerUserID)
+	}
+
+	req := map[string]any{
+		"open_kfid":       openKfID,
+		"external_userid": externalUserID,
+		"service_state":   int(to),
+	}
+	if servicerUserID != "" {
+		req["servicer_userid"] = servicerUserID
+	}
+
+	var resp struct {
+		workwxResponse
+		MsgCode string `json:"msg_code"`
+	}
+	if err := c.postJSON(ctx, "/cgi-bin/kf/service_state/trans", req, &resp); err != nil {
+		return "", fmt.Errorf("变更客服会话状态失败: %w", err)
+	}
+
+	return resp.MsgCode, nil
+}
@@ -0,0 +1,378 @@
+package wechat_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darwinOrg/go-wechat"
+)
+
+// TestKfConsumer_Sync 测试按游标分页拉取客服消息并解析各类消息
+func TestKfConsumer_Sync(t *testing.T) {
+	pages := map[string]string{
+		"": `{"errcode":0,"next_cursor":"cursor_1","has_more":1,"msg_list":[
+			{"msgid":"m1","open_kfid":"kf_1","external_userid":"wm_1","origin":3,"msgtype":"text","text":{"content":"你好","menu_id":"menu_1"}},
+			{"msgid":"m2","open_kfid":"kf_1","external_userid":"wm_1","origin":3,"msgtype":"location","location":{"latitude":23.1,"longitude":113.3,"name":"广州塔"}}
+		]}`,
+		"cursor_1": `{"errcode":0,"next_cursor":"cursor_2","has_more":0,"msg_list":[
+			{"msgid":"m3","open_kfid":"kf_1","external_userid":"wm_1","origin":3,"msgtype":"miniprogram","miniprogram":{"appid":"wx_mp","pagepath":"pages/index"}},
+			{"msgid":"m4","origin":4,"msgtype":"event","event":{"event_type":"enter_session","open_kfid":"kf_1","external_userid":"wm_1","scene_param":"abc","welcome_code":"code_1"}}
+		]}`,
+		"cursor_2": `{"errcode":0,"next_cursor":"cursor_2","has_more":0,"msg_list":[]}`,
+	}
+	var requests []map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgi-bin/kf/sync_msg", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+		if req["open_kfid"] == "kf_broken" {
+			_, _ = w.Write([]byte(`{"errcode":0,"next_cursor":"","has_more":1,"msg_list":[]}`))
+			return
+		}
+		if req["open_kfid"] == "kf_stuck" {
+			_, _ = w.Write([]byte(`{"errcode":0,"next_cursor":"cursor_stuck","has_more":1,"msg_list":[]}`))
+			return
+		}
+		cursor, _ := req["cursor"].(string)
+		_, _ = w.Write([]byte(pages[cursor]))
+	})
+	workwxClient := newMockWorkwxClient(t, mux)
+
+	var received []*wechat.KfMessage
+	failOn := ""
+	consumer := workwxClient.NewKfConsumer(wechat.KfMessageHandlerFunc(func(ctx context.Context, msg *wechat.KfMessage) error {
+		if msg.MsgID == failOn {
+			return errors.New("handler failed")
+		}
+		received = append(received, msg)
+		return nil
+	}), nil)
+	ctx := context.Background()
+
+	// 第二页处理失败时只保存第一页的游标
+	failOn = "m3"
+	if err := consumer.Sync(ctx, "kf_1", "token_1"); err == nil {
+		t.Fatal("expected handler error")
+	}
+	if cursor := consumer.Cursor(ctx, "kf_1"); cursor != "cursor_1" {
+		t.Fatalf("expected cursor_1, got %q", cursor)
+	}
+
+	failOn = ""
+	received = nil
+	if err := consumer.Sync(ctx, "kf_1", "token_1"); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if cursor := consumer.Cursor(ctx, "kf_1"); cursor != "cursor_2" {
+		t.Fatalf("expected cursor_2, got %q", cursor)
+	}
+	if len(received) != 2 || received[0].MiniProgram == nil || received[0].MiniProgram.AppID != "wx_mp" {
+		t.Fatalf("unexpected messages: %+v", received)
+	}
+	if event := received[1].Event; event == nil || event.EventType != wechat.KfEventEnterSession || event.WelcomeCode != "code_1" {
+		t.Fatalf("unexpected event: %+v", received[1].Event)
+	}
+	if last := requests[len(requests)-1]; last["cursor"] != "cursor_1" || last["token"] != "token_1" || last["open_kfid"] != "kf_1" {
+		t.Fatalf("unexpected request: %+v", last)
+	}
+
+	// has_more 为 1 但没有新游标时返回错误而不是重复拉取
+	if err := consumer.Sync(ctx, "kf_broken", ""); err == nil || !strings.Contains(err.Error(), "next_cursor") {
+		t.Fatalf("expected next_cursor error, got %v", err)
+	}
+	// has_more 为 1 但游标未变化时同样返回错误
+	requests = nil
+	if err := consumer.Sync(ctx, "kf_stuck", ""); err == nil || !strings.Contains(err.Error(), "未变化") {
+		t.Fatalf("expected unchanged cursor error, got %v", err)
+	}
+	if len(requests) != 2 || consumer.Cursor(ctx, "kf_stuck") != "cursor_stuck" {
+		t.Fatalf("expected to stop after cursor stopped advancing, got %d requests", len(requests))
+	}
+
+	// 第一次拉取时解析的文本和位置消息
+	resp, err := workwxClient.KfSyncMsg(ctx, &wechat.KfSyncMsgRequest{OpenKfID: "kf_1"})
+	if err != nil || resp.MsgList[0].Text.MenuID != "menu_1" || resp.MsgList[1].Location.Name != "广州塔" {
+		t.Fatalf("KfSyncMsg failed: %+v, %v", resp, err)
+	}
+}
+
+// TestWorkwxClient_TransKfServiceState 测试会话状态查询和变更校验
+func TestWorkwxClient_TransKfServiceState(t *testing.T) {
+	state := map[string]any{"service_state": 1}
+	var transRequests []map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgi-bin/kf/service_state/get", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(state)
+	})
+	mux.HandleFunc("/cgi-bin/kf/service_state/trans", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		transRequests = append(transRequests, req)
+		state = map[string]any{"service_state": req["service_state"], "servicer_userid": req["servicer_userid"]}
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "msg_code": "msg_code_1"})
+	})
+	workwxClient := newMockWorkwxClient(t, mux)
+	ctx := context.Background()
+
+	if _, err := workwxClient.TransKfServiceState(ctx, "kf_1", "wm_1", wechat.KfServiceStateServicing, ""); err == nil {
+		t.Fatal("expected error without servicer")
+	}
+
+	// 智能助手转人工
+	msgCode, err := workwxClient.TransKfServiceState(ctx, "kf_1", "wm_1", wechat.KfServiceStateServicing, "zhangsan")
+	if err != nil || msgCode != "msg_code_1" {
+		t.Fatalf("TransKfServiceState failed: %s, %v", msgCode, err)
+	}
+	info, err := workwxClient.GetKfServiceState(ctx, "kf_1", "wm_1")
+	if err != nil || info.ServiceState != wechat.KfServiceStateServicing || info.ServicerUserID != "zhangsan" {
+		t.Fatalf("GetKfServiceState failed: %+v, %v", info, err)
+	}
+
+	// 人工接待中不能退回智能助手
+	_, err = workwxClient.TransKfServiceState(ctx, "kf_1", "wm_1", wechat.KfServiceStateAssistant, "")
+	if !errors.Is(err, wechat.ErrInvalidKfServiceStateTransition) {
+		t.Fatalf("expected ErrInvalidKfServiceStateTransition, got %v", err)
+	}
+	if len(transRequests) != 1 {
+		t.Fatalf("expected invalid transition not to be sent, got %d requests", len(transRequests))
+	}
+}
+
+// TestWorkwxClient_KfAccountAndServicer 测试客服账号和接待人员管理
+func TestWorkwxClient_KfAccountAndServicer(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgi-bin/kf/account/add", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["name"] != "售前咨询" || req["media_id"] != "media_1" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 40058, "errmsg": "invalid parameter"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "open_kfid": "kf_1"})
+	})
+	mux.HandleFunc("/cgi-bin/kf/add_contact_way", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "url": "https://work.weixin.qq.com/kf/kfc?scene=" + req["scene"]})
+	})
+	mux.HandleFunc("/cgi-bin/kf/servicer/add", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "result_list": []map[string]any{
+			{"userid": "zhangsan", "errcode": 0},
+			{"department_id": 2, "errcode": 95014, "errmsg": "department not exist"},
+		}})
+	})
+	mux.HandleFunc("/cgi-bin/kf/servicer/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Query().Get("open_kfid") != "kf_1" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 95000, "errmsg": "invalid open_kfid"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "servicer_list": []map[string]any{
+			{"userid": "zhangsan", "status": 0},
+			{"department_id": 1},
+		}})
+	})
+	workwxClient := newMockWorkwxClient(t, mux)
+	ctx := context.Background()
+
+	if _, err := workwxClient.CreateKfAccount(ctx, "这是一个超过十六个字符长度的客服账号名称", "media_1"); err == nil {
+		t.Fatal("expected error for long name")
+	}
+	openKfID, err := workwxClient.CreateKfAccount(ctx, "售前咨询", "media_1")
+	if err != nil || openKfID != "kf_1" {
+		t.Fatalf("CreateKfAccount failed: %s, %v", openKfID, err)
+	}
+
+	if _, err := workwxClient.GetKfContactURL(ctx, openKfID, "product line 1"); err == nil {
+		t.Fatal("expected error for invalid scene")
+	}
+	contactURL, err := workwxClient.GetKfContactURL(ctx, openKfID, "product_line_1")
+	if err != nil || contactURL != "https://work.weixin.qq.com/kf/kfc?scene=product_line_1" {
+		t.Fatalf("GetKfContactURL failed: %s, %v", contactURL, err)
+	}
+
+	results, err := workwxClient.AddKfServicers(ctx, openKfID, []string{"zhangsan"}, []int64{2})
+	if err == nil || len(results) != 2 || results[1].ErrCode != 95014 {
+		t.Fatalf("expected partial failure, got %+v, %v", results, err)
+	}
+
+	servicers, err := workwxClient.ListKfServicers(ctx, openKfID)
+	if err != nil || len(servicers) != 2 || servicers[0].UserID != "zhangsan" || servicers[1].DepartmentID != 1 {
+		t.Fatalf("ListKfServicers failed: %+v, %v", servicers, err)
+	}
+}
+
+// TestWorkwxClient_KfMsgMenu 测试菜单消息构建校验和事件响应消息
+func TestWorkwxClient_KfMsgMenu(t *testing.T) {
+	var sent map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgi-bin/kf/send_msg_on_event", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&sent)
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "msgid": "msg_1"})
+	})
+	workwxClient := newMockWorkwxClient(t, mux)
+
+	builder := wechat.NewKfMsgMenuBuilder().Head("请对本次服务进行评价")
+	for i := 0; i < 11; i++ {
+		builder.Click("score", "满意")
+	}
+	if _, err := builder.Build(); err == nil || !strings.Contains(err.Error(), "不能超过 10 个") {
+		t.Fatalf("expected item count error, got %v", err)
+	}
+	if _, err := wechat.NewKfMsgMenuBuilder().Text(strings.Repeat("长", 100), false).Build(); err == nil {
+		t.Fatal("expected text length error")
+	}
+
+	menu, err := wechat.NewKfMsgMenuBuilder().
+		Head("请对本次服务进行评价").
+		Click("101", "满意").
+		Click("102", "不满意").
+		MiniProgram("wx_mp", "pages/feedback", "填写反馈").
+		Tail("感谢您的耐心").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	msgID, err := workwxClient.KfSendMsgMenuOnEvent(context.Background(), "msg_code_1", "", menu)
+	if err != nil || msgID != "msg_1" {
+		t.Fatalf("KfSendMsgMenuOnEvent failed: %s, %v", msgID, err)
+	}
+	list := sent["msgmenu"].(map[string]any)["list"].([]any)
+	if sent["code"] != "msg_code_1" || sent["msgtype"] != "msgmenu" || len(list) != 3 {
+		t.Fatalf("unexpected request: %+v", sent)
+	}
+	if item := list[2].(map[string]any); item["type"] != "miniprogram" || item["miniprogram"].(map[string]any)["appid"] != "wx_mp" {
+		t.Fatalf("unexpected miniprogram item: %+v", item)
+	}
+}
+
+// TestKfSendWindow 测试发送窗口的条数限制和排队消息
+func TestKfSendWindow(t *testing.T) {
+	var sent []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgi-bin/kf/send_msg", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		content := req["text"].(map[string]any)["content"].(string)
+		sent = append(sent, content)
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "msgid": "msg_" + content})
+	})
+	workwxClient := newMockWorkwxClient(t, mux)
+	window := workwxClient.NewKfSendWindow(nil)
+	ctx := context.Background()
+	text := func(content string) map[string]any {
+		return map[string]any{"msgtype": "text", "text": map[string]any{"content": content}}
+	}
+
+	if status := window.Status(ctx, "kf_1", "ext_1"); status.Allowed || !status.ExpiresAt.IsZero() {
+		t.Fatalf("unexpected initial status: %+v", status)
+	}
+	if _, err := window.Send(ctx, "ext_1", "kf_1", "", text("0")); !errors.Is(err, wechat.ErrKfSendWindowClosed) {
+		t.Fatalf("expected ErrKfSendWindowClosed, got %v", err)
+	}
+
+	// 窗口外的客户消息不开启窗口
+	expired := &wechat.KfMessage{MsgID: "c0", OpenKfID: "kf_1", ExternalUserID: "ext_1", Origin: wechat.KfOriginCustomer, SendTime: time.Now().Add(-49 * time.Hour).Unix()}
+	if err := window.Observe(ctx, expired); err != nil {
+		t.Fatalf("Observe failed: %v", err)
+	}
+	if resp, queued, err := window.SendOrQueue(ctx, "ext_1", "kf_1", "", text("q1")); err != nil || !queued || resp != nil {
+		t.Fatalf("expected queued, got %v, %v", queued, err)
+	}
+	if status := window.Status(ctx, "kf_1", "ext_1"); status.Allowed || status.Queued != 1 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if _, err := window.Send(ctx, "ext_1", "kf_1", "", text("direct")); !errors.Is(err, wechat.ErrKfSendQueuePending) {
+		t.Fatalf("expected ErrKfSendQueuePending, got %v", err)
+	}
+
+	// 客户再次发送消息后自动发送排队消息
+	var handled int
+	handler := window.Wrap(wechat.KfMessageHandlerFunc(func(ctx context.Context, msg *wechat.KfMessage) error {
+		handled++
+		return nil
+	}))
+	customer := &wechat.KfMessage{MsgID: "c1", OpenKfID: "kf_1", ExternalUserID: "ext_1", Origin: wechat.KfOriginCustomer, SendTime: time.Now().Unix()}
+	if err := handler.OnKfMessage(ctx, customer); err != nil || handled != 1 {
+		t.Fatalf("handler failed: %v", err)
+	}
+	if len(sent) != 1 || sent[0] != "q1" {
+		t.Fatalf("queued message not sent: %v", sent)
+	}
+
+	// 接待人员消息占用条数，已记录的消息不重复计数
+	for _, msgID := range []string{"s1", "s1", "msg_q1"} {
+		servicer := &wechat.KfMessage{MsgID: msgID, OpenKfID: "kf_1", ExternalUserID: "ext_1", Origin: wechat.KfOriginServicer, SendTime: time.Now().Unix()}
+		if err := window.Observe(ctx, servicer); err != nil {
+			t.Fatalf("Observe failed: %v", err)
+		}
+	}
+	if status := window.Status(ctx, "kf_1", "ext_1"); !status.Allowed || status.Remaining != 3 || status.Queued != 0 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	for _, content := range []string{"1", "2", "3"} {
+		if _, err := window.Send(ctx, "ext_1", "kf_1", "", text(content)); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	if _, err := window.Send(ctx, "ext_1", "kf_1", "", text("4")); !errors.Is(err, wechat.ErrKfSendWindowClosed) {
+		t.Fatalf("expected ErrKfSendWindowClosed, got %v", err)
+	}
+	if len(sent) != 4 {
+		t.Fatalf("unexpected sent messages: %v", sent)
+	}
+}
+
+// TestKfSendWindow_ServerLimit 测试企业微信拒绝发送时关闭本地窗口并排队
+func TestKfSendWindow_ServerLimit(t *testing.T) {
+	reject := true
+	var sent []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgi-bin/kf/send_msg", func(w http.ResponseWriter, r *http.Request) {
+		if reject {
+			_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 95001, "errmsg": "send msg fail"})
+			return
+		}
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		sent = append(sent, req["text"].(map[string]any)["content"].(string))
+		_ = json.NewEncoder(w).Encode(map[string]any{"errcode": 0, "msgid": "msg_" + sent[len(sent)-1]})
+	})
+	workwxClient := newMockWorkwxClient(t, mux)
+	window := workwxClient.NewKfSendWindow(nil)
+	ctx := context.Background()
+	text := map[string]any{"msgtype": "text", "text": map[string]any{"content": "hello"}}
+
+	customer := &wechat.KfMessage{MsgID: "c1", OpenKfID: "kf_1", ExternalUserID: "ext_1", Origin: wechat.KfOriginCustomer, SendTime: time.Now().Add(-time.Hour).Unix()}
+	if err := window.Observe(ctx, customer); err != nil {
+		t.Fatalf("Observe failed: %v", err)
+	}
+
+	// 本地窗口仍开放但企业微信拒绝发送
+	if _, err := window.Send(ctx, "ext_1", "kf_1", "", text); !errors.Is(err, wechat.ErrKfSendWindowClosed) {
+		t.Fatalf("expected ErrKfSendWindowClosed, got %v", err)
+	}
+	if status := window.Status(ctx, "kf_1", "ext_1"); status.Allowed || status.Remaining != 0 {
+		t.Fatalf("expected local window closed: %+v", status)
+	}
+	if _, queued, err := window.SendOrQueue(ctx, "ext_1", "kf_1", "", text); err != nil || !queued {
+		t.Fatalf("expected queued, got %v, %v", queued, err)
+	}
+
+	// 客户再次发送消息后重新开放窗口并发送排队消息
+	reject = false
+	customer = &wechat.KfMessage{MsgID: "c2", OpenKfID: "kf_1", ExternalUserID: "ext_1", Origin: wechat.KfOriginCustomer, SendTime: time.Now().Unix()}
+	if err := window.Observe(ctx, customer); err != nil {
+		t.Fatalf("Observe failed: %v", err)
+	}
+	if status := window.Status(ctx, "kf_1", "ext_1"); len(sent) != 1 || !status.Allowed || status.Remaining != 4 || status.Queued != 0 {
+		t.Fatalf("unexpected status after reopen: %+v, sent=%v", status, sent)
+	}
+}
@@ -0,0 +1,306 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// 客服消息发送窗口限制：客户最后一次发送消息后 48 小时内最多可发送 5 条消息
+const (
+	KfSendWindowDuration = 48 * time.Hour
+	KfSendWindowLimit    = 5
+)
+
+const (
+	// defaultKfSendWindowStateTTL 发送窗口状态缓存时长，同时也是排队消息的最长保留时间
+	defaultKfSendWindowStateTTL = 7 * 24 * time.Hour
+	// defaultKfSendWindowMaxQueued 每个客户最多排队的消息数量
+	defaultKfSendWindowMaxQueued = 20
+)
+
+// kfSendWindowErrCodes 企业微信因超出 48 小时或 5 条限制拒绝发送时返回的错误码
+// 本地状态与实际不一致时（如在窗口之外发送过消息）据此关闭本地窗口
+var kfSendWindowErrCodes = map[int]bool{
+	95001: true, // 发送客服消息失败，超出发送限制
+	95002: true, // 发送客服消息数量超过上限
+}
+
+var (
+	// ErrKfSendWindowClosed 发送窗口已关闭，需等待客户再次发送消息
+	ErrKfSendWindowClosed = errors.New("客服消息发送窗口已关闭")
+	// ErrKfSendQueueFull 排队消息已满
+	ErrKfSendQueueFull = errors.New("客服排队消息已满")
+	// ErrKfSendQueuePending 存在排队消息，直接发送会先于排队消息送达
+	ErrKfSendQueuePending = errors.New("存在未发送的客服排队消息")
+)
+
+// KfSendWindowStatus 客服消息发送窗口状态
+type KfSendWindowStatus struct {
+	Allowed   bool      // 当前是否允许发送
+	Remaining int       // 窗口内剩余可发送条数，窗口关闭时为 0
+	ExpiresAt time.Time // 窗口过期时间，未收到过客户消息时为零值
+	Queued    int       // 排队等待发送的消息数量
+}
+
+// KfQueuedMessage 排队等待发送的客服消息
+type KfQueuedMessage struct {
+	MsgID    string         `json:"msgid,omitempty"` // 指定的消息ID，可为空
+	MsgData  map[string]any `json:"msg_data"`        // 消息内容，包含 msgtype 及对应类型的字段
+	QueuedAt int64          `json:"queued_at"`       // 入队时间
+}
+
+// kfSendWindowState 缓存中保存的发送窗口状态
+type kfSendWindowState struct {
+	LastCustomerMsgTime int64              `json:"last_customer_msg_time"` // 客户最后一次发送消息的时间
+	SentMsgIDs          []string           `json:"sent_msgids"`            // 当前窗口内已发送的消息ID
+	Closed              bool               `json:"closed"`                 // 企业微信已拒绝发送，客户再次发送消息前不再尝试
+	Queue               []*KfQueuedMessage `json:"queue"`                  // 排队消息
+}
+
+// status 计算发送窗口状态
+func (s *kfSendWindowState) status(now time.Time) *KfSendWindowStatus {
+	status := &KfSendWindowStatus{Queued: len(s.Queue)}
+	if s.LastCustomerMsgTime == 0 {
+		return status
+	}
+
+	status.ExpiresAt = time.Unix(s.LastCustomerMsgTime, 0).Add(KfSendWindowDuration)
+	if now.Before(status.ExpiresAt) && !s.Closed {
+		status.Remaining = max(KfSendWindowLimit-len(s.SentMsgIDs), 0)
+		status.Allowed = status.Remaining > 0
+	}
+
+	return status
+}
+
+// KfSendWindowOptions 客服消息发送窗口选项
+type KfSendWindowOptions struct {
+	StateTTL  time.Duration                                    // 窗口状态和排队消息的缓存时长，默认 7 天
+	MaxQueued int                                              // 每个客户最多排队的消息数量，默认 20
+	OnError   func(openKfID, externalUserID string, err error) // 客户发送消息后自动发送排队消息失败时调用
+}
+
+// KfSendWindow 客服消息发送窗口
+// 根据 kf/sync_msg 拉取到的客户消息时间记录每个客户的发送窗口，发送前检查是否超出 48 小时或 5 条的限制
+// 窗口关闭时可将消息排队，客户再次发送消息后自动发送
+// 状态保存在客户端缓存中，进程内按客户串行更新，多实例部署时需由同一实例处理同一客服账号
+type KfSendWindow struct {
+	client *WorkwxClient
+	opts   KfSendWindowOptions
+	locks  keyLocks // 同一客户串行更新
+}
+
+// NewKfSendWindow 创建客服消息发送窗口
+func (c *WorkwxClient) NewKfSendWindow(opts *KfSendWindowOptions) *KfSendWindow {
+	window := &KfSendWindow{client: c}
+	if opts != nil {
+		window.opts = *opts
+	}
+	if window.opts.StateTTL <= 0 {
+		window.opts.StateTTL = defaultKfSendWindowStateTTL
+	}
+	if window.opts.MaxQueued <= 0 {
+		window.opts.MaxQueued = defaultKfSendWindowMaxQueued
+	}
+
+	return window
+}
+
+// Wrap 包装客服消息处理器，处理消息前先更新发送窗口，可直接传给 NewKfConsumer 使用
+func (w *KfSendWindow) Wrap(handler KfMessageHandler) KfMessageHandler {
+	return KfMessageHandlerFunc(func(ctx context.Context, msg *KfMessage) error {
+		if err := w.Observe(ctx, msg); err != nil {
+			return err
+		}
+		return handler.OnKfMessage(ctx, msg)
+	})
+}
+
+// Observe 根据拉取到的消息更新发送窗口
+// 客户消息重新开启窗口并发送排队消息，接待人员消息占用窗口条数，其他消息忽略
+func (w *KfSendWindow) Observe(ctx context.Context, msg *KfMessage) error {
+	if msg.ExternalUserID == "" {
+		return nil
+	}
+
+	switch msg.Origin {
+	case KfOriginCustomer:
+		flush, err := w.update(ctx, msg.OpenKfID, msg.ExternalUserID, func(state *kfSendWindowState) bool {
+			if msg.SendTime <= state.LastCustomerMsgTime {
+				return false
+			}
+			state.LastCustomerMsgTime = msg.SendTime
+			state.SentMsgIDs = nil
+			state.Closed = false
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		if flush {
+			if _, err := w.Flush(ctx, msg.OpenKfID, msg.ExternalUserID); err != nil && w.opts.OnError != nil {
+				w.opts.OnError(msg.OpenKfID, msg.ExternalUserID, err)
+			}
+		}
+	case KfOriginServicer:
+		_, err := w.update(ctx, msg.OpenKfID, msg.ExternalUserID, func(state *kfSendWindowState) bool {
+			if msg.SendTime < state.LastCustomerMsgTime || slices.Contains(state.SentMsgIDs, msg.MsgID) {
+				return false
+			}
+			state.SentMsgIDs = append(state.SentMsgIDs, msg.MsgID)
+			return true
+		})
+		return err
+	}
+
+	return nil
+}
+
+// Status 获取客户的发送窗口状态
+func (w *KfSendWindow) Status(ctx context.Context, openKfID, externalUserID string) *KfSendWindowStatus {
+	return w.load(ctx, openKfID, externalUserID).status(time.Now())
+}
+
+// Send 在发送窗口内发送客服消息，窗口关闭时返回 ErrKfSendWindowClosed
+// 存在排队消息时返回 ErrKfSendQueuePending，避免先于排队消息送达，此时应使用 SendOrQueue
+// msgData: 消息内容，包含 msgtype 及对应类型的字段，格式同 kf/send_msg 接口
+func (w *KfSendWindow) Send(ctx context.Context, externalUserID, openKfID, msgID string, msgData map[string]any) (*KfSendMessageResponse, error) {
+	unlock := w.lock(openKfID, externalUserID)
+	defer unlock()
+
+	state := w.load(ctx, openKfID, externalUserID)
+	if len(state.Queue) > 0 {
+		return nil, fmt.Errorf("%w: %d 条", ErrKfSendQueuePending, len(state.Queue))
+	}
+	resp, err := w.send(ctx, state, externalUserID, openKfID, msgID, msgData)
+	if err != nil {
+		if state.Closed {
+			return resp, errors.Join(err, w.save(ctx, openKfID, externalUserID, state))
+		}
+		return resp, err
+	}
+
+	return resp, w.save(ctx, openKfID, externalUserID, state)
+}
+
+// SendOrQueue 在发送窗口内直接发送客服消息，窗口关闭时排队等待客户再次发送消息
+// 排队时返回的 queued 为 true
+func (w *KfSendWindow) SendOrQueue(ctx context.Context, externalUserID, openKfID, msgID string, msgData map[string]any) (resp *KfSendMessageResponse, queued bool, err error) {
+	unlock := w.lock(openKfID, externalUserID)
+	defer unlock()
+
+	state := w.load(ctx, openKfID, externalUserID)
+	if len(state.Queue) == 0 {
+		resp, err = w.send(ctx, state, externalUserID, openKfID, msgID, msgData)
+		if !errors.Is(err, ErrKfSendWindowClosed) {
+			if err != nil {
+				return resp, false, err
+			}
+			return resp, false, w.save(ctx, openKfID, externalUserID, state)
+		}
+	}
+
+	// 已有排队消息时同样排队，保证消息顺序
+	if len(state.Queue) >= w.opts.MaxQueued {
+		return nil, false, fmt.Errorf("%w: %d", ErrKfSendQueueFull, w.opts.MaxQueued)
+	}
+	state.Queue = append(state.Queue, &KfQueuedMessage{
+		MsgID:    msgID,
+		MsgData:  msgData,
+		QueuedAt: time.Now().Unix(),
+	})
+
+	return nil, true, w.save(ctx, openKfID, externalUserID, state)
+}
+
+// Flush 在发送窗口内按顺序发送排队消息，返回已发送的消息响应
+// 发送失败时停止发送，未发送的消息保留在队列中
+func (w *KfSendWindow) Flush(ctx context.Context, openKfID, externalUserID string) ([]*KfSendMessageResponse, error) {
+	unlock := w.lock(openKfID, externalUserID)
+	defer unlock()
+
+	state := w.load(ctx, openKfID, externalUserID)
+	var (
+		sent    []*KfSendMessageResponse
+		sendErr error
+	)
+	for len(state.Queue) > 0 && state.status(time.Now()).Allowed {
+		msg := state.Queue[0]
+		resp, err := w.send(ctx, state, externalUserID, openKfID, msg.MsgID, msg.MsgData)
+		if err != nil {
+			sendErr = fmt.Errorf("发送排队消息失败: %w", err)
+			break
+		}
+		sent = append(sent, resp)
+		state.Queue = state.Queue[1:]
+	}
+	if len(sent) == 0 && !state.Closed {
+		return nil, sendErr
+	}
+
+	return sent, errors.Join(sendErr, w.save(ctx, openKfID, externalUserID, state))
+}
+
+// send 检查发送窗口并发送消息，成功后占用一条窗口条数
+func (w *KfSendWindow) send(ctx context.Context, state *kfSendWindowState, externalUserID, openKfID, msgID string, msgData map[string]any) (*KfSendMessageResponse, error) {
+	status := state.status(time.Now())
+	if !status.Allowed {
+		if status.ExpiresAt.IsZero() {
+			return nil, fmt.Errorf("%w: 未收到客户消息", ErrKfSendWindowClosed)
+		}
+		return nil, fmt.Errorf("%w: 窗口过期时间 %s，剩余 %d 条", ErrKfSendWindowClosed, status.ExpiresAt.Format(time.DateTime), status.Remaining)
+	}
+
+	resp, err := w.client.sendKfMessageContext(ctx, externalUserID, openKfID, msgID, msgData)
+	if err != nil {
+		if resp != nil && kfSendWindowErrCodes[resp.ErrCode] {
+			state.Closed = true
+			return nil, fmt.Errorf("%w: %d - %s", ErrKfSendWindowClosed, resp.ErrCode, resp.ErrMsg)
+		}
+		return resp, err
+	}
+	state.SentMsgIDs = append(state.SentMsgIDs, resp.MsgID)
+
+	return resp, nil
+}
+
+// update 加锁读取并修改发送窗口状态，fn 返回 true 时保存
+func (w *KfSendWindow) update(ctx context.Context, openKfID, externalUserID string, fn func(state *kfSendWindowState) bool) (bool, error) {
+	unlock := w.lock(openKfID, externalUserID)
+	defer unlock()
+
+	state := w.load(ctx, openKfID, externalUserID)
+	if !fn(state) {
+		return false, nil
+	}
+
+	return true, w.save(ctx, openKfID, externalUserID, state)
+}
+
+// lock 锁定客户的发送窗口，返回解锁函数
+func (w *KfSendWindow) lock(openKfID, externalUserID string) func() {
+	return w.locks.lock(w.stateKey(openKfID, externalUserID))
+}
+
+// load 读取发送窗口状态
+func (w *KfSendWindow) load(ctx context.Context, openKfID, externalUserID string) *kfSendWindowState {
+	var state kfSendWindowState
+	cacheGetJSON(ctx, w.client.cache, w.stateKey(openKfID, externalUserID), &state)
+	return &state
+}
+
+// save 保存发送窗口状态
+func (w *KfSendWindow) save(ctx context.Context, openKfID, externalUserID string, state *kfSendWindowState) error {
+	if err := cacheSetJSON(ctx, w.client.cache, w.stateKey(openKfID, externalUserID), state, w.opts.StateTTL); err != nil {
+		return fmt.Errorf("保存客服消息发送窗口失败: %w", err)
+	}
+	return nil
+}
+
+// stateKey 发送窗口状态的缓存键
+func (w *KfSendWindow) stateKey(openKfID, externalUserID string) string {
+	return "workwx:kf:window:" + w.client.config.CorpID + ":" + openKfID + ":" + externalUserID
+}